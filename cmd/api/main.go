@@ -5,8 +5,12 @@ import (
 	"os"
 
 	"homecare-backend/internal/config"
+	"homecare-backend/internal/jobs"
 	"homecare-backend/internal/middleware"
 	"homecare-backend/internal/routes" // <--- Import ini
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/payment"
+	"homecare-backend/pkg/storage"
 	"homecare-backend/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -22,10 +26,30 @@ func main() {
 
 	// 2. Connect DB
 	config.ConnectDB()
+	config.MigrateDB()
 
 	// Init Firebase
 	utils.InitFCM()
 
+	// Init Storage (Dokumen Mitra)
+	storage.Init()
+
+	// Init Payment Gateway (Tagihan Customer)
+	payment.Init()
+
+	// Init Disbursement (Cek Rekening & Transfer ke Mitra)
+	disbursement.Init()
+
+	// Background Jobs
+	jobs.StartDocumentExpiryJob()
+	jobs.StartPartnerMetricsJob()
+	jobs.StartLedgerReconciliationJob()
+	jobs.StartPayoutScheduleJob()
+	jobs.StartPayoutRetryJob()
+	jobs.StartEarningsReleaseJob()
+	jobs.StartPaymentReconciliationJob()
+	jobs.StartOrgInvoiceJob()
+
 	// 3. Init Router
 	r := gin.Default()
 
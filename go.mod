@@ -9,6 +9,7 @@ require (
 	github.com/midtrans/midtrans-go v1.3.8
 	golang.org/x/crypto v0.44.0
 	golang.org/x/time v0.14.0
+	google.golang.org/api v0.256.0
 	gorm.io/driver/mysql v1.6.0
 	gorm.io/gorm v1.31.1
 )
@@ -54,7 +55,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.37.0 // indirect
 	golang.org/x/oauth2 v0.33.0 // indirect
 	golang.org/x/sync v0.18.0 // indirect
-	google.golang.org/api v0.256.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/genproto v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250818200422-3122310a409c // indirect
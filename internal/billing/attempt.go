@@ -0,0 +1,145 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/sequence"
+	"homecare-backend/pkg/payment"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrNotPayable = errors.New("order tidak dalam status menunggu pembayaran")
+
+// NextOrderNo nomor order baru: INV-<tanggal>-<urutan harian>, misal INV-20261019-000042.
+// Urutan diambil dari tabel sequences, jadi order di detik yang sama tidak bentrok.
+func NextOrderNo(db *gorm.DB, now time.Time) (string, error) {
+	day := now.Format("20060102")
+	seq, err := sequence.Next(db, "ORDER-"+day)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INV-%s-%06d", day, seq), nil
+}
+
+// Charge membuat sesi pembayaran baru di gateway untuk order PENDING_PAYMENT.
+// 1. Sesi lama yang masih PENDING dibatalkan di gateway & ditandai SUPERSEDED (cegah bayar dobel)
+// 2. Sesi pertama pakai order_no asli, berikutnya <order_no>-R<n> (gateway menolak order_id yang sama)
+// 3. Link pembayaran terbaru disimpan di order
+func Charge(ctx context.Context, db *gorm.DB, gateway payment.Gateway, order *models.Order) (*models.PaymentAttempt, payment.Charge, error) {
+	var charge payment.Charge
+	if order.Status != "PENDING_PAYMENT" {
+		return nil, charge, ErrNotPayable
+	}
+
+	var customer models.User
+	db.First(&customer, order.CustomerID)
+	var service models.Service
+	if err := db.First(&service, order.ServiceID).Error; err != nil {
+		return nil, charge, err
+	}
+
+	var previous []models.PaymentAttempt
+	db.Where("order_id = ?", order.ID).Order("attempt_no asc").Find(&previous)
+	for _, p := range previous {
+		if p.Status != models.AttemptPending {
+			continue
+		}
+		// Best effort: tagihan yang belum pernah dipilih metode bayarnya memang tidak dikenal gateway
+		if err := gateway.Cancel(ctx, p.GatewayOrderNo); err != nil && !errors.Is(err, payment.ErrNotFound) {
+			log.Printf("[Billing] Gagal membatalkan sesi %s di %s: %v", p.GatewayOrderNo, gateway.Name(), err)
+		}
+		db.Model(&models.PaymentAttempt{}).
+			Where("id = ? AND status = ?", p.ID, models.AttemptPending).
+			Update("status", models.AttemptSuperseded)
+	}
+
+	attempt := models.PaymentAttempt{
+		OrderID:        order.ID,
+		AttemptNo:      len(previous) + 1,
+		GatewayOrderNo: order.OrderNo,
+		Provider:       gateway.Name(),
+		Amount:         order.ChargeAmount(),
+		Status:         models.AttemptPending,
+	}
+	if attempt.AttemptNo > 1 {
+		attempt.GatewayOrderNo = fmt.Sprintf("%s-R%d", order.OrderNo, attempt.AttemptNo)
+	}
+	// Simpan dulu: nomor unik mencegah dua request "Bayar Ulang" paralel membuat sesi yang sama
+	if err := db.Create(&attempt).Error; err != nil {
+		return nil, charge, err
+	}
+
+	items := []payment.Item{
+		{ID: fmt.Sprintf("SVC-%d", service.ID), Name: service.Name, Price: order.TotalAmount, Qty: 1},
+	}
+	// Rincian item harus sama dengan nominal tagihan, potongan dicatat sebagai item minus
+	if order.PayerAmount > 0 {
+		items = append(items, payment.Item{ID: "PAYER", Name: "Ditanggung Perusahaan/Asuransi", Price: -order.PayerAmount, Qty: 1})
+	}
+	if order.BalanceUsed > 0 {
+		items = append(items, payment.Item{ID: "BALANCE", Name: "Potongan Saldo", Price: -order.BalanceUsed, Qty: 1})
+	}
+
+	charge, err := gateway.CreateCharge(ctx, payment.ChargeRequest{
+		OrderNo:       attempt.GatewayOrderNo,
+		Amount:        attempt.Amount,
+		CustomerName:  customer.FullName,
+		CustomerEmail: customer.Email,
+		CustomerPhone: customer.Phone,
+		Items:         items,
+	})
+	if err != nil {
+		db.Model(&attempt).Update("status", models.AttemptFailed)
+		return nil, charge, err
+	}
+
+	attempt.Token = charge.Token
+	attempt.PaymentURL = charge.RedirectURL
+	db.Model(&attempt).Updates(map[string]interface{}{"token": charge.Token, "payment_url": charge.RedirectURL})
+
+	// Simpan Link Pembayaran ke order, biar tidak hilang kalau user menutup browser
+	order.PaymentURL = charge.RedirectURL
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_url", charge.RedirectURL)
+
+	return &attempt, charge, nil
+}
+
+// ResolveOrder mencari order dari order_id yang dikirim gateway (sesi pembayaran mana pun, atau order_no asli).
+// attempt nil untuk order lama yang dibuat sebelum ada riwayat sesi pembayaran.
+func ResolveOrder(db *gorm.DB, gatewayOrderNo string) (models.Order, *models.PaymentAttempt, error) {
+	var order models.Order
+	var attempt models.PaymentAttempt
+	err := db.Where("gateway_order_no = ?", gatewayOrderNo).First(&attempt).Error
+	if err == nil {
+		return order, &attempt, db.First(&order, attempt.OrderID).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return order, nil, err
+	}
+	return order, nil, db.Where("order_no = ?", gatewayOrderNo).First(&order).Error
+}
+
+// MarkAttempt mencatat status pembayaran terakhir sebuah sesi. Sesi yang sudah PAID tidak diubah lagi.
+func MarkAttempt(db *gorm.DB, attempt *models.PaymentAttempt, paymentStatus string) {
+	var status string
+	switch paymentStatus {
+	case payment.StatusPaid:
+		status = models.AttemptPaid
+	case payment.StatusFailed:
+		status = models.AttemptFailed
+	default:
+		return // Masih pending, status sesi tetap (PENDING / SUPERSEDED)
+	}
+
+	res := db.Model(&models.PaymentAttempt{}).
+		Where("id = ? AND status <> ?", attempt.ID, models.AttemptPaid).
+		Update("status", status)
+	if res.Error == nil && res.RowsAffected > 0 {
+		attempt.Status = status
+	}
+}
@@ -0,0 +1,291 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/payment"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Jenis transaksi wallet customer
+const (
+	TrxTopup           = "TOPUP"            // Isi saldo lewat payment gateway
+	TrxRefund          = "REFUND"           // Refund order ke saldo
+	TrxPromo           = "PROMO"            // Saldo promo dari platform
+	TrxPayment         = "PAYMENT"          // Saldo dipakai bayar order
+	TrxPaymentReversal = "PAYMENT_REVERSAL" // Saldo dikembalikan karena order batal sebelum lunas
+)
+
+// TopupPrefix order_no tagihan top-up di gateway: TOPUP-<id wallet_transaction>
+const TopupPrefix = "TOPUP-"
+
+var (
+	ErrNothingToRefund = errors.New("tidak ada dana order yang perlu direfund")
+	ErrRefundExceeded  = errors.New("nominal refund melebihi sisa dana yang harus dikembalikan")
+)
+
+// IsTopup true kalau order_no gateway adalah tagihan top-up saldo (bukan order layanan)
+func IsTopup(orderNo string) bool {
+	return strings.HasPrefix(orderNo, TopupPrefix)
+}
+
+// UseBalance memotong saldo customer untuk order yang baru dibuat (dipanggil di dalam transaksi).
+// Saldo dipakai sebanyak mungkin untuk sisa tagihan (setelah tanggungan organisasi).
+// Kalau sisa tagihan jadi 0, panggil SettleWithoutCharge supaya order langsung PAID tanpa lewat gateway.
+func UseBalance(tx *gorm.DB, order *models.Order) error {
+	w, err := wallet.ForUser(tx, order.CustomerID)
+	if err != nil {
+		return err
+	}
+	used := min(w.Balance, order.ChargeAmount())
+	if used <= 0 {
+		return nil
+	}
+
+	if err := wallet.Debit(tx, w.ID, used); err != nil {
+		return err
+	}
+	trx := models.WalletTransaction{
+		WalletID:    w.ID,
+		OrderID:     &order.ID,
+		Amount:      used,
+		Type:        TrxPayment,
+		Status:      "SUCCESS",
+		Description: "Pembayaran order " + order.OrderNo,
+	}
+	if err := tx.Create(&trx).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("balance_used", used).Error; err != nil {
+		return err
+	}
+	order.BalanceUsed = used
+
+	// Ledger: hutang saldo ke customer berkurang, jadi pendapatan diterima dimuka
+	_, err = ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("ORDER_BALANCE_PAID:%d", order.ID),
+		Kind:        "ORDER_BALANCE_PAID",
+		Description: "Pembayaran order " + order.OrderNo + " dari saldo",
+		OrderID:     &order.ID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.WalletAccount(w.ID), used),
+			ledger.Credit(ledger.CustomerUnearned, used),
+		},
+	})
+	return err
+}
+
+// restoreBalance mengembalikan saldo yang sudah dipotong kalau order batal sebelum lunas (di dalam transaksi)
+func restoreBalance(tx *gorm.DB, order *models.Order) error {
+	if order.BalanceUsed <= 0 {
+		return nil
+	}
+	w, err := wallet.ForUser(tx, order.CustomerID)
+	if err != nil {
+		return err
+	}
+	if err := wallet.Credit(tx, w.ID, order.BalanceUsed); err != nil {
+		return err
+	}
+	trx := models.WalletTransaction{
+		WalletID:    w.ID,
+		OrderID:     &order.ID,
+		Amount:      order.BalanceUsed,
+		Type:        TrxPaymentReversal,
+		Status:      "SUCCESS",
+		Description: "Order " + order.OrderNo + " batal, saldo dikembalikan",
+	}
+	if err := tx.Create(&trx).Error; err != nil {
+		return err
+	}
+
+	_, err = ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("ORDER_BALANCE_RESTORE:%d", order.ID),
+		Kind:        "ORDER_BALANCE_RESTORE",
+		Description: "Order " + order.OrderNo + " batal, saldo dikembalikan",
+		OrderID:     &order.ID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.CustomerUnearned, order.BalanceUsed),
+			ledger.Credit(ledger.WalletAccount(w.ID), order.BalanceUsed),
+		},
+	})
+	return err
+}
+
+// StartTopup mencatat top-up PENDING. Tagihannya dibuat di gateway dengan order_no = PaymentRef.
+func StartTopup(db *gorm.DB, userID uint64, amount money.Money) (models.WalletTransaction, error) {
+	var trx models.WalletTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		w, err := wallet.ForUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		trx = models.WalletTransaction{
+			WalletID:    w.ID,
+			Amount:      amount,
+			Type:        TrxTopup,
+			Status:      "PENDING",
+			Description: "Top-up saldo",
+		}
+		if err := tx.Create(&trx).Error; err != nil {
+			return err
+		}
+		trx.PaymentRef = fmt.Sprintf("%s%d", TopupPrefix, trx.ID)
+		return tx.Model(&trx).Update("payment_ref", trx.PaymentRef).Error
+	})
+	return trx, err
+}
+
+// ApplyTopupStatus menerapkan status pembayaran gateway ke top-up PENDING.
+// Return false kalau tidak ada perubahan (masih pending, atau sudah diproses notifikasi sebelumnya).
+func ApplyTopupStatus(db *gorm.DB, trx *models.WalletTransaction, paymentStatus string) (bool, error) {
+	status := "PENDING"
+	switch paymentStatus {
+	case payment.StatusPaid:
+		status = "SUCCESS"
+	case payment.StatusFailed:
+		status = "FAILED"
+	}
+	if trx.Status != "PENDING" || status == "PENDING" {
+		return false, nil
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.WalletTransaction{}).
+			Where("id = ? AND type = ? AND status = ?", trx.ID, TrxTopup, "PENDING").
+			Updates(map[string]interface{}{"status": status, "processed_at": time.Now()})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+
+		if status != "SUCCESS" {
+			return nil
+		}
+		if err := wallet.Credit(tx, trx.WalletID, trx.Amount); err != nil {
+			return err
+		}
+		// Ledger: uang masuk ke gateway, jadi hutang saldo ke customer
+		_, err := ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("TOPUP_PAID:%d", trx.ID),
+			Kind:        "TOPUP_PAID",
+			Description: "Top-up saldo " + trx.PaymentRef,
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.GatewayClearing, trx.Amount),
+				ledger.Credit(ledger.WalletAccount(trx.WalletID), trx.Amount),
+			},
+		})
+		return err
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	trx.Status = status
+	return true, nil
+}
+
+// RefundToWallet mengembalikan dana order (yang sudah tercatat sebagai hutang refund) ke saldo customer.
+// amount 0 = refund seluruh sisa.
+func RefundToWallet(db *gorm.DB, orderID uint64, amount money.Money, reason string) (models.WalletTransaction, error) {
+	var trx models.WalletTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Kunci order supaya dua Finance tidak merefund sisa yang sama bersamaan
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+
+		refundable, err := ledger.OrderBalance(tx, ledger.RefundsPayable, order.ID)
+		if err != nil {
+			return err
+		}
+		if refundable <= 0 {
+			return ErrNothingToRefund
+		}
+		if amount == 0 {
+			amount = refundable
+		}
+		if amount > refundable {
+			return ErrRefundExceeded
+		}
+
+		w, err := wallet.ForUser(tx, order.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := wallet.Credit(tx, w.ID, amount); err != nil {
+			return err
+		}
+		trx = models.WalletTransaction{
+			WalletID:    w.ID,
+			OrderID:     &order.ID,
+			Amount:      amount,
+			Type:        TrxRefund,
+			Status:      "SUCCESS",
+			Description: truncate("Refund order "+order.OrderNo+": "+reason, 255),
+		}
+		if err := tx.Create(&trx).Error; err != nil {
+			return err
+		}
+
+		_, err = ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("REFUND_WALLET:%d", trx.ID),
+			Kind:        "REFUND_WALLET",
+			Description: "Refund order " + order.OrderNo + " ke saldo customer",
+			OrderID:     &order.ID,
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.RefundsPayable, amount),
+				ledger.Credit(ledger.WalletAccount(w.ID), amount),
+			},
+		})
+		return err
+	})
+	return trx, err
+}
+
+// GrantPromo menambah saldo promo ke wallet customer (dicatat sebagai beban promosi)
+func GrantPromo(db *gorm.DB, userID uint64, amount money.Money, description string) (models.WalletTransaction, error) {
+	var trx models.WalletTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		w, err := wallet.ForUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := wallet.Credit(tx, w.ID, amount); err != nil {
+			return err
+		}
+		trx = models.WalletTransaction{
+			WalletID:    w.ID,
+			Amount:      amount,
+			Type:        TrxPromo,
+			Status:      "SUCCESS",
+			Description: truncate(description, 255),
+		}
+		if err := tx.Create(&trx).Error; err != nil {
+			return err
+		}
+
+		_, err = ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("PROMO_CREDIT:%d", trx.ID),
+			Kind:        "PROMO_CREDIT",
+			Description: truncate("Saldo promo: "+description, 255),
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.PromotionExpense, amount),
+				ledger.Credit(ledger.WalletAccount(w.ID), amount),
+			},
+		})
+		return err
+	})
+	return trx, err
+}
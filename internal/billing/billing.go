@@ -0,0 +1,172 @@
+// Package billing berisi perubahan status pembayaran order yang dipakai bersama
+// oleh webhook gateway, replay event & job rekonsiliasi.
+package billing
+
+import (
+	"fmt"
+	"homecare-backend/internal/invoice"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/metrics"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/payment"
+	"homecare-backend/pkg/utils"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderStatusFor menerjemahkan status pembayaran gateway ke status order internal
+func OrderStatusFor(paymentStatus string) string {
+	switch paymentStatus {
+	case payment.StatusPaid:
+		return "PAID"
+	case payment.StatusFailed:
+		return "CANCELLED"
+	default:
+		return "PENDING_PAYMENT"
+	}
+}
+
+// ApplyPaymentStatus mengubah status order PENDING_PAYMENT -> PAID / CANCELLED secara atomik.
+// Return false kalau tidak ada perubahan (status sama, order sudah diproses, atau notifikasi paralel sudah duluan).
+func ApplyPaymentStatus(db *gorm.DB, order *models.Order, orderStatus string) (bool, error) {
+	if order.Status != "PENDING_PAYMENT" || orderStatus == "PENDING_PAYMENT" {
+		return false, nil
+	}
+
+	updates := map[string]interface{}{"status": orderStatus}
+	now := time.Now()
+	if orderStatus == "PAID" {
+		updates["paid_at"] = now // Patokan jeda dispatch per tier
+	}
+	if orderStatus == "CANCELLED" {
+		updates["cancel_reason"] = "PAYMENT_FAILED"
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", order.ID, "PENDING_PAYMENT").
+			Updates(updates)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+
+		if orderStatus != "PAID" {
+			// Saldo wallet yang sudah dipotong saat order dibuat dikembalikan
+			return restoreBalance(tx, order)
+		}
+		// Ledger: uang customer masuk ke gateway (di luar bagian yang dibayar saldo / organisasi), dicatat sebagai pendapatan diterima dimuka
+		if _, err := ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("ORDER_PAID:%d", order.ID),
+			Kind:        "ORDER_PAID",
+			Description: "Pembayaran order " + order.OrderNo,
+			OrderID:     &order.ID,
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.GatewayClearing, order.ChargeAmount()),
+				ledger.Credit(ledger.CustomerUnearned, order.ChargeAmount()),
+			},
+		}); err != nil {
+			return err
+		}
+		return postPayerBilled(tx, order)
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	order.Status = orderStatus
+	if orderStatus == "PAID" {
+		order.PaidAt = &now
+	} else {
+		order.CancelReason = "PAYMENT_FAILED"
+	}
+	return true, nil
+}
+
+// NotifyPaymentStatus efek samping setelah status pembayaran order berubah: terbitkan invoice & kirim push notif
+func NotifyPaymentStatus(db *gorm.DB, order models.Order, orderStatus string) {
+	if orderStatus == "PAID" {
+		// Invoice terbit saat lunas (nomor urut diambil saat itu). Kalau gagal, diterbitkan saat customer membukanya.
+		if _, err := invoice.Issue(db, order.ID); err != nil {
+			log.Printf("[Billing] Gagal menerbitkan invoice order %s: %v", order.OrderNo, err)
+		}
+
+		// A. Notifikasi ke Customer (Payment Success)
+		var customer models.User
+		if err := db.First(&customer, order.CustomerID).Error; err == nil {
+			if customer.FCMToken != "" {
+				utils.SendNotification(
+					customer.FCMToken,
+					"Pembayaran Berhasil! ✅",
+					"Terima kasih! Pembayaran Anda telah diterima. Kami sedang mencarikan Mitra untuk Anda.",
+					map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "payment_success"},
+				)
+			}
+		}
+
+		// B. Cek Direct Booking atau Open Booking
+		if order.PartnerID != nil {
+			// --- DIRECT BOOKING ---
+			// Kita butuh User ID dari partner_profile, tapi di Order cuma ada PartnerID (Profile ID)
+			// Jadi kita harus join ke tabel partner_profiles lalu ke users
+			var profile models.PartnerProfile
+			if err := db.Preload("User").First(&profile, *order.PartnerID).Error; err == nil {
+				// Kirim Notif ke Mitra
+				if profile.User.FCMToken != "" {
+					utils.SendNotification(
+						profile.User.FCMToken,
+						"Order Baru Masuk! 🔔",
+						"Ada pasien yang memesan jasa Anda secara khusus. Segera konfirmasi!",
+						map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "new_order_direct"},
+					)
+				}
+			}
+		} else {
+			// --- OPEN BOOKING (BROADCAST) ---
+			// Cari mitra di sekitar pasien (Logic mirip SearchPartners)
+			// Kita butuh koordinat pasien. Asumsi: Pasien ada di alamat yg tersimpan (Next: Order harus punya lat/lng sendiri)
+			// Untuk sekarang, kita broadcast ke SEMUA mitra yang ONLINE saja dulu atau radius jika memungkinkan.
+			// Simplifikasi: Broadcast ke semua mitra aktif yang punya token.
+
+			// Prioritas tier: Gold dikabari duluan, Silver/Bronze menyusul sesuai jeda dispatch
+			var activePartners []models.PartnerProfile
+			db.Preload("User").Where("is_active = ?", true).Find(&activePartners)
+			sort.SliceStable(activePartners, func(i, j int) bool {
+				return metrics.TierRank(activePartners[i].Tier) < metrics.TierRank(activePartners[j].Tier)
+			})
+
+			for _, p := range activePartners {
+				if p.User.FCMToken != "" {
+					go func(token string, delay time.Duration) { // Pakai goroutine biar gak blocking
+						time.Sleep(delay)
+						utils.SendNotification(
+							token,
+							"Lowongan Job Baru! 📢",
+							"Ada order baru di area sekitar Anda. Cek sekarang sebelum diambil orang lain!",
+							map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "new_order_open"},
+						)
+					}(p.User.FCMToken, metrics.DispatchDelay(p.Tier))
+				}
+			}
+		}
+	} else if orderStatus == "CANCELLED" {
+		// KIRIM NOTIFIKASI JIKA CANCELLED (Payment Failed/Expired)
+		// Cari User Customer
+		var customer models.User
+		if err := db.First(&customer, order.CustomerID).Error; err == nil {
+			if customer.FCMToken != "" {
+				utils.SendNotification(
+					customer.FCMToken,
+					"Pembayaran Gagal/Expired ❌",
+					"Maaf, pesanan Anda dibatalkan karena pembayaran gagal atau waktu habis.",
+					map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "order_cancelled"},
+				)
+			}
+		}
+	}
+
+}
@@ -0,0 +1,45 @@
+package billing
+
+import (
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SettleWithoutCharge menandai order PAID kalau tidak ada sisa yang perlu ditagih lewat gateway
+// (seluruhnya ditanggung organisasi dan/atau saldo). Dipanggil di dalam transaksi pembuatan order.
+func SettleWithoutCharge(tx *gorm.DB, order *models.Order) error {
+	if order.ChargeAmount() > 0 || order.Status != "PENDING_PAYMENT" {
+		return nil
+	}
+	now := time.Now()
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Updates(map[string]interface{}{"status": "PAID", "paid_at": now}).Error; err != nil {
+		return err
+	}
+	order.Status = "PAID"
+	order.PaidAt = &now
+	return postPayerBilled(tx, order)
+}
+
+// postPayerBilled mencatat bagian yang ditanggung organisasi sebagai piutang saat order lunas.
+// Piutang ditagih lewat tagihan bulanan & lunas saat organisasi transfer (lihat package payer).
+func postPayerBilled(tx *gorm.DB, order *models.Order) error {
+	if order.PayerAmount <= 0 {
+		return nil
+	}
+	_, err := ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("ORDER_PAYER_BILLED:%d", order.ID),
+		Kind:        "ORDER_PAYER_BILLED",
+		Description: "Order " + order.OrderNo + " ditanggung organisasi penjamin",
+		OrderID:     &order.ID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.CustomerReceivable, order.PayerAmount),
+			ledger.Credit(ledger.CustomerUnearned, order.PayerAmount),
+		},
+	})
+	return err
+}
@@ -0,0 +1,239 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/payment"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status laporan & item rekonsiliasi
+const (
+	ReconRunning   = "RUNNING"
+	ReconCompleted = "COMPLETED"
+	ReconFailed    = "FAILED"
+
+	ReconItemOpen     = "OPEN"
+	ReconItemFixed    = "FIXED"
+	ReconItemResolved = "RESOLVED"
+)
+
+var ErrReconItemClosed = errors.New("item rekonsiliasi sudah ditutup")
+
+// Reconcile mencocokkan semua order yang dibuat pada tanggal `day` dengan status transaksi di gateway.
+// 1. Ambil order hari itu & cek status masing-masing ke gateway
+// 2. Selisih yang aman diperbaiki otomatis (lunas di gateway tapi masih PENDING_PAYMENT, atau gagal/expired)
+// 3. Selisih lain dicatat OPEN untuk ditindaklanjuti Finance
+// 4. Notifikasi gateway untuk order_no yang tidak dikenal (dari payment_events) ikut dilaporkan
+func Reconcile(db *gorm.DB, gateway payment.Gateway, day time.Time, triggeredBy *uint64) (*models.PaymentReconciliation, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	recon := models.PaymentReconciliation{
+		Date:        start,
+		Provider:    gateway.Name(),
+		Status:      ReconRunning,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+	if err := db.Create(&recon).Error; err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	if err := db.Where("created_at >= ? AND created_at < ?", start, end).Order("id ASC").Find(&orders).Error; err != nil {
+		db.Model(&recon).Updates(map[string]interface{}{"status": ReconFailed, "finished_at": time.Now()})
+		return nil, err
+	}
+
+	for i := range orders {
+		item := checkOrder(db, gateway, &orders[i])
+		recon.OrdersChecked++
+		if item == nil {
+			recon.Matched++
+			continue
+		}
+
+		item.ReconciliationID = recon.ID
+		if err := db.Create(item).Error; err != nil {
+			log.Printf("[Recon] Gagal simpan selisih order %s: %v", item.OrderNo, err)
+		}
+		recon.Mismatched++
+		if item.Status == ReconItemFixed {
+			recon.AutoFixed++
+		}
+	}
+
+	// Notifikasi untuk order_no yang tidak ada di sistem kita
+	var unknown []models.PaymentEvent
+	db.Where("result = ? AND error = ? AND created_at >= ? AND created_at < ?",
+		models.PaymentEventRejected, "order not found", start, end).Find(&unknown)
+	seen := map[string]bool{}
+	for _, ev := range unknown {
+		if seen[ev.OrderNo] {
+			continue
+		}
+		seen[ev.OrderNo] = true
+
+		item := models.PaymentReconciliationItem{
+			ReconciliationID: recon.ID,
+			OrderNo:          ev.OrderNo,
+			Type:             models.ReconUnknownOrder,
+			GatewayStatus:    ev.TransactionStatus,
+			Detail:           fmt.Sprintf("Notifikasi gateway (event %d, gross_amount %s) untuk order_no yang tidak dikenal", ev.ID, ev.GrossAmount),
+			Status:           ReconItemOpen,
+		}
+		if err := db.Create(&item).Error; err != nil {
+			log.Printf("[Recon] Gagal simpan order tidak dikenal %s: %v", ev.OrderNo, err)
+		}
+		recon.Mismatched++
+	}
+
+	now := time.Now()
+	recon.Status = ReconCompleted
+	recon.FinishedAt = &now
+	if err := db.Save(&recon).Error; err != nil {
+		return nil, err
+	}
+	return &recon, nil
+}
+
+// checkOrder membandingkan satu order dengan gateway. Return nil kalau cocok.
+func checkOrder(db *gorm.DB, gateway payment.Gateway, order *models.Order) *models.PaymentReconciliationItem {
+	if order.ChargeAmount() <= 0 {
+		return nil // Lunas penuh dari saldo wallet, tidak ada tagihan di gateway
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	item := &models.PaymentReconciliationItem{
+		OrderID:     &order.ID,
+		OrderNo:     order.OrderNo,
+		LocalStatus: order.Status,
+		LocalAmount: order.ChargeAmount(),
+		Status:      ReconItemOpen,
+	}
+	localPaid := order.PaidAt != nil
+
+	// Cek sesi yang lunas, kalau belum ada cek sesi terbaru (order lama tanpa riwayat sesi pakai order_no asli)
+	gatewayOrderNo := order.OrderNo
+	var attempt models.PaymentAttempt
+	if err := db.Where("order_id = ?", order.ID).
+		Order(fmt.Sprintf("status = '%s' DESC, attempt_no DESC", models.AttemptPaid)).
+		First(&attempt).Error; err == nil {
+		gatewayOrderNo = attempt.GatewayOrderNo
+	}
+
+	st, err := gateway.QueryStatus(ctx, gatewayOrderNo)
+	if errors.Is(err, payment.ErrNotFound) {
+		// Belum pernah dibayar (customer tidak lanjut ke halaman bayar) -> wajar kalau order juga belum lunas
+		if !localPaid {
+			return nil
+		}
+		item.Type = models.ReconNotPaidAtGateway
+		item.GatewayStatus = "NOT_FOUND"
+		item.Detail = "Order tercatat lunas, tapi transaksi tidak ada di gateway"
+		return item
+	}
+	if err != nil {
+		item.Type = models.ReconQueryFailed
+		item.Detail = truncate(err.Error(), 255)
+		return item
+	}
+
+	item.GatewayStatus = st.RawStatus
+	item.GatewayAmount = st.Amount
+
+	switch {
+	case st.Status == payment.StatusPaid && st.Amount != order.ChargeAmount():
+		// Nominal beda: jangan diubah otomatis, Finance yang putuskan
+		item.Type = models.ReconAmountMismatch
+		item.Detail = fmt.Sprintf("Nominal gateway %s, tagihan order %s", st.Amount, order.ChargeAmount())
+		return item
+
+	case st.Status == payment.StatusPaid && order.Status == "PENDING_PAYMENT":
+		// Webhook hilang / gagal: aman diperbaiki otomatis
+		item.Type = models.ReconPaidNotRecorded
+		return autoFix(db, order, "PAID", item)
+
+	case st.Status == payment.StatusPaid && !localPaid:
+		// Order sudah dibatalkan sebelum pembayaran masuk -> perlu refund manual
+		item.Type = models.ReconPaidAfterCancel
+		item.Detail = "Pembayaran diterima gateway setelah order dibatalkan, perlu refund"
+		return item
+
+	case st.Status == payment.StatusFailed && order.Status == "PENDING_PAYMENT":
+		item.Type = models.ReconStalePending
+		return autoFix(db, order, "CANCELLED", item)
+
+	case st.Status != payment.StatusPaid && localPaid && !strings.Contains(st.RawStatus, "refund"):
+		item.Type = models.ReconNotPaidAtGateway
+		item.Detail = "Order tercatat lunas, tapi status di gateway belum/tidak lunas"
+		return item
+	}
+	return nil
+}
+
+// autoFix menerapkan status gateway ke order (lewat jalur yang sama dengan webhook)
+func autoFix(db *gorm.DB, order *models.Order, orderStatus string, item *models.PaymentReconciliationItem) *models.PaymentReconciliationItem {
+	changed, err := ApplyPaymentStatus(db, order, orderStatus)
+	if err != nil {
+		item.Detail = truncate("Gagal perbaiki otomatis: "+err.Error(), 255)
+		return item
+	}
+	if !changed {
+		// Sudah diperbaiki proses lain (webhook datang barusan) -> anggap cocok
+		return nil
+	}
+
+	NotifyPaymentStatus(db, *order, orderStatus)
+	now := time.Now()
+	item.Status = ReconItemFixed
+	item.Detail = "Diperbaiki otomatis: order diubah ke " + orderStatus
+	item.ResolvedAt = &now
+	return item
+}
+
+// ResolveItem menutup selisih OPEN setelah ditindaklanjuti Finance
+func ResolveItem(db *gorm.DB, itemID, userID uint64, note string) (*models.PaymentReconciliationItem, error) {
+	var item models.PaymentReconciliationItem
+	if err := db.First(&item, itemID).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := db.Model(&models.PaymentReconciliationItem{}).
+		Where("id = ? AND status = ?", item.ID, ReconItemOpen).
+		Updates(map[string]interface{}{
+			"status":      ReconItemResolved,
+			"note":        note,
+			"resolved_by": userID,
+			"resolved_at": now,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrReconItemClosed
+	}
+
+	item.Status = ReconItemResolved
+	item.Note = note
+	item.ResolvedBy = &userID
+	item.ResolvedAt = &now
+	return &item, nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
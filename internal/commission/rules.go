@@ -0,0 +1,102 @@
+package commission
+
+import (
+	"homecare-backend/internal/metrics"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Result adalah hasil resolusi aturan bagi hasil untuk satu order
+type Result struct {
+	Rule           *models.CommissionRule `json:"rule"`            // NULL = pakai default tier
+	PartnerPercent float64                `json:"partner_percent"` // 0-100 (tampilan), hitungan pakai basis poin
+	BaseAmount     money.Money            `json:"base_amount"`     // Total bayar - admin fee
+	PartnerShare   money.Money            `json:"partner_share"`
+	PlatformShare  money.Money            `json:"platform_share"`
+}
+
+// RuleID helper untuk dicatat di WalletTransaction
+func (r Result) RuleID() *uint64 {
+	if r.Rule == nil {
+		return nil
+	}
+	return &r.Rule.ID
+}
+
+// ActiveRules mengambil semua rule aktif yang berlaku di waktu `at`
+func ActiveRules(db *gorm.DB, at time.Time) ([]models.CommissionRule, error) {
+	var rules []models.CommissionRule
+	err := db.
+		Where("is_active = ? AND effective_from <= ?", true, at).
+		Where("effective_to IS NULL OR effective_to > ?", at).
+		Find(&rules).Error
+	return rules, err
+}
+
+// Pick memilih rule paling spesifik dari kandidat:
+// Layanan+Tier > Layanan > Tier > Global. Kalau sama spesifiknya, yang effective_from paling baru menang.
+func Pick(rules []models.CommissionRule, serviceID uint, tier string, at time.Time) *models.CommissionRule {
+	var best *models.CommissionRule
+	bestScore := -1
+
+	for i := range rules {
+		r := &rules[i]
+		if !r.IsActive || r.EffectiveFrom.After(at) || (r.EffectiveTo != nil && !r.EffectiveTo.After(at)) {
+			continue
+		}
+		if r.ServiceID != nil && *r.ServiceID != serviceID {
+			continue
+		}
+		if r.Tier != "" && r.Tier != tier {
+			continue
+		}
+
+		score := 0
+		if r.ServiceID != nil {
+			score += 2
+		}
+		if r.Tier != "" {
+			score++
+		}
+
+		if score > bestScore || (score == bestScore && r.EffectiveFrom.After(best.EffectiveFrom)) {
+			best = r
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// Calculate menghitung bagi hasil dari rule terpilih (atau default tier kalau tidak ada rule).
+// Aturan pembulatan: bagian Mitra dibulatkan ke bawah ke Rupiah penuh, sisa pecahannya masuk platform.
+// Jadi PartnerShare + PlatformShare selalu == totalAmount.
+func Calculate(rule *models.CommissionRule, tier string, totalAmount, adminFee money.Money) Result {
+	bps := metrics.PartnerShareBps(tier)
+	if rule != nil {
+		bps = money.PercentToBps(rule.PartnerPercent)
+	}
+
+	base := totalAmount - adminFee
+	share, _ := base.Split(bps)
+
+	return Result{
+		Rule:           rule,
+		PartnerPercent: money.BpsToPercent(bps),
+		BaseAmount:     base,
+		PartnerShare:   share,
+		PlatformShare:  totalAmount - share,
+	}
+}
+
+// Resolve = ActiveRules + Pick + Calculate untuk satu order
+func Resolve(db *gorm.DB, order models.Order, service models.Service, tier string, at time.Time) (Result, error) {
+	rules, err := ActiveRules(db, at)
+	if err != nil {
+		return Result{}, err
+	}
+	rule := Pick(rules, service.ID, tier, at)
+	return Calculate(rule, tier, order.TotalAmount, service.AdminFee), nil
+}
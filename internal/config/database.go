@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/sequence"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var DB *gorm.DB
@@ -29,3 +35,279 @@ func ConnectDB() {
 
 	fmt.Println("🚀 Database MySQL berhasil terhubung!")
 }
+
+// MigrateDB menyiapkan tabel & kolom baru.
+// Tabel lama (users, orders, dll) sengaja TIDAK di-AutoMigrate penuh,
+// biar tipe kolom yang sudah ada di production (ENUM dll) tidak diubah GORM.
+func MigrateDB() {
+	// Ledger baru pertama kali dibuat -> saldo wallet lama perlu dicatat sebagai saldo awal
+	firstLedger := !DB.Migrator().HasTable(&models.LedgerTransaction{})
+
+	// 1. Tabel baru: aman di-AutoMigrate penuh
+	err := DB.AutoMigrate(
+		&models.Competency{},
+		&models.ServiceCompetency{},
+		&models.PartnerCompetency{},
+		&models.PartnerDocument{},
+		&models.PartnerVerificationLog{},
+		&models.OrderLocation{},
+		&models.OrderVisit{},
+		&models.PartnerMetric{},
+		&models.OrderReview{},
+		&models.CommissionRule{},
+		&models.LedgerAccount{},
+		&models.LedgerTransaction{},
+		&models.LedgerEntry{},
+		&models.BankAccount{},
+		&models.PayoutSetting{},
+		&models.PayoutRun{},
+		&models.OrderDispute{},
+		&models.PaymentEvent{},
+		&models.PaymentReconciliation{},
+		&models.PaymentReconciliationItem{},
+		&models.PaymentAttempt{},
+		&models.Sequence{},
+		&models.Invoice{},
+		&models.InvoiceItem{},
+		&models.PayerOrganization{},
+		&models.PayerMember{},
+		&models.PayerCoverage{},
+		&models.OrgInvoice{},
+		&models.OrgPayment{},
+	)
+	if err != nil {
+		log.Fatal("Gagal migrasi tabel:", err)
+	}
+
+	// 2. Tabel lama: cukup tambah kolom yang belum ada
+	addMissingColumns(&models.Service{}, "MinExperienceYears")
+	addMissingColumns(&models.PartnerProfile{}, "STRExpiresAt", "LocationUpdatedAt", "Gender", "Languages", "PriceTier", "Tier")
+	addMissingColumns(&models.Order{}, "CancelReason", "PaidAt", "AcceptedAt", "BalanceUsed", "IdempotencyKey",
+		"ServiceFee", "AdminFee", "PayerAmount", "PayerOrganizationID", "OrgInvoiceID")
+	addMissingColumns(&models.WalletTransaction{}, "CommissionRuleID", "CommissionPercent", "IdempotencyKey", "BankAccountID",
+		"PayoutProvider", "PayoutRef", "FailureReason", "ProcessedAt", "PayoutRunID", "AvailableAt",
+		"Description", "PaymentRef")
+	// Status penarikan bertambah PROCESSING: kolom ENUM lama diubah ke VARCHAR
+	widenEnumColumns(&models.WalletTransaction{}, "Type", "Status")
+
+	// Mitra lama yang sudah is_verified dianggap APPROVED
+	if addMissingColumns(&models.PartnerProfile{}, "VerificationStatus") {
+		DB.Exec(`UPDATE partner_profiles p JOIN users u ON u.id = p.user_id
+			SET p.verification_status = ? WHERE u.is_verified = ?`, models.PartnerApproved, true)
+	}
+
+	// Dulu Mitra baru langsung aktif tanpa verifikasi: yang belum APPROVED tidak boleh ONLINE.
+	// Dijalankan tiap start (bukan hanya saat kolom dibuat) supaya database yang sudah
+	// terlanjur migrasi sebelum perbaikan ini ikut dibereskan.
+	if res := DB.Model(&models.PartnerProfile{}).
+		Where("is_active = ? AND verification_status <> ?", true, models.PartnerApproved).
+		Update("is_active", false); res.Error != nil {
+		log.Fatal("Gagal menonaktifkan Mitra yang belum terverifikasi:", res.Error)
+	} else if res.RowsAffected > 0 {
+		fmt.Printf("🔒 %d Mitra belum terverifikasi dinonaktifkan\n", res.RowsAffected)
+	}
+
+	// Koordinat pasien boleh NULL (= belum diisi). Dulu "belum diisi" disimpan sebagai 0,0,
+	// jadi data lama dikonversi sekali; setelah itu 0,0 dianggap lokasi yang sah.
+	makeColumnsNullable(&models.Patient{}, "Lat", "Lng")
+	runOnce("MIGRATE-patient-null-coords", func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE patients SET lat = NULL, lng = NULL WHERE lat = 0 AND lng = 0").Error
+	})
+
+	// Kolom nominal lama (DECIMAL/DOUBLE) -> BIGINT Rupiah bulat (lihat pkg/money)
+	migrateMoneyColumns(&models.Service{}, "Price", "AdminFee")
+	migrateMoneyColumns(&models.Order{}, "TotalAmount")
+	migrateMoneyColumns(&models.Wallet{}, "Balance")
+	migrateMoneyColumns(&models.WalletTransaction{}, "Amount")
+
+	// 3. Index tambahan
+	addMissingIndexes(&models.PartnerProfile{}, "idx_partner_geo")
+	addMissingIndexes(&models.Order{}, "idx_order_idem_key", "idx_orders_payer_organization_id", "idx_orders_org_invoice_id")
+	addMissingIndexes(&models.WalletTransaction{}, "idx_wallet_idem_key", "idx_wallet_transactions_payout_ref",
+		"idx_wallet_transactions_payout_run_id", "idx_wallet_transactions_available_at", "idx_wallet_transactions_payment_ref")
+
+	// 4. Order yang sudah ON_DUTY sebelum fitur check-in tidak punya catatan kunjungan,
+	// padahal check-out & jurnal medis mewajibkannya. Anggap check-in di jam jadwal mulai.
+	backfillOrderVisits()
+
+	// Order yang dibayar sebelum kolom paid_at ada: tanpa ini invoice ditolak "belum dibayar".
+	// Jam bayar aslinya tidak tercatat, pakai jam diterima Mitra / dibuat sebagai perkiraan.
+	if err := DB.Exec(`UPDATE orders SET paid_at = COALESCE(accepted_at, created_at)
+		WHERE paid_at IS NULL AND status IN ('PAID','ASSIGNED','EN_ROUTE','ON_DUTY','COMPLETED')`).Error; err != nil {
+		log.Fatal("Gagal backfill paid_at:", err)
+	}
+
+	// 5. Saldo awal ledger
+	if firstLedger {
+		count, err := ledger.BackfillOpeningBalances(DB)
+		if err != nil {
+			log.Fatal("Gagal mencatat saldo awal ledger:", err)
+		}
+		fmt.Printf("📒 Saldo awal %d wallet dicatat ke ledger\n", count)
+	}
+
+	fmt.Println("📦 Migrasi database selesai!")
+}
+
+// runOnce menjalankan migrasi data satu kali saja, ditandai lewat tabel sequences.
+// Penanda & perubahan data ada di transaksi yang sama: kalau gagal, diulang saat start berikutnya.
+func runOnce(name string, fn func(tx *gorm.DB) error) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		n, err := sequence.Next(tx, name)
+		if err != nil || n > 1 {
+			return err
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		log.Fatalf("Gagal migrasi data %s: %v", name, err)
+	}
+}
+
+// backfillOrderVisits membuat / melengkapi order_visits untuk order ON_DUTY yang belum punya check-in.
+// Aman dijalankan berulang: hanya menyentuh order yang belum tercatat check-in.
+func backfillOrderVisits() {
+	res := DB.Exec(`INSERT INTO order_visits (order_id, partner_id, check_in_at, created_at, updated_at)
+		SELECT o.id, o.partner_id, o.schedule_start, NOW(), NOW() FROM orders o
+		WHERE o.status = 'ON_DUTY' AND o.partner_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM order_visits v WHERE v.order_id = o.id)`)
+	if res.Error != nil {
+		log.Fatal("Gagal backfill kunjungan order:", res.Error)
+	}
+	created := res.RowsAffected
+
+	// Sudah ada baris (misal dari override Admin) tapi check_in_at kosong
+	res = DB.Exec(`UPDATE order_visits v JOIN orders o ON o.id = v.order_id
+		SET v.check_in_at = o.schedule_start
+		WHERE o.status = 'ON_DUTY' AND v.check_in_at IS NULL`)
+	if res.Error != nil {
+		log.Fatal("Gagal backfill kunjungan order:", res.Error)
+	}
+
+	if total := created + res.RowsAffected; total > 0 {
+		fmt.Printf("📍 Check-in %d order ON_DUTY lama dicatat dari jadwal mulai\n", total)
+	}
+}
+
+// addMissingColumns menambah kolom (nama field struct) kalau belum ada di tabel.
+// Return true kalau ada kolom yang baru dibuat (berguna untuk backfill data lama).
+func addMissingColumns(model interface{}, fields ...string) bool {
+	added := false
+	for _, field := range fields {
+		if DB.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := DB.Migrator().AddColumn(model, field); err != nil {
+			log.Fatalf("Gagal menambah kolom %s: %v", field, err)
+		}
+		added = true
+	}
+	return added
+}
+
+// migrateMoneyColumns mengubah tipe kolom nominal ke BIGINT kalau belum.
+// Nilai lama dibulatkan dulu secara eksplisit (ROUND = setengah ke atas) sebelum ALTER,
+// biar hasilnya tidak tergantung aturan konversi implisit MySQL.
+func migrateMoneyColumns(model interface{}, fields ...string) {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(model); err != nil {
+		log.Fatalf("Gagal membaca model: %v", err)
+	}
+
+	columnTypes, err := DB.Migrator().ColumnTypes(model)
+	if err != nil {
+		log.Fatalf("Gagal membaca kolom %s: %v", stmt.Table, err)
+	}
+
+	for _, field := range fields {
+		f := stmt.Schema.LookUpField(field)
+		if f == nil {
+			log.Fatalf("Field %s tidak ada di %s", field, stmt.Table)
+		}
+
+		for _, ct := range columnTypes {
+			if ct.Name() != f.DBName || strings.EqualFold(ct.DatabaseTypeName(), "bigint") {
+				continue
+			}
+			if err := DB.Exec("UPDATE ? SET ? = ROUND(?)", clause.Table{Name: stmt.Table}, clause.Column{Name: f.DBName}, clause.Column{Name: f.DBName}).Error; err != nil {
+				log.Fatalf("Gagal membulatkan %s.%s: %v", stmt.Table, f.DBName, err)
+			}
+			if err := DB.Migrator().AlterColumn(model, field); err != nil {
+				log.Fatalf("Gagal mengubah tipe %s.%s: %v", stmt.Table, f.DBName, err)
+			}
+			fmt.Printf("💰 Kolom %s.%s diubah ke BIGINT\n", stmt.Table, f.DBName)
+		}
+	}
+}
+
+// widenEnumColumns mengubah kolom ENUM lama ke tipe sesuai tag struct (VARCHAR),
+// supaya status baru tidak ditolak database.
+func widenEnumColumns(model interface{}, fields ...string) {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(model); err != nil {
+		log.Fatalf("Gagal membaca model: %v", err)
+	}
+
+	columnTypes, err := DB.Migrator().ColumnTypes(model)
+	if err != nil {
+		log.Fatalf("Gagal membaca kolom %s: %v", stmt.Table, err)
+	}
+
+	for _, field := range fields {
+		f := stmt.Schema.LookUpField(field)
+		if f == nil {
+			log.Fatalf("Field %s tidak ada di %s", field, stmt.Table)
+		}
+		for _, ct := range columnTypes {
+			if ct.Name() != f.DBName || !strings.EqualFold(ct.DatabaseTypeName(), "enum") {
+				continue
+			}
+			if err := DB.Migrator().AlterColumn(model, field); err != nil {
+				log.Fatalf("Gagal mengubah tipe %s.%s: %v", stmt.Table, f.DBName, err)
+			}
+		}
+	}
+}
+
+// makeColumnsNullable mengubah kolom NOT NULL lama jadi boleh NULL (field pointer di struct)
+func makeColumnsNullable(model interface{}, fields ...string) {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(model); err != nil {
+		log.Fatalf("Gagal membaca model: %v", err)
+	}
+
+	columnTypes, err := DB.Migrator().ColumnTypes(model)
+	if err != nil {
+		log.Fatalf("Gagal membaca kolom %s: %v", stmt.Table, err)
+	}
+
+	for _, field := range fields {
+		f := stmt.Schema.LookUpField(field)
+		if f == nil {
+			log.Fatalf("Field %s tidak ada di %s", field, stmt.Table)
+		}
+		for _, ct := range columnTypes {
+			if ct.Name() != f.DBName {
+				continue
+			}
+			if nullable, ok := ct.Nullable(); !ok || nullable {
+				continue
+			}
+			if err := DB.Migrator().AlterColumn(model, field); err != nil {
+				log.Fatalf("Gagal mengubah %s.%s jadi nullable: %v", stmt.Table, f.DBName, err)
+			}
+		}
+	}
+}
+
+// addMissingIndexes membuat index (sesuai tag gorm di struct) kalau belum ada
+func addMissingIndexes(model interface{}, names ...string) {
+	for _, name := range names {
+		if DB.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := DB.Migrator().CreateIndex(model, name); err != nil {
+			log.Fatalf("Gagal membuat index %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package earnings mengatur masa tahan pendapatan Mitra.
+// Pendapatan order masuk sebagai INCOME PENDING (ditahan selama masa komplain), lalu:
+// - lewat masa tahan / customer konfirmasi -> SUCCESS (masuk saldo wallet, bisa ditarik)
+// - customer komplain -> FROZEN sampai sengketa diputuskan (SUCCESS atau REVERSED)
+package earnings
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/money"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status INCOME
+const (
+	StatusHeld      = "PENDING"  // Masih dalam masa tahan
+	StatusFrozen    = "FROZEN"   // Dibekukan karena sengketa
+	StatusAvailable = "SUCCESS"  // Sudah masuk saldo wallet
+	StatusReversed  = "REVERSED" // Dikembalikan ke customer (sengketa dimenangkan customer)
+)
+
+var ErrNotHeld = errors.New("pendapatan order ini tidak sedang ditahan")
+
+// HoldDuration lama pendapatan ditahan setelah order selesai.
+// Config .env: EARNINGS_HOLD_HOURS (default 72, 0 = langsung tersedia)
+func HoldDuration() time.Duration {
+	hours := 72
+	if v, err := strconv.Atoi(os.Getenv("EARNINGS_HOLD_HOURS")); err == nil && v >= 0 {
+		hours = v
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// FillBalances mengisi saldo ditahan & dibekukan wallet (Balance = saldo tersedia / bisa ditarik)
+func FillBalances(db *gorm.DB, w *models.Wallet) {
+	var rows []struct {
+		Status string
+		Total  money.Money
+	}
+	db.Model(&models.WalletTransaction{}).
+		Select("status, COALESCE(SUM(amount), 0) AS total").
+		Where("wallet_id = ? AND type = ? AND status IN ?", w.ID, "INCOME", []string{StatusHeld, StatusFrozen}).
+		Group("status").
+		Scan(&rows)
+
+	w.PendingBalance, w.FrozenBalance = 0, 0
+	for _, r := range rows {
+		if r.Status == StatusHeld {
+			w.PendingBalance = r.Total
+		} else {
+			w.FrozenBalance = r.Total
+		}
+	}
+}
+
+// Release melepas satu pendapatan yang ditahan ke saldo wallet (dipanggil di dalam transaksi)
+func Release(tx *gorm.DB, trxID uint64) error {
+	return release(tx, trxID, StatusHeld, "Masa tahan selesai")
+}
+
+// ConfirmOrder: customer mengonfirmasi layanan OK -> pendapatan Mitra langsung dilepas
+func ConfirmOrder(db *gorm.DB, orderID uint64) error {
+	trx, err := heldIncome(db, orderID, StatusHeld)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return release(tx, trx.ID, StatusHeld, "Dikonfirmasi customer")
+	})
+}
+
+// ReleaseDue melepas semua pendapatan yang masa tahannya sudah lewat. Return jumlah yang dilepas.
+func ReleaseDue(db *gorm.DB, now time.Time) (int, error) {
+	var ids []uint64
+	err := db.Model(&models.WalletTransaction{}).
+		Where("type = ? AND status = ? AND available_at <= ?", "INCOME", StatusHeld, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error { return Release(tx, id) })
+		if err != nil {
+			if !errors.Is(err, ErrNotHeld) {
+				log.Printf("[Earnings] Gagal melepas pendapatan %d: %v", id, err)
+			}
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+// Freeze membekukan pendapatan order yang masih ditahan (dipanggil saat customer komplain, di dalam transaksi)
+func Freeze(tx *gorm.DB, orderID uint64) (models.WalletTransaction, error) {
+	trx, err := heldIncome(tx, orderID, StatusHeld)
+	if err != nil {
+		return trx, err
+	}
+	if err := transition(tx, trx.ID, StatusHeld, StatusFrozen); err != nil {
+		return trx, err
+	}
+	trx.Status = StatusFrozen
+	return trx, nil
+}
+
+// Unfreeze: sengketa dimenangkan Mitra -> dana masuk saldo wallet
+func Unfreeze(tx *gorm.DB, trxID uint64) error {
+	return release(tx, trxID, StatusFrozen, "Sengketa selesai, dana dilepas ke Mitra")
+}
+
+// Reverse: sengketa dimenangkan customer -> bagian Mitra jadi hutang refund ke customer
+func Reverse(tx *gorm.DB, trxID uint64) error {
+	var trx models.WalletTransaction
+	if err := tx.First(&trx, trxID).Error; err != nil {
+		return err
+	}
+	if err := transition(tx, trx.ID, StatusFrozen, StatusReversed); err != nil {
+		return err
+	}
+
+	_, err := ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("EARNINGS_REVERSE:%d", trx.ID),
+		Kind:        "EARNINGS_REVERSE",
+		Description: "Sengketa dimenangkan customer, bagian Mitra dikembalikan",
+		OrderID:     trx.OrderID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.EarningsHeld, trx.Amount),
+			ledger.Credit(ledger.RefundsPayable, trx.Amount),
+		},
+	})
+	return err
+}
+
+// release: ubah status from -> SUCCESS, tambah saldo wallet & catat ledger (ditahan -> hutang ke Mitra)
+func release(tx *gorm.DB, trxID uint64, from, description string) error {
+	var trx models.WalletTransaction
+	if err := tx.First(&trx, trxID).Error; err != nil {
+		return err
+	}
+	if err := transition(tx, trx.ID, from, StatusAvailable); err != nil {
+		return err
+	}
+	if err := wallet.Credit(tx, trx.WalletID, trx.Amount); err != nil {
+		return err
+	}
+
+	_, err := ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("EARNINGS_RELEASE:%d", trx.ID),
+		Kind:        "EARNINGS_RELEASE",
+		Description: description,
+		OrderID:     trx.OrderID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.EarningsHeld, trx.Amount),
+			ledger.Credit(ledger.WalletAccount(trx.WalletID), trx.Amount),
+		},
+	})
+	return err
+}
+
+// transition mengubah status INCOME secara atomik (hanya kalau statusnya masih `from`)
+func transition(tx *gorm.DB, trxID uint64, from, to string) error {
+	res := tx.Model(&models.WalletTransaction{}).
+		Where("id = ? AND type = ? AND status = ?", trxID, "INCOME", from).
+		Update("status", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// heldIncome mencari INCOME order dengan status tertentu
+func heldIncome(db *gorm.DB, orderID uint64, status string) (models.WalletTransaction, error) {
+	var trx models.WalletTransaction
+	err := db.Where("order_id = ? AND type = ? AND status = ?", orderID, "INCOME", status).First(&trx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return trx, ErrNotHeld
+	}
+	return trx, err
+}
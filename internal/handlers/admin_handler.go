@@ -1,20 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"homecare-backend/internal/config"
 	"homecare-backend/internal/models"
+	"homecare-backend/internal/payout"
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/money"
 	"homecare-backend/pkg/utils"
 	"net/http"
 
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetDashboardStats menampilkan ringkasan performa bisnis
 func GetDashboardStats(c *gin.Context) {
-	var totalIncome float64
+	var totalIncome money.Money
 	var activePartners int64
 	var ongoingOrders int64
 	var pendingWithdrawals int64
@@ -25,7 +30,7 @@ func GetDashboardStats(c *gin.Context) {
 	// Cara simple: Hitung saldo wallet Admin (Nanti perlu dibuat wallet khusus admin)
 	// Atau hitung manual dari transaksi order:
 	type Result struct {
-		Total float64
+		Total money.Money
 	}
 	var res Result
 	// Query total_amount dari order completed (Ini Gross Revenue)
@@ -40,7 +45,7 @@ func GetDashboardStats(c *gin.Context) {
 	config.DB.Model(&models.PartnerProfile{}).Where("is_active = ?", true).Count(&activePartners)
 
 	// 3. Order Sedang Berjalan
-	config.DB.Model(&models.Order{}).Where("status IN ('PAID', 'ASSIGNED', 'ON_DUTY')").Count(&ongoingOrders)
+	config.DB.Model(&models.Order{}).Where("status IN ('PAID', 'ASSIGNED', 'EN_ROUTE', 'ON_DUTY')").Count(&ongoingOrders)
 
 	// 4. Request Withdraw Pending
 	config.DB.Model(&models.WalletTransaction{}).Where("type = ? AND status = ?", "WITHDRAWAL", "PENDING").Count(&pendingWithdrawals)
@@ -92,10 +97,14 @@ func GetAllOrders(c *gin.Context) {
 // CreateService menambahkan layanan baru
 func CreateService(c *gin.Context) {
 	var input struct {
-		Name        string  `json:"name" binding:"required"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price" binding:"required"`
-		AdminFee    float64 `json:"admin_fee"`
+		Name        string      `json:"name" binding:"required"`
+		Description string      `json:"description"`
+		Price       money.Money `json:"price" binding:"required,gt=0"`
+		AdminFee    money.Money `json:"admin_fee" binding:"min=0"`
+
+		// Syarat Mitra (Opsional)
+		MinExperienceYears int      `json:"min_experience_years" binding:"min=0"`
+		CompetencyIDs      []uint64 `json:"competency_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -103,11 +112,19 @@ func CreateService(c *gin.Context) {
 		return
 	}
 
+	competencies, err := findCompetencies(input.CompetencyIDs)
+	if err != nil {
+		respondCompetencyError(c, err)
+		return
+	}
+
 	service := models.Service{
-		Name:        input.Name,
-		Description: input.Description,
-		Price:       input.Price,
-		AdminFee:    input.AdminFee,
+		Name:                 input.Name,
+		Description:          input.Description,
+		Price:                input.Price,
+		AdminFee:             input.AdminFee,
+		MinExperienceYears:   input.MinExperienceYears,
+		RequiredCompetencies: competencies,
 	}
 
 	if err := config.DB.Create(&service).Error; err != nil {
@@ -145,10 +162,14 @@ func DeleteService(c *gin.Context) {
 func UpdateService(c *gin.Context) {
 	id := c.Param("id")
 	var input struct {
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price"`
-		AdminFee    float64 `json:"admin_fee"`
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Price       money.Money `json:"price"`
+		AdminFee    money.Money `json:"admin_fee" binding:"min=0"`
+
+		// Pointer/nil = tidak diubah
+		MinExperienceYears *int     `json:"min_experience_years" binding:"omitempty,min=0"`
+		CompetencyIDs      []uint64 `json:"competency_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -174,25 +195,93 @@ func UpdateService(c *gin.Context) {
 	}
 	// AdminFee boleh 0, jadi kita tidak cek > 0 (tapi cek input logic di frontend)
 	service.AdminFee = input.AdminFee
+	if input.MinExperienceYears != nil {
+		service.MinExperienceYears = *input.MinExperienceYears
+	}
+
+	// Validasi kompetensi dulu: kalau ada ID yang salah, perubahan lain juga tidak boleh tersimpan
+	var competencies []models.Competency
+	if input.CompetencyIDs != nil {
+		var err error
+		competencies, err = findCompetencies(input.CompetencyIDs)
+		if err != nil {
+			respondCompetencyError(c, err)
+			return
+		}
+	}
 
-	config.DB.Save(&service)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&service).Error; err != nil {
+			return err
+		}
+		// Ganti daftar kompetensi wajib kalau dikirim (array kosong = hapus semua syarat)
+		if input.CompetencyIDs != nil {
+			return tx.Model(&service).Association("RequiredCompetencies").Replace(competencies)
+		}
+		return nil
+	})
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memperbarui layanan", err.Error())
+		return
+	}
+	config.DB.Preload("RequiredCompetencies").First(&service, service.ID)
 
 	utils.APIResponse(c, http.StatusOK, true, "Data Layanan Diperbarui", service)
 }
 
+var errCompetencyNotFound = errors.New("Ada ID kompetensi yang tidak ditemukan")
+
+// findCompetencies memastikan semua ID kompetensi valid sebelum dipasang ke layanan (ID dobel dihitung sekali)
+func findCompetencies(ids []uint64) ([]models.Competency, error) {
+	competencies := []models.Competency{}
+	if len(ids) == 0 {
+		return competencies, nil
+	}
+
+	unique := make([]uint64, 0, len(ids))
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	if err := config.DB.Where("id IN ?", unique).Find(&competencies).Error; err != nil {
+		return nil, err
+	}
+	if len(competencies) != len(unique) {
+		return nil, errCompetencyNotFound
+	}
+	return competencies, nil
+}
+
+// respondCompetencyError: ID salah = 400, error database = 500
+func respondCompetencyError(c *gin.Context, err error) {
+	if errors.Is(err, errCompetencyNotFound) {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengecek kompetensi", err.Error())
+}
+
 // === FITUR ADMIN OPS ===
 
-// GetPendingPartners melihat daftar mitra yang belum diverifikasi
+// GetPendingPartners melihat daftar mitra yang menunggu/sedang direview
 func GetPendingPartners(c *gin.Context) {
 	var partners []models.PartnerProfile
 
-	// Cari yang is_active = false (atau nanti bisa tambah kolom is_verified di PartnerProfile)
-	// Asumsi: Kalau user.is_verified = false, berarti butuh approval
-	config.DB.
-		Preload("User").
-		Joins("JOIN users ON users.id = partner_profiles.user_id").
-		Where("users.is_verified = ?", false).
-		Find(&partners)
+	// Filter status (optional) ?status=SUBMITTED atau ?status=IN_REVIEW
+	status := c.Query("status")
+
+	query := config.DB.Preload("User")
+	if status != "" {
+		query = query.Where("verification_status = ?", status)
+	} else {
+		query = query.Where("verification_status IN ?", []string{models.PartnerSubmitted, models.PartnerInReview})
+	}
+
+	query.Order("id asc").Find(&partners) // Yang daftar duluan, direview duluan
 
 	utils.APIResponse(c, http.StatusOK, true, "Daftar Mitra Pending", partners)
 }
@@ -217,11 +306,15 @@ func GetAllPartners(c *gin.Context) {
 	utils.APIResponse(c, http.StatusOK, true, "Data Semua Mitra", partners)
 }
 
-// VerifyPartner menyetujui atau menolak mitra
+// VerifyPartner memproses verifikasi mitra sesuai alur status:
+// review (SUBMITTED -> IN_REVIEW), approve, reject, suspend, reinstate (SUSPENDED -> APPROVED)
 func VerifyPartner(c *gin.Context) {
+	adminID, _ := c.Get("userID")
 	partnerID := c.Param("id")
 	var input struct {
-		Action string `json:"action" binding:"required,oneof=approve reject"`
+		Action string `json:"action" binding:"required,oneof=review approve reject suspend reinstate"`
+		Reason string `json:"reason"` // Wajib untuk reject & suspend (dikirim ke Mitra)
+		Notes  string `json:"notes"`  // Catatan internal reviewer
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -235,17 +328,39 @@ func VerifyPartner(c *gin.Context) {
 		return
 	}
 
-	if input.Action == "approve" {
-		// Update User jadi Verified & Partner jadi Active
-		config.DB.Model(&profile).Update("is_active", true)
-		config.DB.Model(&profile.User).Update("is_verified", true)
+	targetStatus := map[string]string{
+		"review":    models.PartnerInReview,
+		"approve":   models.PartnerApproved,
+		"reject":    models.PartnerRejected,
+		"suspend":   models.PartnerSuspended,
+		"reinstate": models.PartnerApproved,
+	}[input.Action]
 
-		utils.APIResponse(c, http.StatusOK, true, "Mitra Berhasil Diverifikasi", nil)
-	} else {
-		// Kalau Reject, mungkin kirim email notifikasi (Nanti)
-		// Untuk sekarang biarkan saja atau hapus
-		utils.APIResponse(c, http.StatusOK, true, "Mitra Ditolak", nil)
+	if (input.Action == "reject" || input.Action == "suspend") && strings.TrimSpace(input.Reason) == "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Alasan (reason) wajib diisi", nil)
+		return
+	}
+
+	if targetStatus == models.PartnerApproved {
+		// Wajib ada bukti: STR, SIP, KTP sudah di-review & masih berlaku
+		if missing := missingPartnerDocuments(profile.ID); len(missing) > 0 {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Dokumen wajib belum lengkap/belum disetujui: "+strings.Join(missing, ", "), gin.H{
+				"missing_documents": missing,
+			})
+			return
+		}
+	}
+
+	reviewer := adminID.(uint64)
+	if err := transitionPartner(&profile, targetStatus, &reviewer, input.Reason, input.Notes); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
 	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Verifikasi Mitra menjadi "+profile.VerificationStatus, gin.H{
+		"partner_id":          profile.ID,
+		"verification_status": profile.VerificationStatus,
+	})
 }
 
 // === FITUR FINANCE ===
@@ -259,6 +374,7 @@ func GetAllWithdrawals(c *gin.Context) {
 
 	// Query Dasar: Ambil semua transaksi tipe WITHDRAWAL
 	query := config.DB.
+		Unscoped().Preload("BankAccount"). // Rekening tujuan transfer (tetap tampil walau sudah dihapus Mitra)
 		Where("type = ?", "WITHDRAWAL").
 		Order("created_at desc") // Urutkan dari terbaru
 
@@ -275,11 +391,12 @@ func GetAllWithdrawals(c *gin.Context) {
 	utils.APIResponse(c, http.StatusOK, true, "Daftar Penarikan Dana", withdrawals)
 }
 
-// ApproveWithdrawal menyetujui transfer
+// ApproveWithdrawal menyetujui / menolak penarikan.
+// Approve -> PROCESSING lalu transfer dikirim otomatis ke provider disbursement (hasil final lewat callback).
 func ApproveWithdrawal(c *gin.Context) {
-	trxID := c.Param("id")
 	var input struct {
 		Action string `json:"action" binding:"required,oneof=approve reject"`
+		Reason string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -291,84 +408,89 @@ func ApproveWithdrawal(c *gin.Context) {
 	action := strings.ToLower(input.Action)
 
 	var trx models.WalletTransaction
-	if err := config.DB.First(&trx, trxID).Error; err != nil {
+	if err := config.DB.Where("type = ?", "WITHDRAWAL").First(&trx, c.Param("id")).Error; err != nil {
 		utils.APIResponse(c, http.StatusNotFound, false, "Transaksi tidak ditemukan", nil)
 		return
 	}
 
-	if trx.Status != "PENDING" {
+	if trx.Status != payout.StatusPending {
 		utils.APIResponse(c, http.StatusBadRequest, false, "Transaksi sudah diproses sebelumnya", nil)
 		return
 	}
 
-	tx := config.DB.Begin()
-
-	if action == "approve" {
-		trx.Status = "SUCCESS"
-		// Di sini nanti integrasi Disbursement Xendit/Midtrans
-	} else if action == "reject" {
-		trx.Status = "FAILED"
+	// Penarikan yang sudah masuk batch diproses lewat batch (atau dikeluarkan dulu dari batch DRAFT)
+	if trx.PayoutRunID != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, fmt.Sprintf("Penarikan ini masuk batch #%d, proses lewat batch pencairan", *trx.PayoutRunID), nil)
+		return
+	}
 
-		// KEMBALIKAN SALDO KE DOMPET MITRA
-		var wallet models.Wallet
-		if err := tx.First(&wallet, trx.WalletID).Error; err != nil {
-			tx.Rollback()
-			utils.APIResponse(c, http.StatusInternalServerError, false, "Wallet mitra tidak ditemukan", nil)
+	if action == "reject" {
+		// Tolak: saldo dikembalikan + ledger WITHDRAWAL_REJECT + notif ke Mitra
+		err := payout.Reject(config.DB, trx.ID, input.Reason)
+		if errors.Is(err, payout.ErrAlreadyProcessed) {
+			utils.APIResponse(c, http.StatusConflict, false, "Transaksi sudah diproses sebelumnya", nil)
 			return
 		}
-
-		wallet.Balance += trx.Amount
-		if err := tx.Save(&wallet).Error; err != nil {
-			tx.Rollback()
-			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal refund saldo", err.Error())
+		if err != nil {
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menolak penarikan", err.Error())
 			return
 		}
-	} else {
-		// Jaga-jaga kalau lolos binding tapi bukan approve/reject
-		tx.Rollback()
-		utils.APIResponse(c, http.StatusBadRequest, false, "Aksi tidak dikenali", nil)
+		utils.APIResponse(c, http.StatusOK, true, "Status Penarikan Berhasil Diupdate menjadi FAILED", nil)
 		return
 	}
 
-	// PERBAIKAN UTAMA: Cek Error saat Save Status Transaksi!
-	if err := tx.Save(&trx).Error; err != nil {
-		tx.Rollback()
-		// Error ini biasanya karena ENUM di database tidak cocok dengan string "SUCCESS"/"FAILED"
+	// Approve: kunci PENDING -> PROCESSING, kalau dua Finance memproses bersamaan hanya satu yang lolos
+	err := payout.Approve(config.DB, trx.ID)
+	if errors.Is(err, payout.ErrAlreadyProcessed) {
+		utils.APIResponse(c, http.StatusConflict, false, "Transaksi sudah diproses sebelumnya", nil)
+		return
+	}
+	if err != nil {
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update status transaksi: "+err.Error(), nil)
 		return
 	}
 
-	tx.Commit()
-
-	utils.APIResponse(c, http.StatusOK, true, "Status Penarikan Berhasil Diupdate menjadi "+trx.Status, nil)
-
-	// KIRIM NOTIFIKASI KE MITRA
-	// Kita perlu ambil User ID dari Wallet untuk dapat FCM Token
-	var wallet models.Wallet
-	if err := config.DB.Preload("User").First(&wallet, trx.WalletID).Error; err == nil {
-		if wallet.User.FCMToken != "" {
-			title := ""
-			body := ""
-			typeNotif := ""
-
-			if trx.Status == "SUCCESS" {
-				title = "Penarikan Dana Berhasil! 💰"
-				body = fmt.Sprintf("Permintaan penarikan dana sebesar Rp %.0f telah disetujui dan ditransfer.", trx.Amount)
-				typeNotif = "withdrawal_approved"
-			} else if trx.Status == "FAILED" {
-				title = "Penarikan Dana Ditolak ❌"
-				body = "Maaf, permintaan penarikan dana Anda ditolak. Saldo telah dikembalikan."
-				typeNotif = "withdrawal_rejected"
-			}
-
-			if title != "" {
-				utils.SendNotification(
-					wallet.User.FCMToken,
-					title,
-					body,
-					map[string]string{"transaction_id": fmt.Sprintf("%d", trx.ID), "type": typeNotif},
-				)
-			}
-		}
+	// Transfer dikirim di background (bisa makan waktu), hasilnya datang lewat callback provider
+	go payout.Execute(config.DB, disbursement.Default, trx.ID)
+
+	utils.APIResponse(c, http.StatusOK, true, "Penarikan disetujui dan sedang diproses transfer", gin.H{
+		"id":     trx.ID,
+		"status": payout.StatusProcessing,
+	})
+}
+
+// SettleWithdrawal menyelesaikan manual penarikan PROCESSING yang statusnya macet
+// (misal provider timeout, Finance sudah cek sendiri di dashboard provider)
+func SettleWithdrawal(c *gin.Context) {
+	var input struct {
+		Status string `json:"status" binding:"required,oneof=SUCCESS FAILED"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
 	}
+
+	if input.Status == payout.StatusFailed && strings.TrimSpace(input.Reason) == "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Alasan gagal wajib diisi", nil)
+		return
+	}
+
+	var trx models.WalletTransaction
+	if err := config.DB.Where("type = ?", "WITHDRAWAL").First(&trx, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Transaksi tidak ditemukan", nil)
+		return
+	}
+
+	err := payout.Settle(config.DB, trx.ID, input.Status == payout.StatusSuccess, input.Reason)
+	if errors.Is(err, payout.ErrAlreadyProcessed) {
+		utils.APIResponse(c, http.StatusConflict, false, "Hanya penarikan berstatus PROCESSING yang bisa diselesaikan", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyelesaikan penarikan", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Penarikan Berhasil Diupdate menjadi "+input.Status, nil)
 }
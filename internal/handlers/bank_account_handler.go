@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/payout"
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === FITUR MITRA ===
+
+// GetSupportedBanks daftar bank yang bisa dipakai untuk pencairan
+func GetSupportedBanks(c *gin.Context) {
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Bank", disbursement.SupportedBanks)
+}
+
+// RegisterBankAccount mendaftarkan rekening pencairan.
+// Rekening langsung dicek ke bank (inquiry) untuk mengambil nama pemilik asli, lalu menunggu verifikasi Finance.
+func RegisterBankAccount(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.CreateBankAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input rekening tidak valid", err.Error())
+		return
+	}
+
+	bankCode := strings.ToLower(strings.TrimSpace(input.BankCode))
+	if _, ok := disbursement.SupportedBanks[bankCode]; !ok {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Bank tidak didukung", nil)
+		return
+	}
+
+	// 1. Inquiry nama pemilik rekening ke provider
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	// (Provider yang tidak mendukung inquiry dilewati, nama diperiksa manual oleh Finance)
+	info, err := disbursement.Default.InquireAccount(ctx, bankCode, input.AccountNumber)
+	if errors.Is(err, disbursement.ErrAccountNotFound) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Nomor rekening tidak ditemukan di bank tersebut", nil)
+		return
+	}
+	if err != nil && !errors.Is(err, disbursement.ErrInquiryNotSupported) {
+		log.Printf("[BankAccount] Inquiry %s gagal: %v", disbursement.Default.Name(), err)
+		utils.APIResponse(c, http.StatusBadGateway, false, "Layanan cek rekening sedang gangguan, coba lagi nanti", nil)
+		return
+	}
+
+	// 2. Simpan (kalau pernah didaftarkan lalu dihapus, pakai ulang datanya)
+	var account models.BankAccount
+	config.DB.Unscoped().
+		Where("user_id = ? AND bank_code = ? AND account_number = ?", userID, bankCode, input.AccountNumber).
+		First(&account)
+
+	if account.ID != 0 && !account.DeletedAt.Valid {
+		utils.APIResponse(c, http.StatusConflict, false, "Rekening ini sudah terdaftar", account)
+		return
+	}
+
+	account.UserID = userID.(uint64)
+	account.BankCode = bankCode
+	account.AccountNumber = input.AccountNumber
+	account.HolderName = strings.TrimSpace(input.HolderName)
+	account.InquiryName = info.AccountName
+	account.NameMatched = disbursement.NamesMatch(account.HolderName, info.AccountName)
+	account.Status = "PENDING"
+	account.ReviewNote = ""
+	account.VerifiedBy = nil
+	account.VerifiedAt = nil
+	account.DeletedAt.Valid = false
+
+	if err := config.DB.Unscoped().Save(&account).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan rekening", err.Error())
+		return
+	}
+
+	msg := "Rekening terdaftar. Menunggu verifikasi Finance."
+	if !account.NameMatched && info.AccountName != "" {
+		msg = fmt.Sprintf("Rekening terdaftar, tapi nama di bank (%s) berbeda dengan yang diisi. Finance akan memeriksa.", info.AccountName)
+	}
+	utils.APIResponse(c, http.StatusCreated, true, msg, account)
+}
+
+// GetMyBankAccounts daftar rekening milik Mitra
+func GetMyBankAccounts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var accounts []models.BankAccount
+	config.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&accounts)
+
+	utils.APIResponse(c, http.StatusOK, true, "Rekening Saya", accounts)
+}
+
+// DeleteMyBankAccount menghapus rekening (tidak boleh kalau masih dipakai penarikan yang belum selesai)
+func DeleteMyBankAccount(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var account models.BankAccount
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&account).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Rekening tidak ditemukan", nil)
+		return
+	}
+
+	var pending int64
+	config.DB.Model(&models.WalletTransaction{}).
+		Where("bank_account_id = ? AND status IN ?", account.ID, []string{"PENDING", "PROCESSING"}).
+		Count(&pending)
+	if pending > 0 {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Rekening masih dipakai penarikan yang sedang diproses", nil)
+		return
+	}
+
+	if err := config.DB.Delete(&account).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghapus rekening", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Rekening Dihapus", nil)
+}
+
+// === FITUR FINANCE ===
+
+// GetBankAccounts daftar rekening Mitra (filter ?status=PENDING)
+func GetBankAccounts(c *gin.Context) {
+	query := config.DB.Preload("User").Order("created_at asc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var accounts []models.BankAccount
+	query.Find(&accounts)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Rekening Mitra", accounts)
+}
+
+// VerifyBankAccount menyetujui / menolak rekening Mitra
+func VerifyBankAccount(c *gin.Context) {
+	financeID, _ := c.Get("userID")
+
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=approve reject"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	var account models.BankAccount
+	if err := config.DB.Preload("User").First(&account, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Rekening tidak ditemukan", nil)
+		return
+	}
+
+	// Nama beda dengan data bank wajib diberi catatan alasan kalau tetap di-approve
+	if input.Action == "approve" && !account.NameMatched && strings.TrimSpace(input.Note) == "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Nama pemilik tidak cocok dengan data bank. Isi catatan alasan persetujuan.", nil)
+		return
+	}
+
+	reviewer := financeID.(uint64)
+	now := time.Now()
+	account.ReviewNote = input.Note
+	account.VerifiedBy = &reviewer
+	account.VerifiedAt = &now
+	account.Status = "VERIFIED"
+	if input.Action == "reject" {
+		account.Status = "REJECTED"
+	}
+
+	if err := config.DB.Omit("User").Save(&account).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update rekening", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Rekening menjadi "+account.Status, account)
+
+	// Notifikasi ke Mitra
+	if account.User != nil && account.User.FCMToken != "" {
+		title := "Rekening Terverifikasi ✅"
+		body := fmt.Sprintf("Rekening %s %s sudah bisa dipakai untuk penarikan dana.", strings.ToUpper(account.BankCode), account.AccountNumber)
+		if account.Status == "REJECTED" {
+			title = "Rekening Ditolak ❌"
+			body = fmt.Sprintf("Rekening %s %s ditolak. Catatan: %s", strings.ToUpper(account.BankCode), account.AccountNumber, input.Note)
+		}
+		utils.SendNotification(account.User.FCMToken, title, body, map[string]string{
+			"bank_account_id": fmt.Sprintf("%d", account.ID),
+			"type":            "bank_account_review",
+		})
+	}
+}
+
+// === CALLBACK PROVIDER ===
+
+// HandleDisbursementCallback menerima status akhir transfer dari provider disbursement (Iris/Xendit).
+// Signature/token dicek oleh provider masing-masing; callback yang dikirim ulang aman (idempoten).
+func HandleDisbursementCallback(c *gin.Context) {
+	result, err := disbursement.Default.ParseCallback(c.Request)
+	if err != nil {
+		log.Printf("[Disbursement] Callback ditolak: %v", err)
+		utils.APIResponse(c, http.StatusUnauthorized, false, "Callback tidak valid", nil)
+		return
+	}
+
+	trxID, err := payout.HandleCallback(config.DB, result)
+	if errors.Is(err, payout.ErrNotFound) {
+		utils.APIResponse(c, http.StatusNotFound, false, "Penarikan tidak ditemukan", nil)
+		return
+	}
+	if errors.Is(err, payout.ErrAlreadyProcessed) {
+		// Provider kirim ulang callback yang sama -> cukup balas OK biar tidak retry terus
+		utils.APIResponse(c, http.StatusOK, true, "Callback sudah diproses", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("[Disbursement] Gagal proses callback penarikan %d: %v", trxID, err)
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memproses callback", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Callback diterima", nil)
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"homecare-backend/internal/commission"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCommissionRules menampilkan semua aturan bagi hasil (Finance)
+func GetCommissionRules(c *gin.Context) {
+	query := config.DB.Preload("Service").Order("effective_from desc, id desc")
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var rules []models.CommissionRule
+	query.Find(&rules)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Aturan Bagi Hasil", rules)
+}
+
+// CreateCommissionRule membuat aturan bagi hasil baru
+func CreateCommissionRule(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.CommissionRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input aturan tidak lengkap", err.Error())
+		return
+	}
+
+	rule, msg := buildCommissionRule(input)
+	if msg != "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, msg, nil)
+		return
+	}
+	rule.CreatedBy = userID.(uint64)
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan aturan", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Aturan Bagi Hasil Dibuat", rule)
+}
+
+// UpdateCommissionRule mengubah aturan yang ada.
+// Catatan: transaksi lama tetap menyimpan persentase saat itu, jadi aman diubah.
+// Untuk perubahan tarif ke depan lebih baik buat rule baru dengan effective_from baru.
+func UpdateCommissionRule(c *gin.Context) {
+	var existing models.CommissionRule
+	if err := config.DB.First(&existing, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Aturan tidak ditemukan", nil)
+		return
+	}
+
+	var input models.CommissionRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input aturan tidak lengkap", err.Error())
+		return
+	}
+
+	rule, msg := buildCommissionRule(input)
+	if msg != "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, msg, nil)
+		return
+	}
+	if input.EffectiveFrom == nil {
+		rule.EffectiveFrom = existing.EffectiveFrom // Jangan geser tanggal mulai kalau tidak dikirim
+	}
+	rule.ID = existing.ID
+	rule.IsActive = existing.IsActive
+	rule.CreatedBy = existing.CreatedBy
+	rule.CreatedAt = existing.CreatedAt
+
+	if err := config.DB.Save(&rule).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update aturan", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Aturan Bagi Hasil Diperbarui", rule)
+}
+
+// DeactivateCommissionRule menonaktifkan aturan (tidak dihapus karena direferensikan WalletTransaction)
+func DeactivateCommissionRule(c *gin.Context) {
+	res := config.DB.Model(&models.CommissionRule{}).Where("id = ?", c.Param("id")).Update("is_active", false)
+	if res.Error != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menonaktifkan aturan", nil)
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.APIResponse(c, http.StatusNotFound, false, "Aturan tidak ditemukan", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Aturan Bagi Hasil Dinonaktifkan", nil)
+}
+
+// PreviewCommission mensimulasikan bagi hasil untuk layanan/tier tertentu.
+// Kalau `proposed_rule` dikirim, dibandingkan dengan kondisi sekarang + dampaknya ke order COMPLETED N hari terakhir.
+func PreviewCommission(c *gin.Context) {
+	var input struct {
+		ServiceID    uint                        `json:"service_id" binding:"required"`
+		Tier         string                      `json:"tier" binding:"omitempty,oneof=BRONZE SILVER GOLD"`
+		Amount       money.Money                 `json:"amount"` // Kosong = harga layanan + admin fee
+		At           *time.Time                  `json:"at"`     // Kosong = sekarang
+		ProposedRule *models.CommissionRuleInput `json:"proposed_rule"`
+		ImpactDays   int                         `json:"impact_days"` // Default 30
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input simulasi salah", err.Error())
+		return
+	}
+
+	var service models.Service
+	if err := config.DB.First(&service, input.ServiceID).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Layanan tidak ditemukan", nil)
+		return
+	}
+
+	tier := input.Tier
+	if tier == "" {
+		tier = models.TierBronze
+	}
+	at := time.Now()
+	if input.At != nil {
+		at = *input.At
+	}
+	amount := input.Amount
+	if amount <= 0 {
+		amount = service.Price + service.AdminFee
+	}
+
+	rules, err := commission.ActiveRules(config.DB, at)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengambil aturan", nil)
+		return
+	}
+
+	current := commission.Calculate(commission.Pick(rules, service.ID, tier, at), tier, amount, service.AdminFee)
+	result := gin.H{"current": current}
+
+	// Simulasi rule usulan (belum disimpan)
+	if input.ProposedRule != nil {
+		proposed, msg := buildCommissionRule(*input.ProposedRule)
+		if msg != "" {
+			utils.APIResponse(c, http.StatusBadRequest, false, msg, nil)
+			return
+		}
+		if input.ProposedRule.EffectiveFrom == nil {
+			proposed.EffectiveFrom = at
+		}
+		withProposed := append(append([]models.CommissionRule{}, rules...), proposed)
+
+		result["proposed"] = commission.Calculate(commission.Pick(withProposed, service.ID, tier, at), tier, amount, service.AdminFee)
+		result["impact"] = commissionImpact(rules, withProposed, proposed, at, input.ImpactDays)
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Simulasi Bagi Hasil", result)
+}
+
+// commissionImpact menghitung ulang order COMPLETED dalam N hari terakhir dengan rule lama vs usulan
+// (seolah-olah order tersebut selesai di waktu `at`)
+func commissionImpact(current, withProposed []models.CommissionRule, proposed models.CommissionRule, at time.Time, days int) gin.H {
+	if days <= 0 {
+		days = 30
+	}
+
+	query := config.DB.Preload("Service").Preload("PartnerProfile").
+		Where("status = ? AND created_at >= ?", "COMPLETED", time.Now().AddDate(0, 0, -days))
+	if proposed.ServiceID != nil {
+		query = query.Where("service_id = ?", *proposed.ServiceID)
+	}
+
+	var orders []models.Order
+	query.Find(&orders)
+
+	affected := 0
+	var currentTotal, proposedTotal money.Money
+	for _, order := range orders {
+		if order.Service == nil || order.PartnerProfile == nil {
+			continue
+		}
+		tier := order.PartnerProfile.Tier
+		before := commission.Calculate(commission.Pick(current, order.ServiceID, tier, at), tier, order.TotalAmount, order.Service.AdminFee)
+		after := commission.Calculate(commission.Pick(withProposed, order.ServiceID, tier, at), tier, order.TotalAmount, order.Service.AdminFee)
+
+		currentTotal += before.PartnerShare
+		proposedTotal += after.PartnerShare
+		if before.PartnerPercent != after.PartnerPercent {
+			affected++
+		}
+	}
+
+	return gin.H{
+		"days":                   days,
+		"orders":                 len(orders),
+		"affected_orders":        affected,
+		"current_partner_total":  currentTotal,
+		"proposed_partner_total": proposedTotal,
+		"difference":             proposedTotal - currentTotal, // Positif = bagian Mitra naik (pendapatan platform turun)
+	}
+}
+
+// buildCommissionRule validasi input & ubah jadi model. Return pesan error kalau tidak valid.
+func buildCommissionRule(input models.CommissionRuleInput) (models.CommissionRule, string) {
+	if input.ServiceID != nil {
+		var count int64
+		config.DB.Model(&models.Service{}).Where("id = ?", *input.ServiceID).Count(&count)
+		if count == 0 {
+			return models.CommissionRule{}, "Layanan tidak ditemukan"
+		}
+	}
+
+	from := time.Now()
+	if input.EffectiveFrom != nil {
+		from = *input.EffectiveFrom
+	}
+	if input.EffectiveTo != nil && !input.EffectiveTo.After(from) {
+		return models.CommissionRule{}, "effective_to harus setelah effective_from"
+	}
+
+	return models.CommissionRule{
+		Name:           input.Name,
+		ServiceID:      input.ServiceID,
+		Tier:           input.Tier,
+		PartnerPercent: input.PartnerPercent,
+		EffectiveFrom:  from,
+		EffectiveTo:    input.EffectiveTo,
+		IsActive:       true,
+	}, ""
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/search"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCompetencies menampilkan master data kompetensi (Publik)
+func GetCompetencies(c *gin.Context) {
+	var competencies []models.Competency
+	config.DB.Order("name asc").Find(&competencies)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Kompetensi", competencies)
+}
+
+// === FITUR MITRA ===
+
+// GetMyCompetencies melihat daftar keahlian yang sudah didaftarkan Mitra
+func GetMyCompetencies(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	var competencies []models.PartnerCompetency
+	config.DB.Preload("Competency").Where("partner_id = ?", profile.ID).Find(&competencies)
+
+	utils.APIResponse(c, http.StatusOK, true, "Kompetensi Saya", competencies)
+}
+
+// DeclareCompetency mendaftarkan keahlian baru (Status PENDING sampai diverifikasi Admin)
+func DeclareCompetency(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var input models.DeclareCompetencyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input tidak valid", err.Error())
+		return
+	}
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan. Harap lengkapi profil dulu.", nil)
+		return
+	}
+
+	var competency models.Competency
+	if err := config.DB.First(&competency, input.CompetencyID).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Kompetensi tidak ditemukan", nil)
+		return
+	}
+
+	// Kalau sudah pernah didaftarkan, update sertifikatnya & minta verifikasi ulang
+	var claim models.PartnerCompetency
+	err := config.DB.Where("partner_id = ? AND competency_id = ?", profile.ID, competency.ID).First(&claim).Error
+	if err == nil {
+		claim.CertificateNo = input.CertificateNo
+		claim.CertificateURL = input.CertificateURL
+		claim.Status = "PENDING"
+		claim.ReviewNote = ""
+		claim.VerifiedBy = nil
+		claim.VerifiedAt = nil
+		if err := config.DB.Save(&claim).Error; err != nil {
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update kompetensi", err.Error())
+			return
+		}
+	} else {
+		claim = models.PartnerCompetency{
+			PartnerID:      profile.ID,
+			CompetencyID:   competency.ID,
+			CertificateNo:  input.CertificateNo,
+			CertificateURL: input.CertificateURL,
+			Status:         "PENDING",
+		}
+		if err := config.DB.Create(&claim).Error; err != nil {
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan kompetensi", err.Error())
+			return
+		}
+	}
+
+	claim.Competency = &competency
+	utils.APIResponse(c, http.StatusCreated, true, "Kompetensi terdaftar. Menunggu verifikasi Admin.", claim)
+}
+
+// DeleteMyCompetency menghapus klaim keahlian milik Mitra
+func DeleteMyCompetency(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+	claimID := c.Param("id")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	res := config.DB.Where("id = ? AND partner_id = ?", claimID, profile.ID).Delete(&models.PartnerCompetency{})
+	if res.Error != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghapus kompetensi", nil)
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.APIResponse(c, http.StatusNotFound, false, "Kompetensi tidak ditemukan", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Kompetensi Dihapus", nil)
+}
+
+// === FITUR ADMIN ===
+
+// CreateCompetency menambah master data kompetensi
+func CreateCompetency(c *gin.Context) {
+	var input struct {
+		Code        string `json:"code" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input kompetensi tidak lengkap", err.Error())
+		return
+	}
+
+	competency := models.Competency{
+		Code:        input.Code,
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	if err := config.DB.Create(&competency).Error; err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Kode kompetensi sudah dipakai", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Kompetensi Baru Berhasil Dibuat", competency)
+}
+
+// GetPartnerCompetencies melihat klaim keahlian satu Mitra (untuk direview)
+func GetPartnerCompetencies(c *gin.Context) {
+	partnerID := c.Param("id")
+
+	var competencies []models.PartnerCompetency
+	config.DB.Preload("Competency").Where("partner_id = ?", partnerID).Find(&competencies)
+
+	utils.APIResponse(c, http.StatusOK, true, "Kompetensi Mitra", competencies)
+}
+
+// VerifyPartnerCompetency menyetujui atau menolak klaim keahlian Mitra
+func VerifyPartnerCompetency(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+	claimID := c.Param("id")
+
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=approve reject"`
+		Note   string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	var claim models.PartnerCompetency
+	if err := config.DB.Preload("Competency").First(&claim, claimID).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Klaim kompetensi tidak ditemukan", nil)
+		return
+	}
+
+	reviewer := adminID.(uint64)
+	now := time.Now()
+	claim.ReviewNote = input.Note
+	claim.VerifiedBy = &reviewer
+	claim.VerifiedAt = &now
+
+	if input.Action == "approve" {
+		claim.Status = "VERIFIED"
+	} else {
+		claim.Status = "REJECTED"
+	}
+
+	if err := config.DB.Save(&claim).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update kompetensi", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Kompetensi menjadi "+claim.Status, claim)
+}
+
+// === HELPER ELIGIBILITY ===
+
+// checkPartnerEligibility mengecek apakah Mitra memenuhi syarat layanan:
+// izin praktik (STR/SIP) berlaku, pengalaman minimal & SEMUA kompetensi wajib sudah VERIFIED.
+// Return pesan alasan kalau tidak eligible.
+func checkPartnerEligibility(profile models.PartnerProfile, serviceID uint) (bool, string) {
+	var service models.Service
+	if err := config.DB.Preload("RequiredCompetencies").First(&service, serviceID).Error; err != nil {
+		return false, "Layanan tidak ditemukan"
+	}
+
+	if !licenseValid(profile.ID) {
+		return false, msgLicenseExpired
+	}
+
+	if profile.ExperienceYears < service.MinExperienceYears {
+		return false, fmt.Sprintf("Layanan %s butuh pengalaman minimal %d tahun", service.Name, service.MinExperienceYears)
+	}
+
+	verified := verifiedCompetencyIDs(profile.ID)
+	for _, comp := range service.RequiredCompetencies {
+		if !verified[comp.ID] {
+			return false, fmt.Sprintf("Layanan %s butuh kompetensi %s yang sudah terverifikasi", service.Name, comp.Name)
+		}
+	}
+
+	return true, ""
+}
+
+// eligibleServiceIDs mengembalikan daftar ID layanan yang boleh dikerjakan Mitra
+func eligibleServiceIDs(profile models.PartnerProfile) []uint {
+	if !licenseValid(profile.ID) {
+		return []uint{}
+	}
+
+	var services []models.Service
+	config.DB.Preload("RequiredCompetencies").Find(&services)
+
+	verified := verifiedCompetencyIDs(profile.ID)
+
+	ids := []uint{}
+	for _, service := range services {
+		if profile.ExperienceYears < service.MinExperienceYears {
+			continue
+		}
+		eligible := true
+		for _, comp := range service.RequiredCompetencies {
+			if !verified[comp.ID] {
+				eligible = false
+				break
+			}
+		}
+		if eligible {
+			ids = append(ids, service.ID)
+		}
+	}
+	return ids
+}
+
+const msgLicenseExpired = "STR/SIP Anda sudah kedaluwarsa. Upload dokumen terbaru & tunggu verifikasi Admin."
+
+// licenseValid: STR/SIP Mitra masih berlaku (aturan sama dengan search.LicenseValid).
+// Gagal baca database dianggap tidak berlaku, lebih aman menolak daripada meloloskan.
+func licenseValid(partnerID uint64) bool {
+	var count int64
+	config.DB.Model(&models.PartnerProfile{}).
+		Scopes(search.LicenseValid(time.Now())).
+		Where("partner_profiles.id = ?", partnerID).
+		Count(&count)
+	return count > 0
+}
+
+// verifiedCompetencyIDs mengambil set ID kompetensi Mitra yang sudah VERIFIED
+func verifiedCompetencyIDs(partnerID uint64) map[uint64]bool {
+	var ids []uint64
+	config.DB.Model(&models.PartnerCompetency{}).
+		Where("partner_id = ? AND status = ?", partnerID, "VERIFIED").
+		Pluck("competency_id", &ids)
+
+	verified := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		verified[id] = true
+	}
+	return verified
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"homecare-backend/internal/billing"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/payment"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR CUSTOMER (SALDO) ===
+
+// GetMyBalance menampilkan saldo customer & riwayat transaksinya (terbaru dulu, filter ?type=REFUND)
+func GetMyBalance(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var w models.Wallet
+	if err := config.DB.Where("user_id = ?", userID).First(&w).Error; err != nil {
+		// Belum pernah punya saldo
+		utils.APIResponse(c, http.StatusOK, true, "Saldo Saya", gin.H{"balance": money.Money(0), "transactions": []models.WalletTransaction{}})
+		return
+	}
+
+	query := config.DB.Where("wallet_id = ?", w.ID).Order("id desc").Limit(100)
+	if trxType := c.Query("type"); trxType != "" {
+		query = query.Where("type = ?", trxType)
+	}
+	var transactions []models.WalletTransaction
+	query.Find(&transactions)
+
+	utils.APIResponse(c, http.StatusOK, true, "Saldo Saya", gin.H{"balance": w.Balance, "transactions": transactions})
+}
+
+// TopupBalance membuat tagihan isi saldo lewat payment gateway. Saldo bertambah setelah notifikasi pembayaran masuk.
+func TopupBalance(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input struct {
+		Amount money.Money `json:"amount" binding:"required,min=10000"` // Minimal top-up 10rb
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	var customer models.User
+	config.DB.First(&customer, userID)
+
+	trx, err := billing.StartTopup(config.DB, userID.(uint64), input.Amount)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat top-up", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	charge, err := payment.Default.CreateCharge(ctx, payment.ChargeRequest{
+		OrderNo:       trx.PaymentRef,
+		Amount:        trx.Amount,
+		CustomerName:  customer.FullName,
+		CustomerEmail: customer.Email,
+		CustomerPhone: customer.Phone,
+		Items: []payment.Item{
+			{ID: "TOPUP", Name: "Top-up Saldo Homecare", Price: trx.Amount, Qty: 1},
+		},
+	})
+	if err != nil {
+		config.DB.Model(&trx).Updates(map[string]interface{}{"status": "FAILED", "failure_reason": "Gagal membuat tagihan"})
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Payment Gateway Error", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Tagihan top-up dibuat. Silakan bayar.", gin.H{
+		"transaction_id": trx.ID,
+		"order_no":       trx.PaymentRef,
+		"amount":         trx.Amount,
+		"snap_token":     charge.Token,
+		"redirect_url":   charge.RedirectURL,
+	})
+}
+
+// === FITUR FINANCE (SALDO CUSTOMER) ===
+
+// RefundOrderToWallet mengembalikan dana order yang batal / komplain dikabulkan ke saldo customer.
+// amount kosong = refund seluruh sisa hutang refund order tsb.
+func RefundOrderToWallet(c *gin.Context) {
+	var input struct {
+		Amount money.Money `json:"amount" binding:"min=0"`
+		Reason string      `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	orderID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	trx, err := billing.RefundToWallet(config.DB, orderID, input.Amount, input.Reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+	if errors.Is(err, billing.ErrNothingToRefund) || errors.Is(err, billing.ErrRefundExceeded) {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memproses refund", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Refund masuk ke saldo customer", trx)
+	notifyWalletCredit(trx, "Refund Diterima 💰", "wallet_refund")
+}
+
+// GrantCustomerPromo menambah saldo promo ke customer
+func GrantCustomerPromo(c *gin.Context) {
+	var input struct {
+		Amount      money.Money `json:"amount" binding:"required,min=1"`
+		Description string      `json:"description" binding:"required"` // Nama / alasan promo
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	var customer models.User
+	if err := config.DB.Where("id = ? AND role_id = ?", c.Param("id"), 4).First(&customer).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Customer tidak ditemukan", nil)
+		return
+	}
+
+	trx, err := billing.GrantPromo(config.DB, customer.ID, input.Amount, input.Description)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menambah saldo promo", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Saldo promo ditambahkan", trx)
+	notifyWalletCredit(trx, "Saldo Promo Masuk 🎁", "wallet_promo")
+}
+
+// notifyWalletCredit kabari customer kalau saldonya bertambah
+func notifyWalletCredit(trx models.WalletTransaction, title, typeNotif string) {
+	var w models.Wallet
+	if err := config.DB.Preload("User").First(&w, trx.WalletID).Error; err != nil || w.User.FCMToken == "" {
+		return
+	}
+	utils.SendNotification(w.User.FCMToken, title, "Saldo sebesar "+trx.Amount.String()+" sudah masuk ke dompet Anda.", map[string]string{
+		"transaction_id": strconv.FormatUint(trx.ID, 10),
+		"type":           typeNotif,
+	})
+}
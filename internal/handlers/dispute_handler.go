@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/earnings"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR CUSTOMER ===
+
+// ConfirmOrderCompletion customer mengonfirmasi layanan sudah OK -> pendapatan Mitra dilepas lebih awal
+func ConfirmOrderCompletion(c *gin.Context) {
+	order, ok := loadCompletedOrder(c)
+	if !ok {
+		return
+	}
+
+	err := earnings.ConfirmOrder(config.DB, order.ID)
+	if errors.Is(err, earnings.ErrNotHeld) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Order ini sudah dikonfirmasi atau sedang dalam komplain", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal konfirmasi order", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Terima kasih! Layanan telah dikonfirmasi.", nil)
+	notifyPartnerOfOrder(order, "Pendapatan Diteruskan 💰", fmt.Sprintf("Customer mengonfirmasi order %s. Pendapatan sudah bisa ditarik.", order.OrderNo), "earnings_released")
+}
+
+// CreateOrderDispute customer komplain atas layanan (hanya selama masa tahan pendapatan Mitra).
+// Pendapatan Mitra dari order ini dibekukan sampai Finance memutuskan.
+func CreateOrderDispute(c *gin.Context) {
+	var input models.CreateDisputeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Alasan komplain wajib diisi (minimal 10 karakter)", err.Error())
+		return
+	}
+
+	order, ok := loadCompletedOrder(c)
+	if !ok {
+		return
+	}
+
+	var dispute models.OrderDispute
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		trx, err := earnings.Freeze(tx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		dispute = models.OrderDispute{
+			OrderID:             order.ID,
+			CustomerID:          order.CustomerID,
+			WalletTransactionID: trx.ID,
+			Reason:              input.Reason,
+			Status:              models.DisputeOpen,
+		}
+		return tx.Create(&dispute).Error
+	})
+	if errors.Is(err, earnings.ErrNotHeld) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Masa komplain untuk order ini sudah lewat atau sudah ada komplain", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengajukan komplain", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Komplain diterima. Tim kami akan meninjau dalam 1x24 jam.", dispute)
+	notifyPartnerOfOrder(order, "Order Dikomplain ⚠️", fmt.Sprintf("Customer mengajukan komplain untuk order %s. Pendapatan dibekukan sementara.", order.OrderNo), "order_disputed")
+}
+
+// === FITUR FINANCE ===
+
+// GetDisputes daftar komplain (filter ?status=OPEN)
+func GetDisputes(c *gin.Context) {
+	query := config.DB.Preload("Order").Order("created_at asc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var disputes []models.OrderDispute
+	query.Find(&disputes)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Komplain", disputes)
+}
+
+// ResolveDispute memutuskan komplain:
+// PARTNER = dana dilepas ke Mitra, CUSTOMER = bagian Mitra dikembalikan ke customer (hutang refund)
+func ResolveDispute(c *gin.Context) {
+	financeID, _ := c.Get("userID")
+
+	var input models.ResolveDisputeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	var dispute models.OrderDispute
+	if err := config.DB.Preload("Order").First(&dispute, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Komplain tidak ditemukan", nil)
+		return
+	}
+
+	resolver := financeID.(uint64)
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci status: kalau dua Finance memutuskan bersamaan, hanya satu yang lolos
+		res := tx.Model(&models.OrderDispute{}).
+			Where("id = ? AND status = ?", dispute.ID, models.DisputeOpen).
+			Updates(map[string]interface{}{
+				"status":          models.DisputeResolved,
+				"resolution":      input.Resolution,
+				"resolution_note": input.Note,
+				"resolved_by":     resolver,
+				"resolved_at":     now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return earnings.ErrNotHeld
+		}
+
+		if input.Resolution == models.DisputeForPartner {
+			return earnings.Unfreeze(tx, dispute.WalletTransactionID)
+		}
+		return earnings.Reverse(tx, dispute.WalletTransactionID)
+	})
+	if errors.Is(err, earnings.ErrNotHeld) {
+		utils.APIResponse(c, http.StatusConflict, false, "Komplain sudah diputuskan sebelumnya", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memutuskan komplain", err.Error())
+		return
+	}
+
+	dispute.Status = models.DisputeResolved
+	dispute.Resolution = input.Resolution
+	dispute.ResolutionNote = input.Note
+	dispute.ResolvedBy = &resolver
+	dispute.ResolvedAt = &now
+	utils.APIResponse(c, http.StatusOK, true, "Komplain Diputuskan", dispute)
+
+	// Notifikasi ke kedua pihak
+	if dispute.Order == nil {
+		return
+	}
+	order := *dispute.Order
+	partnerMsg := fmt.Sprintf("Komplain order %s selesai. Pendapatan sudah bisa ditarik.", order.OrderNo)
+	customerMsg := fmt.Sprintf("Komplain order %s ditinjau dan tidak dapat dikabulkan. Catatan: %s", order.OrderNo, input.Note)
+	if input.Resolution == models.DisputeForCustomer {
+		partnerMsg = fmt.Sprintf("Komplain order %s dikabulkan. Pendapatan order ini dikembalikan ke customer.", order.OrderNo)
+		customerMsg = fmt.Sprintf("Komplain order %s dikabulkan. Dana akan dikembalikan.", order.OrderNo)
+	}
+	notifyPartnerOfOrder(order, "Keputusan Komplain", partnerMsg, "dispute_resolved")
+
+	var customer models.User
+	if err := config.DB.First(&customer, order.CustomerID).Error; err == nil && customer.FCMToken != "" {
+		utils.SendNotification(customer.FCMToken, "Keputusan Komplain", customerMsg, map[string]string{
+			"order_id": fmt.Sprintf("%d", order.ID),
+			"type":     "dispute_resolved",
+		})
+	}
+}
+
+// loadCompletedOrder ambil order :id milik customer yang sudah COMPLETED
+func loadCompletedOrder(c *gin.Context) (models.Order, bool) {
+	userID, _ := c.Get("userID")
+
+	var order models.Order
+	if err := config.DB.Where("id = ? AND customer_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return order, false
+	}
+	if order.Status != "COMPLETED" || order.PartnerID == nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Order belum selesai", nil)
+		return order, false
+	}
+	return order, true
+}
+
+// notifyPartnerOfOrder kirim push notif ke Mitra yang mengerjakan order
+func notifyPartnerOfOrder(order models.Order, title, body, typeNotif string) {
+	if order.PartnerID == nil {
+		return
+	}
+	var profile models.PartnerProfile
+	if err := config.DB.Preload("User").First(&profile, *order.PartnerID).Error; err != nil || profile.User.FCMToken == "" {
+		return
+	}
+	utils.SendNotification(profile.User.FCMToken, title, body, map[string]string{
+		"order_id": fmt.Sprintf("%d", order.ID),
+		"type":     typeNotif,
+	})
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/storage"
+	"homecare-backend/pkg/utils"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Batas ukuran file dokumen (5 MB)
+const maxDocumentSize = 5 << 20
+
+// Tipe file yang boleh diupload (scan PDF atau foto)
+var allowedDocumentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// === FITUR MITRA ===
+
+// UploadPartnerDocument mengupload dokumen legalitas (multipart/form-data)
+// Field: type (STR/SIP/KTP/CERTIFICATE), document_no, expires_at (YYYY-MM-DD), file
+func UploadPartnerDocument(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var input struct {
+		Type       string `form:"type" binding:"required,oneof=STR SIP KTP CERTIFICATE"`
+		DocumentNo string `form:"document_no"`
+		ExpiresAt  string `form:"expires_at"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input dokumen tidak valid", err.Error())
+		return
+	}
+
+	// 1. Validasi Masa Berlaku (STR & SIP wajib ada tanggal kedaluwarsa)
+	var expiresAt *time.Time
+	if input.ExpiresAt != "" {
+		t, err := time.ParseInLocation("2006-01-02", input.ExpiresAt, time.Local)
+		if err != nil {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Format expires_at harus YYYY-MM-DD", nil)
+			return
+		}
+		if t.Before(time.Now()) {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Dokumen sudah kedaluwarsa", nil)
+			return
+		}
+		expiresAt = &t
+	} else if input.Type == "STR" || input.Type == "SIP" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Tanggal berlaku (expires_at) wajib untuk "+input.Type, nil)
+		return
+	}
+
+	// 2. Validasi File
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "File dokumen wajib diupload", nil)
+		return
+	}
+	if fileHeader.Size > maxDocumentSize {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Ukuran file maksimal 5 MB", nil)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "File tidak bisa dibaca", nil)
+		return
+	}
+	defer file.Close()
+
+	// Deteksi tipe dari isi file (jangan percaya header Content-Type dari client)
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := allowedDocumentTypes[contentType]
+	if !ok {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Format file harus PDF, JPG, atau PNG", nil)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memproses file", nil)
+		return
+	}
+
+	// 3. Cari Profil Mitra
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan. Harap lengkapi profil dulu.", nil)
+		return
+	}
+
+	// 4. Simpan File ke Storage
+	path := fmt.Sprintf("partners/%d/%s-%d%s", profile.ID, input.Type, time.Now().UnixNano(), ext)
+	if err := storage.Default.Save(path, file); err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan file", err.Error())
+		return
+	}
+
+	// 5. Simpan Metadata ke DB
+	document := models.PartnerDocument{
+		PartnerID:   profile.ID,
+		Type:        input.Type,
+		DocumentNo:  strings.TrimSpace(input.DocumentNo),
+		FilePath:    path,
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		ExpiresAt:   expiresAt,
+		Status:      "PENDING",
+	}
+	if err := config.DB.Create(&document).Error; err != nil {
+		storage.Default.Delete(path)
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan data dokumen", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Dokumen berhasil diupload. Menunggu review Admin.", document)
+}
+
+// GetMyDocuments melihat semua dokumen milik Mitra yang login
+func GetMyDocuments(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	var documents []models.PartnerDocument
+	config.DB.Where("partner_id = ?", profile.ID).Order("created_at desc").Find(&documents)
+
+	utils.APIResponse(c, http.StatusOK, true, "Dokumen Saya", documents)
+}
+
+// DownloadMyDocument mengunduh file dokumen milik Mitra sendiri
+func DownloadMyDocument(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	var document models.PartnerDocument
+	if err := config.DB.Where("id = ? AND partner_id = ?", c.Param("id"), profile.ID).First(&document).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Dokumen tidak ditemukan", nil)
+		return
+	}
+
+	streamDocument(c, document)
+}
+
+// === FITUR ADMIN ===
+
+// GetPartnerDocuments melihat semua dokumen satu Mitra (untuk proses verifikasi)
+func GetPartnerDocuments(c *gin.Context) {
+	partnerID := c.Param("id")
+
+	var documents []models.PartnerDocument
+	config.DB.Where("partner_id = ?", partnerID).Order("created_at desc").Find(&documents)
+
+	utils.APIResponse(c, http.StatusOK, true, "Dokumen Mitra", gin.H{
+		"documents":         documents,
+		"missing_documents": missingPartnerDocuments(utils.StringToUint64(partnerID)),
+	})
+}
+
+// DownloadPartnerDocument mengunduh file dokumen Mitra
+func DownloadPartnerDocument(c *gin.Context) {
+	var document models.PartnerDocument
+	if err := config.DB.First(&document, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Dokumen tidak ditemukan", nil)
+		return
+	}
+
+	streamDocument(c, document)
+}
+
+// ReviewPartnerDocument menyetujui/menolak satu dokumen
+func ReviewPartnerDocument(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=approve reject"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	if input.Action == "reject" && strings.TrimSpace(input.Note) == "" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Alasan penolakan (note) wajib diisi", nil)
+		return
+	}
+
+	var document models.PartnerDocument
+	if err := config.DB.First(&document, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Dokumen tidak ditemukan", nil)
+		return
+	}
+
+	if document.Status != "PENDING" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Dokumen sudah direview sebelumnya", nil)
+		return
+	}
+
+	reviewer := adminID.(uint64)
+	now := time.Now()
+	document.ReviewedBy = &reviewer
+	document.ReviewedAt = &now
+	document.ReviewNote = input.Note
+
+	if input.Action == "approve" {
+		document.Status = "APPROVED"
+	} else {
+		document.Status = "REJECTED"
+	}
+
+	tx := config.DB.Begin()
+
+	if err := tx.Save(&document).Error; err != nil {
+		tx.Rollback()
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update dokumen", err.Error())
+		return
+	}
+
+	// STR yang di-approve jadi sumber data STR di profil (nomor + masa berlaku)
+	if document.Type == "STR" && document.Status == "APPROVED" {
+		if err := tx.Model(&models.PartnerProfile{}).Where("id = ?", document.PartnerID).Updates(map[string]interface{}{
+			"str_number":     document.DocumentNo,
+			"str_expires_at": document.ExpiresAt,
+		}).Error; err != nil {
+			tx.Rollback()
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update STR Mitra", err.Error())
+			return
+		}
+	}
+
+	tx.Commit()
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Dokumen menjadi "+document.Status, document)
+
+	// KIRIM NOTIFIKASI KE MITRA
+	var profile models.PartnerProfile
+	if err := config.DB.Preload("User").First(&profile, document.PartnerID).Error; err == nil {
+		if profile.User.FCMToken != "" {
+			title := "Dokumen Disetujui ✅"
+			body := fmt.Sprintf("Dokumen %s Anda telah diverifikasi Admin.", document.Type)
+			if document.Status == "REJECTED" {
+				title = "Dokumen Ditolak ❌"
+				body = fmt.Sprintf("Dokumen %s Anda ditolak: %s. Silakan upload ulang.", document.Type, document.ReviewNote)
+			}
+			utils.SendNotification(
+				profile.User.FCMToken,
+				title,
+				body,
+				map[string]string{"document_id": fmt.Sprintf("%d", document.ID), "type": "document_reviewed"},
+			)
+		}
+	}
+}
+
+// === HELPER ===
+
+// streamDocument mengirim isi file dari storage ke client
+func streamDocument(c *gin.Context, document models.PartnerDocument) {
+	file, err := storage.Default.Open(document.FilePath)
+	if err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "File dokumen tidak ditemukan di storage", nil)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", document.FileName))
+	c.DataFromReader(http.StatusOK, -1, document.ContentType, file, nil)
+}
+
+// missingPartnerDocuments mengembalikan daftar dokumen wajib yang belum APPROVED (atau sudah kedaluwarsa)
+func missingPartnerDocuments(partnerID uint64) []string {
+	var approvedTypes []string
+	config.DB.Model(&models.PartnerDocument{}).
+		Where("partner_id = ? AND status = ?", partnerID, "APPROVED").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Distinct().
+		Pluck("type", &approvedTypes)
+
+	approved := make(map[string]bool, len(approvedTypes))
+	for _, t := range approvedTypes {
+		approved[t] = true
+	}
+
+	missing := []string{}
+	for _, t := range models.RequiredPartnerDocuments {
+		if !approved[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/invoice"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === FITUR CUSTOMER ===
+
+// GetOrderInvoice data invoice order milik customer (diterbitkan otomatis saat order lunas)
+func GetOrderInvoice(c *gin.Context) {
+	inv, ok := loadMyInvoice(c)
+	if !ok {
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Invoice Order", inv)
+}
+
+// DownloadOrderInvoice unduh PDF invoice order milik customer (untuk klaim asuransi / reimburse)
+func DownloadOrderInvoice(c *gin.Context) {
+	inv, ok := loadMyInvoice(c)
+	if !ok {
+		return
+	}
+	writeInvoicePDF(c, inv)
+}
+
+// loadMyInvoice ambil invoice order :id milik customer login. Order lama yang sudah lunas tapi belum punya invoice
+// langsung diterbitkan sekarang.
+func loadMyInvoice(c *gin.Context) (*models.Invoice, bool) {
+	userID, _ := c.Get("userID")
+
+	var order models.Order
+	if err := config.DB.Where("id = ? AND customer_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return nil, false
+	}
+	return issueInvoice(c, order)
+}
+
+// === FITUR FINANCE ===
+
+// GetInvoices daftar invoice (filter ?customer_id=..&from=2026-10-01&to=2026-10-31&invoice_no=INV/2026)
+func GetInvoices(c *gin.Context) {
+	query := config.DB.Preload("Order").Order("id desc").Limit(200)
+	if customerID := c.Query("customer_id"); customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("issued_at >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("issued_at < DATE_ADD(?, INTERVAL 1 DAY)", to)
+	}
+	if no := strings.TrimSpace(c.Query("invoice_no")); no != "" {
+		query = query.Where("invoice_no LIKE ?", no+"%")
+	}
+
+	var invoices []models.Invoice
+	query.Find(&invoices)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Invoice", invoices)
+}
+
+// DownloadInvoice unduh PDF invoice mana pun
+func DownloadInvoice(c *gin.Context) {
+	var inv models.Invoice
+	if err := config.DB.Preload("Items").Preload("Order").First(&inv, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Invoice tidak ditemukan", nil)
+		return
+	}
+	writeInvoicePDF(c, &inv)
+}
+
+// IssueOrderInvoice menerbitkan (atau mengambil) invoice untuk order lunas mana pun
+func IssueOrderInvoice(c *gin.Context) {
+	var order models.Order
+	if err := config.DB.First(&order, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+	inv, ok := issueInvoice(c, order)
+	if !ok {
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Invoice Order", inv)
+}
+
+// issueInvoice terbitkan / ambil invoice order & tulis response error kalau gagal
+func issueInvoice(c *gin.Context, order models.Order) (*models.Invoice, bool) {
+	inv, err := invoice.Issue(config.DB, order.ID)
+	if errors.Is(err, invoice.ErrNotPaid) {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return nil, false
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menerbitkan invoice", err.Error())
+		return nil, false
+	}
+	inv.Order = &order
+	return inv, true
+}
+
+func writeInvoicePDF(c *gin.Context, inv *models.Invoice) {
+	filename := strings.ReplaceAll(inv.InvoiceNo, "/", "-") + ".pdf"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", "application/pdf")
+	if err := invoice.Render(inv, c.Writer); err != nil {
+		c.Status(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrialBalance menampilkan neraca saldo semua akun ledger (Finance)
+func GetTrialBalance(c *gin.Context) {
+	report, err := ledger.TrialBalance(config.DB)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung neraca saldo", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Neraca Saldo", report)
+}
+
+// GetLedgerReconciliation membandingkan saldo wallet dengan ledger
+func GetLedgerReconciliation(c *gin.Context) {
+	drifts, err := ledger.Reconcile(config.DB)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal rekonsiliasi", err.Error())
+		return
+	}
+
+	msg := "Semua saldo wallet cocok dengan ledger"
+	if len(drifts) > 0 {
+		msg = "Ditemukan wallet yang saldonya tidak cocok dengan ledger"
+	}
+	utils.APIResponse(c, http.StatusOK, true, msg, gin.H{
+		"drift_count": len(drifts),
+		"drifts":      drifts,
+	})
+}
+
+// GetLedgerTransactions melihat jurnal (filter: order_id, kind, account)
+func GetLedgerTransactions(c *gin.Context) {
+	query := config.DB.Preload("Entries.Account").Order("id desc").Limit(100)
+
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if code := c.Query("account"); code != "" {
+		query = query.Where(`id IN (
+			SELECT e.transaction_id FROM ledger_entries e
+			JOIN ledger_accounts a ON a.id = e.account_id
+			WHERE a.code = ?
+		)`, code)
+	}
+
+	var transactions []models.LedgerTransaction
+	query.Find(&transactions)
+
+	utils.APIResponse(c, http.StatusOK, true, "Jurnal Ledger", transactions)
+}
+
+// BackfillLedgerOpeningBalances mencatat saldo awal wallet lama yang belum punya jurnal
+func BackfillLedgerOpeningBalances(c *gin.Context) {
+	count, err := ledger.BackfillOpeningBalances(config.DB)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mencatat saldo awal", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Saldo awal wallet dicatat", gin.H{"wallets": count})
+}
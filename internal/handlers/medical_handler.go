@@ -3,10 +3,15 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"homecare-backend/internal/commission"
 	"homecare-backend/internal/config"
+	"homecare-backend/internal/earnings"
+	"homecare-backend/internal/ledger"
 	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
 	"homecare-backend/pkg/utils"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -51,6 +56,13 @@ func SubmitMedicalJournal(c *gin.Context) {
 		return
 	}
 
+	// VALIDASI KEHADIRAN: Harus check-out dulu (jam selesai aktual dipakai untuk payroll)
+	var visit models.OrderVisit
+	if err := config.DB.Where("order_id = ?", order.ID).First(&visit).Error; err != nil || visit.CheckOutAt == nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Silakan check-out di lokasi pasien terlebih dahulu sebelum submit jurnal.", nil)
+		return
+	}
+
 	// MULAI TRANSAKSI DATABASE (PENTING!)
 	// Kita pakai transaksi biar aman: Kalau update saldo gagal, simpan jurnal juga dibatalkan.
 	tx := config.DB.Begin()
@@ -91,12 +103,7 @@ func SubmitMedicalJournal(c *gin.Context) {
 		return
 	}
 
-	// B. Hitung Jatah Mitra
-	// Rumus: (Total Bayar User - Admin Fee Aplikasi) * 85%
-	basePrice := order.TotalAmount - service.AdminFee
-	mitraShare := basePrice * 0.85
-
-	// C. Cari User ID milik Mitra (Karena Wallet nempel di User, bukan di PartnerProfile)
+	// B. Cari User ID milik Mitra (Karena Wallet nempel di User, bukan di PartnerProfile)
 	// Kita butuh tau "Siapa User ID dari Partner yang mengerjakan order ini?"
 	var profile models.PartnerProfile
 	if order.PartnerID != nil {
@@ -107,29 +114,38 @@ func SubmitMedicalJournal(c *gin.Context) {
 		return
 	}
 
-	// D. Cari Wallet Mitra (Kalau gak ada, buat baru)
-	var wallet models.Wallet
-	if err := tx.Where("user_id = ?", profile.UserID).First(&wallet).Error; err != nil {
-		// Buat wallet baru jika belum ada
-		wallet = models.Wallet{UserID: profile.UserID, Balance: 0}
-		tx.Create(&wallet)
+	// C. Hitung Jatah Mitra
+	// Rumus: (Total Bayar User - Admin Fee Aplikasi) * Persentase dari Commission Rule
+	// (rule paling spesifik per layanan/tier; kalau tidak ada pakai default tier Bronze 85%, Silver 87%, Gold 90%)
+	split, err := commission.Resolve(tx, order, service, profile.Tier, time.Now())
+	if err != nil {
+		tx.Rollback()
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung bagi hasil", nil)
+		return
 	}
+	mitraShare := split.PartnerShare
 
-	// E. Tambah Saldo (Income)
-	wallet.Balance += mitraShare
-	if err := tx.Save(&wallet).Error; err != nil {
+	// D. Cari Wallet Mitra (Kalau gak ada, buat baru)
+	mitraWallet, err := wallet.ForUser(tx, profile.UserID)
+	if err != nil {
 		tx.Rollback()
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update saldo mitra", nil)
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Wallet mitra tidak ditemukan", nil)
 		return
 	}
 
-	// F. Catat Riwayat Transaksi (Mutasi Masuk)
+	// E. Catat Pendapatan (Mutasi Masuk) - DITAHAN dulu selama masa komplain customer,
+	// baru masuk saldo wallet (bisa ditarik) setelah masa tahan lewat / customer konfirmasi
+	availableAt := time.Now().Add(earnings.HoldDuration())
 	trx := models.WalletTransaction{
-		WalletID: wallet.ID,
-		OrderID:  &order.ID,
-		Amount:   mitraShare,
-		Type:     "INCOME",
-		Status:   "SUCCESS",
+		WalletID:    mitraWallet.ID,
+		OrderID:     &order.ID,
+		Amount:      mitraShare,
+		Type:        "INCOME",
+		Status:      earnings.StatusHeld,
+		AvailableAt: &availableAt,
+
+		CommissionRuleID:  split.RuleID(),
+		CommissionPercent: split.PartnerPercent,
 	}
 	if err := tx.Create(&trx).Error; err != nil {
 		tx.Rollback()
@@ -137,13 +153,41 @@ func SubmitMedicalJournal(c *gin.Context) {
 		return
 	}
 
+	// F. Ledger: layanan selesai -> pendapatan diterima dimuka dipecah ke pendapatan Mitra (ditahan) & pendapatan platform
+	_, err = ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("ORDER_COMPLETED:%d", order.ID),
+		Kind:        "ORDER_COMPLETED",
+		Description: "Bagi hasil order " + order.OrderNo,
+		OrderID:     &order.ID,
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.CustomerUnearned, order.TotalAmount),
+			ledger.Credit(ledger.EarningsHeld, mitraShare),
+			ledger.Credit(ledger.PlatformRevenue, order.TotalAmount-mitraShare),
+		},
+	})
+	if err != nil {
+		tx.Rollback()
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal catat ledger", nil)
+		return
+	}
+
+	// G. Tanpa masa tahan (EARNINGS_HOLD_HOURS=0) -> langsung masuk saldo
+	if earnings.HoldDuration() == 0 {
+		if err := earnings.Release(tx, trx.ID); err != nil {
+			tx.Rollback()
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update saldo mitra", nil)
+			return
+		}
+	}
+
 	// SELESAI SEMUA: COMMIT TRANSAKSI
 	tx.Commit()
 
-	utils.APIResponse(c, http.StatusOK, true, "Laporan Medis Tersimpan & Saldo Masuk ke Dompet", gin.H{
-		"journal_id": journal.ID,
-		"income":     mitraShare, // Kasih tau mitra dia dapet berapa
-		"status":     "COMPLETED",
+	utils.APIResponse(c, http.StatusOK, true, "Laporan Medis Tersimpan & Pendapatan Tercatat", gin.H{
+		"journal_id":   journal.ID,
+		"income":       mitraShare,  // Kasih tau mitra dia dapet berapa
+		"available_at": availableAt, // Kapan bisa ditarik
+		"status":       "COMPLETED",
 	})
 
 	// 6. KIRIM NOTIFIKASI KE CUSTOMER
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/metrics"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// === FITUR CUSTOMER ===
+
+// CreateOrderReview memberi rating & ulasan untuk order yang sudah COMPLETED (sekali per order)
+func CreateOrderReview(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	orderID := c.Param("id")
+
+	var input models.CreateReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Rating wajib diisi (1-5)", err.Error())
+		return
+	}
+
+	// 1. Pastikan order milik customer ini & sudah selesai
+	var order models.Order
+	if err := config.DB.Where("id = ? AND customer_id = ?", orderID, userID).First(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+	if order.Status != "COMPLETED" || order.PartnerID == nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Ulasan hanya bisa diberikan untuk order yang sudah selesai", nil)
+		return
+	}
+
+	// 2. Cek sudah pernah review atau belum
+	var count int64
+	config.DB.Model(&models.OrderReview{}).Where("order_id = ?", order.ID).Count(&count)
+	if count > 0 {
+		utils.APIResponse(c, http.StatusConflict, false, "Order ini sudah diulas", nil)
+		return
+	}
+
+	review := models.OrderReview{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		PartnerID:  *order.PartnerID,
+		Rating:     input.Rating,
+		Comment:    input.Comment,
+	}
+	if err := config.DB.Create(&review).Error; err != nil {
+		utils.APIResponse(c, http.StatusConflict, false, "Order ini sudah diulas", nil)
+		return
+	}
+
+	// 3. Rating Mitra langsung diperbarui (tidak perlu menunggu job berikutnya)
+	go func(partnerID uint64) {
+		if _, err := metrics.Refresh(config.DB, partnerID); err != nil {
+			log.Printf("[Metrics] Gagal refresh metrik Mitra %d: %v", partnerID, err)
+		}
+	}(review.PartnerID)
+
+	utils.APIResponse(c, http.StatusCreated, true, "Terima kasih atas ulasan Anda", review)
+}
+
+// === FITUR MITRA ===
+
+// GetMyMetrics melihat performa & tier Mitra sendiri
+func GetMyMetrics(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	respondPartnerMetric(c, profile.ID)
+}
+
+// === FITUR ADMIN ===
+
+// GetPartnerMetrics melihat performa & tier satu Mitra
+func GetPartnerMetrics(c *gin.Context) {
+	var profile models.PartnerProfile
+	if err := config.DB.First(&profile, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Mitra tidak ditemukan", nil)
+		return
+	}
+
+	respondPartnerMetric(c, profile.ID)
+}
+
+// RefreshPartnerMetrics menghitung ulang metrik semua Mitra saat itu juga (tanpa menunggu job)
+func RefreshPartnerMetrics(c *gin.Context) {
+	count, err := metrics.RefreshAll(config.DB)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung ulang metrik", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Metrik Mitra diperbarui", gin.H{"partners": count})
+}
+
+// respondPartnerMetric mengirim metrik terakhir. Kalau belum pernah dihitung, hitung dulu.
+func respondPartnerMetric(c *gin.Context, partnerID uint64) {
+	var metric models.PartnerMetric
+	if err := config.DB.Where("partner_id = ?", partnerID).First(&metric).Error; err != nil {
+		metric, err = metrics.Refresh(config.DB, partnerID)
+		if err != nil {
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung metrik", err.Error())
+			return
+		}
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Performa Mitra", gin.H{
+		"metric":         metric,
+		"commission":     money.BpsToPercent(metrics.PartnerShareBps(metric.Tier)), // Persen default tier
+		"dispatch_delay": metrics.DispatchDelay(metric.Tier).String(),
+	})
+}
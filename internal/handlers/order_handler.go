@@ -1,34 +1,48 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"homecare-backend/internal/billing"
 	"homecare-backend/internal/config"
 	"homecare-backend/internal/models"
+	"homecare-backend/internal/payer"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/payment"
 	"homecare-backend/pkg/utils"
+	"log"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/snap"
+	"gorm.io/gorm"
 )
 
-// CreateOrder membuat pesanan baru
+// CreateOrder membuat pesanan baru.
+// Client sebaiknya kirim header Idempotency-Key (misal UUID) supaya double-tap / retry karena timeout
+// tidak membuat order & tagihan dobel: request dengan key yang sama mendapat response order yang pertama.
 func CreateOrder(c *gin.Context) {
 	customerID, _ := c.Get("userID")
 
-	// Kita perlu ambil data user lengkap (Nama & Email) untuk dikirim ke Midtrans
-	var customer models.User
-	config.DB.First(&customer, customerID)
-
 	var input models.CreateOrderInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.APIResponse(c, http.StatusBadRequest, false, "Input Order Salah", err.Error())
 		return
 	}
 
+	idemKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(idemKey) > 64 {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Idempotency-Key maksimal 64 karakter", nil)
+		return
+	}
+
+	// Request ulang dengan key yang sama -> kembalikan order yang sudah dibuat
+	if existing, ok := findOrderByKey(customerID.(uint64), idemKey); ok {
+		respondOrderCreated(c, existing)
+		return
+	}
+
 	// 1. Cek Layanan & Ambil Harga
 	var service models.Service
 	if err := config.DB.First(&service, input.ServiceID).Error; err != nil {
@@ -37,14 +51,36 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	totalAmount := service.Price + service.AdminFee
-	orderNo := fmt.Sprintf("INV-%d", time.Now().Unix()) // Format: INV-17682391
 	endTime := input.ScheduleStart.Add(time.Duration(input.DurationHours) * time.Hour)
 
+	// Format: INV-20261019-000042 (urutan harian dari tabel sequences, tidak bentrok walau order di detik yang sama)
+	orderNo, err := billing.NextOrderNo(config.DB, time.Now())
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat nomor order", err.Error())
+		return
+	}
+
 	var partnerID *uint64
 	if input.PartnerID != 0 {
 		partnerID = &input.PartnerID
 	}
 
+	// Ditagihkan ke perusahaan/asuransi: hitung bagian yang ditanggung (sisanya dibayar customer)
+	var payerOrgID *uint64
+	var payerAmount money.Money
+	if input.PayerOrganizationID != 0 {
+		payerAmount, err = payer.Coverage(config.DB, input.PayerOrganizationID, customerID.(uint64), service.ID, totalAmount)
+		if err != nil {
+			if errors.Is(err, payer.ErrNotMember) || errors.Is(err, payer.ErrNotCovered) || errors.Is(err, payer.ErrOrgInactive) {
+				utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+				return
+			}
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengecek tanggungan", err.Error())
+			return
+		}
+		payerOrgID = &input.PayerOrganizationID
+	}
+
 	// 2. Simpan Order ke DB (Status PENDING)
 	order := models.Order{
 		OrderNo:       orderNo,
@@ -52,69 +88,157 @@ func CreateOrder(c *gin.Context) {
 		PatientID:     input.PatientID,
 		ServiceID:     input.ServiceID,
 		TotalAmount:   totalAmount,
+		ServiceFee:    service.Price,
+		AdminFee:      service.AdminFee,
 		PartnerID:     partnerID,
+		PayerAmount:   payerAmount,
 		Status:        "PENDING_PAYMENT",
 		ScheduleStart: input.ScheduleStart,
 		ScheduleEnd:   endTime,
 	}
+	order.PayerOrganizationID = payerOrgID
+	if idemKey != "" {
+		order.IdempotencyKey = &idemKey
+	}
 
-	if err := config.DB.Create(&order).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		// Tanggungan organisasi harus masih muat di batas kreditnya
+		if order.PayerOrganizationID != nil {
+			if err := payer.ReserveCredit(tx, *order.PayerOrganizationID, order.PayerAmount); err != nil {
+				return err
+			}
+		}
+		// Bayar pakai saldo wallet dulu (kalau diminta), sisanya lewat gateway
+		if input.UseBalance {
+			if err := billing.UseBalance(tx, &order); err != nil {
+				return err
+			}
+		}
+		// Tidak ada sisa tagihan (ditanggung penuh / saldo cukup) -> langsung lunas
+		return billing.SettleWithoutCharge(tx, &order)
+	})
+	if errors.Is(err, payer.ErrCreditLimit) || errors.Is(err, payer.ErrOrgInactive) {
+		utils.APIResponse(c, http.StatusUnprocessableEntity, false, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		// Bentrok unique (customer_id, idempotency_key) = request paralel dengan key sama sudah duluan berhasil.
+		// Transaksi kita sudah di-rollback (saldo tidak terpotong dua kali), kembalikan order yang sudah ada.
+		if existing, ok := findOrderByKey(customerID.(uint64), idemKey); ok {
+			respondOrderCreated(c, existing)
+			return
+		}
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan order", err.Error())
 		return
 	}
 
-	// ==========================================
-	// 3. INTEGRASI MIDTRANS SNAP (BAGIAN BARU)
-	// ==========================================
-
-	// A. Init Client Midtrans
-	var s = snap.Client{}
-	s.New(os.Getenv("MIDTRANS_SERVER_KEY"), midtrans.Sandbox)
-
-	// B. Siapkan Request Snap
-	req := &snap.Request{
-		TransactionDetails: midtrans.TransactionDetails{
-			OrderID:  orderNo,
-			GrossAmt: int64(totalAmount), // Midtrans minta int64
-		},
-		CreditCard: &snap.CreditCardDetails{
-			Secure: true,
-		},
-		CustomerDetail: &midtrans.CustomerDetails{
-			FName: customer.FullName,
-			Email: customer.Email,
-			Phone: customer.Phone,
-		},
-		Items: &[]midtrans.ItemDetails{
-			{
-				ID:    fmt.Sprintf("SVC-%d", service.ID),
-				Name:  service.Name,
-				Price: int64(service.Price + service.AdminFee),
-				Qty:   1,
-			},
-		},
-	}
-
-	// C. Minta Token ke Midtrans
-	snapResp, errSnap := s.CreateTransaction(req)
-	if errSnap != nil {
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Midtrans Error", errSnap.GetMessage())
+	// Ditanggung penuh organisasi / saldo cukup -> order langsung lunas, tidak perlu ke gateway
+	if order.Status == "PAID" {
+		billing.NotifyPaymentStatus(config.DB, order, "PAID")
+		respondOrderCreated(c, order)
 		return
 	}
 
-	// 4. Simpan Link Pembayaran ke Database!
-	// Ini langkah krusial agar link tidak hilang kalau user menutup browser.
-	order.PaymentURL = snapResp.RedirectURL
-	config.DB.Save(&order) // Update data order yang tadi dibuat
+	// 3. Buat Tagihan di Payment Gateway (Midtrans / fake, lihat PAYMENT_GATEWAY)
+	// Sesi pembayaran dicatat di payment_attempts & link-nya disimpan di order (tidak hilang kalau user menutup browser)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if _, _, err := billing.Charge(ctx, config.DB, payment.Default, &order); err != nil {
+		// Saldo yang sudah terpotong & kredit organisasi jangan sampai nyangkut di order yang tidak bisa dibayar
+		if order.BalanceUsed > 0 || order.PayerAmount > 0 {
+			if _, err := billing.ApplyPaymentStatus(config.DB, &order, "CANCELLED"); err != nil {
+				log.Printf("[Order] Gagal membatalkan order %s: %v", order.OrderNo, err)
+			}
+		}
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Payment Gateway Error", err.Error())
+		return
+	}
+
+	// 4. Return Response
+	respondOrderCreated(c, order)
+}
+
+// respondOrderCreated response CreateOrder. Dibangun ulang dari DB, jadi retry dengan Idempotency-Key
+// mendapat isi yang sama persis dengan response pertama.
+func respondOrderCreated(c *gin.Context, order models.Order) {
+	if order.Status == "PAID" && order.ChargeAmount() == 0 {
+		msg := "Order Berhasil! Dibayar lunas dengan saldo."
+		if order.PayerAmount > 0 {
+			msg = "Order Berhasil! Biaya ditanggung perusahaan/asuransi."
+		}
+		utils.APIResponse(c, http.StatusCreated, true, msg, gin.H{
+			"order_id":     order.ID,
+			"order_no":     order.OrderNo,
+			"total_amount": order.TotalAmount,
+			"payer_amount": order.PayerAmount,
+			"balance_used": order.BalanceUsed,
+		})
+		return
+	}
+
+	// Sesi pembayaran pertama (kosong kalau request pertama masih membuat tagihan di gateway)
+	var attempt models.PaymentAttempt
+	config.DB.Where("order_id = ?", order.ID).Order("attempt_no asc").First(&attempt)
 
-	// 5. Return Response
 	utils.APIResponse(c, http.StatusCreated, true, "Order Berhasil! Silakan Bayar.", gin.H{
+		"order_id":      order.ID,
+		"order_no":      order.OrderNo,
+		"total_amount":  order.TotalAmount,
+		"payer_amount":  order.PayerAmount,
+		"balance_used":  order.BalanceUsed,
+		"charge_amount": order.ChargeAmount(),
+		"snap_token":    attempt.Token,
+		"payment_url":   order.PaymentURL, // Kalau link kedaluwarsa, minta link baru lewat POST /orders/:id/pay
+		"redirect_url":  attempt.PaymentURL,
+	})
+}
+
+// findOrderByKey mencari order customer dengan Idempotency-Key yang sama
+func findOrderByKey(customerID uint64, key string) (models.Order, bool) {
+	var order models.Order
+	if key == "" {
+		return order, false
+	}
+	err := config.DB.Where("customer_id = ? AND idempotency_key = ?", customerID, key).First(&order).Error
+	return order, err == nil
+}
+
+// RetryOrderPayment membuat ulang sesi pembayaran (tombol "Bayar Ulang") untuk order yang belum dibayar.
+// Link lama dibatalkan, jadi customer cukup pakai link terbaru.
+func RetryOrderPayment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var order models.Order
+	if err := config.DB.Where("id = ? AND customer_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	attempt, charge, err := billing.Charge(ctx, config.DB, payment.Default, &order)
+	if errors.Is(err, billing.ErrNotPayable) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Order sudah dibayar atau dibatalkan", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Payment Gateway Error", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Link pembayaran baru dibuat. Silakan Bayar.", gin.H{
 		"order_id":     order.ID,
 		"order_no":     order.OrderNo,
-		"total_amount": order.TotalAmount,
-		"snap_token":   snapResp.Token,
-		"payment_url":  order.PaymentURL, // Frontend bisa pakai ini untuk tombol "Bayar Ulang"
-		"redirect_url": snapResp.RedirectURL,
+		"attempt_no":   attempt.AttemptNo,
+		"amount":       attempt.Amount,
+		"snap_token":   charge.Token,
+		"payment_url":  order.PaymentURL,
+		"redirect_url": charge.RedirectURL,
 	})
 }
 
@@ -147,7 +271,11 @@ func GetOrderDetail(c *gin.Context) {
 		Preload("Service").
 		Preload("Patient").
 		Preload("PartnerProfile.User").
-		Preload("CareJournal").                               // <--- Ambil Laporan Medis
+		Preload("CareJournal"). // <--- Ambil Laporan Medis
+		Preload("Review").
+		Preload("Dispute").
+		Preload("PaymentAttempts").
+		Preload("PayerOrganization").
 		Where("id = ? AND customer_id = ?", orderID, userID). // Pastikan ini order milik dia sendiri
 		First(&order).Error
 
@@ -4,14 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"homecare-backend/internal/config"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/metrics"
 	"homecare-backend/internal/models"
+	"homecare-backend/internal/search"
 	"homecare-backend/pkg/utils"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// Batasan pencarian Mitra (bisa diubah lewat .env SEARCH_DEFAULT_RADIUS_KM & SEARCH_MAX_RADIUS_KM)
+const (
+	defaultSearchRadiusKM = 15
+	maxSearchRadiusKM     = 50
+	maxSearchLimit        = 100
+)
+
 func UpdatePartnerProfile(c *gin.Context) {
 	// 1. Ambil User ID dari Middleware
 	userID, _ := c.Get("userID")
@@ -38,7 +53,12 @@ func UpdatePartnerProfile(c *gin.Context) {
 				BioDescription:  input.BioDescription,
 				CurrentLat:      input.CurrentLat,
 				CurrentLng:      input.CurrentLng,
-				IsActive:        true, // Langsung aktifkan (atau bisa nunggu verifikasi admin)
+				Gender:          input.Gender,
+				Languages:       normalizeLanguages(input.Languages),
+				PriceTier:       input.PriceTier,
+				// Belum boleh terima order sampai verifikasi Admin APPROVED
+				IsActive:           false,
+				VerificationStatus: models.PartnerDraft,
 			}
 			if err := config.DB.Create(&profile).Error; err != nil {
 				utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat profil mitra", err.Error())
@@ -58,6 +78,9 @@ func UpdatePartnerProfile(c *gin.Context) {
 			BioDescription:  input.BioDescription,
 			CurrentLat:      input.CurrentLat,
 			CurrentLng:      input.CurrentLng,
+			Gender:          input.Gender,
+			Languages:       normalizeLanguages(input.Languages),
+			PriceTier:       input.PriceTier,
 		}).Error; err != nil {
 			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengupdate profil mitra", err.Error())
 			return
@@ -73,28 +96,52 @@ func UpdatePartnerProfile(c *gin.Context) {
 	utils.APIResponse(c, http.StatusOK, true, "Profil Mitra Berhasil Diupdate!", profile)
 }
 
+// normalizeLanguages mengubah ["ID", " jv "] menjadi "id,jv" (format kolom languages)
+func normalizeLanguages(languages []string) string {
+	codes := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		if code := strings.ToLower(strings.TrimSpace(lang)); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, ",")
+}
+
 // Tambahan: Handler untuk melihat list layanan (Biar customer bisa liat menu)
 func GetServices(c *gin.Context) {
-	var services []struct {
-		ID          uint    `json:"id"`
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price"`
-		AdminFee    float64 `json:"admin_fee"`
-	}
-	// Ambil semua layanan dari DB
-	config.DB.Table("services").Find(&services)
+	var services []models.Service
+	// Ambil semua layanan dari DB (+ syarat kompetensinya)
+	config.DB.Preload("RequiredCompetencies").Find(&services)
 
 	utils.APIResponse(c, http.StatusOK, true, "Daftar Layanan", services)
 }
 
 // GetAvailableOrders menampilkan job yang sudah dibayar tapi belum ada perawatnya
 func GetAvailableOrders(c *gin.Context) {
-	var orders []models.Order
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
 
-	// Logic: Status PAID + PartnerID masih Kosong (NULL)
-	// Preload Service & Patient biar perawat tau ini sakit apa & bayarannya berapa
-	config.DB.Preload("Service").Preload("Patient").Where("status = ? AND partner_id IS NULL", "PAID").Find(&orders)
+	// Hanya tampilkan job untuk layanan yang memang boleh dikerjakan Mitra ini
+	serviceIDs := eligibleServiceIDs(profile)
+
+	// Prioritas dispatch: tier rendah baru melihat job setelah jeda tertentu sejak PAID
+	visibleBefore := time.Now().Add(-metrics.DispatchDelay(profile.Tier))
+
+	var orders []models.Order
+	if len(serviceIDs) > 0 {
+		// Logic: Status PAID + PartnerID masih Kosong (NULL)
+		// Preload Service & Patient biar perawat tau ini sakit apa & bayarannya berapa
+		config.DB.Preload("Service").Preload("Patient").
+			Where("status = ? AND partner_id IS NULL", "PAID").
+			Where("service_id IN ?", serviceIDs).
+			Where("paid_at IS NULL OR paid_at <= ?", visibleBefore).
+			Find(&orders)
+	}
 	utils.APIResponse(c, http.StatusOK, true, "Daftar Job Tersedia", orders)
 }
 
@@ -117,6 +164,12 @@ func AcceptOrder(c *gin.Context) {
 		return
 	}
 
+	// Cek Kompetensi & Pengalaman sesuai syarat layanan
+	if ok, reason := checkPartnerEligibility(profile, order.ServiceID); !ok {
+		utils.APIResponse(c, http.StatusForbidden, false, reason, nil)
+		return
+	}
+
 	// 3. LOGIKA DIRECT BOOKING (Handling Direct Booking vs Open Booking)
 	if order.PartnerID != nil {
 		// Jika PartnerID sudah terisi, Cek: Apakah ID yang tertulis di order ITU SAYA?
@@ -125,6 +178,12 @@ func AcceptOrder(c *gin.Context) {
 			return
 		}
 	} else {
+		// Open Booking: hormati prioritas tier (sama seperti GetAvailableOrders)
+		if order.PaidAt != nil && time.Since(*order.PaidAt) < metrics.DispatchDelay(profile.Tier) {
+			utils.APIResponse(c, http.StatusForbidden, false, "Job ini masih diprioritaskan untuk Mitra tier lebih tinggi. Coba lagi sebentar.", nil)
+			return
+		}
+
 		// Jika PartnerID kosong (Open Booking), isi dengan ID saya.
 		order.PartnerID = &profile.ID
 	}
@@ -144,7 +203,7 @@ func AcceptOrder(c *gin.Context) {
 	// Dan waktunya tumpang tindih dengan order baru ini
 	config.DB.Model(&models.Order{}).
 		Where("partner_id = ?", profile.ID).
-		Where("status IN ('ASSIGNED', 'ON_DUTY')").
+		Where("status IN ('ASSIGNED', 'EN_ROUTE', 'ON_DUTY')").
 		Where("id <> ?", order.ID). // Jangan hitung order ini sendiri (jaga-jaga)
 		// Rumus Logika Overlap: (StartA < EndB) AND (EndA > StartB)
 		Where("schedule_start < ? AND schedule_end > ?", order.ScheduleEnd, order.ScheduleStart).
@@ -156,7 +215,9 @@ func AcceptOrder(c *gin.Context) {
 	}
 
 	// 6. Update Status
+	now := time.Now()
 	order.Status = "ASSIGNED" // Status berubah jadi ASSIGNED (Sudah dapat perawat)
+	order.AcceptedAt = &now
 
 	if err := config.DB.Save(&order).Error; err != nil {
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal konfirmasi order", nil)
@@ -180,14 +241,21 @@ func AcceptOrder(c *gin.Context) {
 	}
 }
 
-// StartOrder: Mitra menekan tombol "Mulai Kerja" saat sampai di lokasi
+// StartOrder: Mitra menekan tombol "Mulai Kerja" saat sampai di lokasi (Check-In)
+// Body wajib berisi koordinat GPS Mitra, harus dalam radius geofence alamat pasien.
 func StartOrder(c *gin.Context) {
 	mitraID, _ := c.Get("userID")
 	orderID := c.Param("id")
 
+	var input models.GeofenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Koordinat lokasi (lat/lng) wajib dikirim saat check-in", err.Error())
+		return
+	}
+
 	// 1. Cari Order
 	var order models.Order
-	if err := config.DB.First(&order, orderID).Error; err != nil {
+	if err := config.DB.Preload("Patient").First(&order, orderID).Error; err != nil {
 		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
 		return
 	}
@@ -204,14 +272,51 @@ func StartOrder(c *gin.Context) {
 		return
 	}
 
-	// 3. Update Status
+	if order.Status != "ASSIGNED" && order.Status != "EN_ROUTE" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Order ini tidak bisa dimulai (Status: "+order.Status+")", nil)
+		return
+	}
+
+	// 3. Cek Geofence (kecuali sudah di-override Admin)
+	var visit models.OrderVisit
+	config.DB.Where("order_id = ?", order.ID).First(&visit)
+
+	distanceM, err := checkGeofence(order, visit, input)
+	if err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, err.Error(), gin.H{
+			"distance_m": distanceM,
+			"radius_m":   geofenceRadiusM(),
+		})
+		return
+	}
+
+	// 4. Catat Check-In & Update Status
+	now := time.Now()
+	visit.OrderID = order.ID
+	visit.PartnerID = profile.ID
+	visit.CheckInAt = &now
+	visit.CheckInLat = *input.Lat
+	visit.CheckInLng = *input.Lng
+	visit.CheckInDistanceM = distanceM
+	visit.LateMinutes = minutesAfter(now, order.ScheduleStart)
+
 	order.Status = "ON_DUTY"
-	if err := config.DB.Save(&order).Error; err != nil {
+
+	tx := config.DB.Begin()
+	if err := tx.Save(&visit).Error; err != nil {
+		tx.Rollback()
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mencatat check-in", nil)
+		return
+	}
+	if err := tx.Omit("Patient").Save(&order).Error; err != nil {
+		tx.Rollback()
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update status", nil)
 		return
 	}
+	tx.Commit()
+	order.Visit = &visit
 
-	// 4. Kirim Notifikasi ke Customer
+	// 5. Kirim Notifikasi ke Customer
 	var customer models.User
 	if err := config.DB.First(&customer, order.CustomerID).Error; err == nil {
 		if customer.FCMToken != "" {
@@ -227,11 +332,12 @@ func StartOrder(c *gin.Context) {
 	utils.APIResponse(c, http.StatusOK, true, "Status: ON DUTY. Selamat bekerja!", order)
 }
 
-// Update di: internal/handlers/partner_handler.go
-
+// SearchPartners mencari Mitra aktif terdekat + filter & urutan
+// Contoh URL: GET /api/v1/partners/search?lat=-6.200&lng=106.812&radius_km=10&limit=20
+// Filter opsional: service_id, gender (L/P), min_rating, min_experience, languages (id,jv),
+// price_tier, available_at (RFC3339) + duration_hours, sort (distance/rating/experience), cursor
 func SearchPartners(c *gin.Context) {
 	// 1. Ambil koordinat Customer/Pasien dari Query Param
-	// Contoh URL: GET /api/v1/partners/search?lat=-6.200&lng=106.812
 	latStr := c.Query("lat")
 	lngStr := c.Query("lng")
 
@@ -241,34 +347,103 @@ func SearchPartners(c *gin.Context) {
 	}
 
 	// Convert string ke float64
-	latParam := utils.StringToFloat(latStr) // Pastikan kamu punya helper ini atau pakai strconv
+	latParam := utils.StringToFloat(latStr)
 	lngParam := utils.StringToFloat(lngStr)
+	if latParam < -90 || latParam > 90 || lngParam < -180 || lngParam > 180 {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Koordinat (lat/lng) tidak valid", nil)
+		return
+	}
+
+	// 2. Radius & Limit (ada batas atas biar query tidak dipakai untuk dump semua data)
+	radiusKM := envFloat("SEARCH_DEFAULT_RADIUS_KM", defaultSearchRadiusKM)
+	if r := utils.StringToFloat(c.Query("radius_km")); r > 0 {
+		radiusKM = math.Min(r, envFloat("SEARCH_MAX_RADIUS_KM", maxSearchRadiusKM))
+	}
+
+	limit := int(utils.StringToUint64(c.DefaultQuery("limit", "20")))
+	if limit < 1 || limit > maxSearchLimit {
+		limit = 20
+	}
 
-	var partners []models.PartnerProfile
+	// 3. Filter
+	var input struct {
+		Gender        string  `form:"gender" binding:"omitempty,oneof=L P"`
+		MinRating     float64 `form:"min_rating" binding:"omitempty,min=0,max=5"`
+		MinExperience int     `form:"min_experience" binding:"omitempty,min=0"`
+		Languages     string  `form:"languages"`
+		PriceTier     string  `form:"price_tier" binding:"omitempty,oneof=BASIC STANDARD PREMIUM"`
+		AvailableAt   string  `form:"available_at"`
+		DurationHours int     `form:"duration_hours" binding:"omitempty,min=1,max=24"`
+		Sort          string  `form:"sort" binding:"omitempty,oneof=distance rating experience"`
+		Cursor        string  `form:"cursor"`
+	}
+	if err := c.ShouldBindQuery(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Filter pencarian tidak valid", err.Error())
+		return
+	}
+
+	params := search.PartnerQuery{
+		Lat:           latParam,
+		Lng:           lngParam,
+		RadiusKM:      radiusKM,
+		Gender:        input.Gender,
+		MinRating:     input.MinRating,
+		MinExperience: input.MinExperience,
+		PriceTier:     input.PriceTier,
+		Sort:          input.Sort,
+		Cursor:        input.Cursor,
+		Limit:         limit,
+	}
 
-	// 2. Logika Filtering Radius (Haversine Formula MySQL)
-	// Angka 6371 adalah jari-jari bumi dalam KM.
-	// Query ini menghitung jarak antara (current_lat, current_lng) mitra dengan (latParam, lngParam) user.
+	if input.Languages != "" {
+		if langs := normalizeLanguages(strings.Split(input.Languages, ",")); langs != "" {
+			params.Languages = strings.Split(langs, ",")
+		}
+	}
 
-	radiusKM := 15 // Kita cari perawat dalam radius 15 KM
+	// Cek ketersediaan di jam tertentu (default durasi 1 jam)
+	if input.AvailableAt != "" {
+		from, err := time.Parse(time.RFC3339, input.AvailableAt)
+		if err != nil {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Format available_at harus RFC3339 (2025-11-20T08:00:00+07:00)", nil)
+			return
+		}
+		duration := input.DurationHours
+		if duration == 0 {
+			duration = 1
+		}
+		to := from.Add(time.Duration(duration) * time.Hour)
+		params.AvailableFrom = &from
+		params.AvailableTo = &to
+	}
 
-	// Query Raw SQL untuk filter jarak & urutkan dari yang terdekat
-	err := config.DB.
-		Table("partner_profiles").
-		Select("partner_profiles.*, (6371 * acos(cos(radians(?)) * cos(radians(current_lat)) * cos(radians(current_lng) - radians(?)) + sin(radians(?)) * sin(radians(current_lat)))) AS distance", latParam, lngParam, latParam).
-		Joins("JOIN users ON users.id = partner_profiles.user_id"). // Join ke user biar bisa preload
-		Preload("User").                                            // Load data nama/foto
-		Where("is_active = ?", true).
-		Having("distance < ?", radiusKM). // Hanya yang < 15 KM
-		Order("distance ASC").            // Urutkan yang paling dekat duluan
-		Find(&partners).Error
+	// Opsional: ?service_id=3 -> hanya Mitra yang memenuhi syarat layanan tsb
+	if serviceIDStr := c.Query("service_id"); serviceIDStr != "" {
+		var svc models.Service
+		if err := config.DB.First(&svc, utils.StringToUint64(serviceIDStr)).Error; err != nil {
+			utils.APIResponse(c, http.StatusNotFound, false, "Layanan tidak ditemukan", nil)
+			return
+		}
+		params.Service = &svc
+	}
 
+	// 4. Cari (Bounding Box + Haversine, lihat package search)
+	partners, nextCursor, err := search.NearbyPartners(config.DB, params)
 	if err != nil {
+		if errors.Is(err, search.ErrInvalidCursor) {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Cursor tidak valid, ulangi pencarian dari awal", nil)
+			return
+		}
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mencari mitra terdekat", err.Error())
 		return
 	}
 
-	utils.APIResponse(c, http.StatusOK, true, "Rekomendasi Mitra Terdekat", partners)
+	utils.APIResponse(c, http.StatusOK, true, "Rekomendasi Mitra Terdekat", gin.H{
+		"partners":    partners,
+		"radius_km":   radiusKM,
+		"limit":       limit,
+		"next_cursor": nextCursor, // Kosong = sudah halaman terakhir
+	})
 }
 
 // RejectOrder: Mitra menolak orderan yang ditujukan padanya (Direct Booking)
@@ -301,8 +476,28 @@ func RejectOrder(c *gin.Context) {
 	// 4. Update Status jadi CANCELLED (atau REFUND_NEEDED)
 	// Kita set PartnerID jadi NULL lagi biar history-nya jelas atau biarkan terisi untuk audit admin.
 	order.Status = "CANCELLED"
+	order.CancelReason = "PARTNER_REJECTED" // Dihitung di metrik penolakan Mitra
 
-	if err := config.DB.Save(&order).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		// Ledger: uang customer yang sudah dibayar jadi hutang refund.
+		// Bagian yang ditanggung organisasi cukup dibatalkan dari piutang (tidak ikut ditagih bulanan).
+		_, err := ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("ORDER_REFUND_DUE:%d", order.ID),
+			Kind:        "ORDER_REFUND_DUE",
+			Description: "Order ditolak Mitra, menunggu refund",
+			OrderID:     &order.ID,
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.CustomerUnearned, order.TotalAmount),
+				ledger.Credit(ledger.RefundsPayable, order.TotalAmount-order.PayerAmount),
+				ledger.Credit(ledger.CustomerReceivable, order.PayerAmount),
+			},
+		})
+		return err
+	})
+	if err != nil {
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menolak order", nil)
 		return
 	}
@@ -336,6 +531,18 @@ func TogglePartnerStatus(c *gin.Context) {
 		return
 	}
 
+	// Hanya Mitra terverifikasi yang boleh ONLINE
+	if !profile.IsActive && profile.VerificationStatus != models.PartnerApproved {
+		utils.APIResponse(c, http.StatusForbidden, false, "Akun Anda belum terverifikasi (Status: "+profile.VerificationStatus+")", nil)
+		return
+	}
+
+	// STR/SIP kedaluwarsa tidak boleh praktik (job dokumen sudah menonaktifkan, jangan bisa ONLINE lagi)
+	if !profile.IsActive && !licenseValid(profile.ID) {
+		utils.APIResponse(c, http.StatusForbidden, false, msgLicenseExpired, nil)
+		return
+	}
+
 	// 2. Logic Toggle (Balik Status)
 	// Kalau True jadi False, Kalau False jadi True
 	profile.IsActive = !profile.IsActive
@@ -373,6 +580,7 @@ func GetMyJobs(c *gin.Context) {
 	config.DB.
 		Preload("Service").
 		Preload("Patient").
+		Preload("Visit").
 		Where("partner_id = ?", profile.ID).
 		Order("created_at desc").
 		Find(&jobs)
@@ -393,3 +601,12 @@ func GetMyPartnerProfile(c *gin.Context) {
 
 	utils.APIResponse(c, http.StatusOK, true, "Data Lengkap Mitra", profile)
 }
+
+// envFloat membaca angka desimal dari .env, pakai default kalau kosong/salah
+func envFloat(key string, def float64) float64 {
+	val, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || val <= 0 {
+		return def
+	}
+	return val
+}
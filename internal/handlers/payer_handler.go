@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/payer"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR CUSTOMER (PENJAMIN) ===
+
+// GetMyPayers daftar perusahaan/asuransi yang menanggung customer (pilihan "Tagihkan ke Kantor/Asuransi" saat order)
+func GetMyPayers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	now := time.Now()
+	var members []models.PayerMember
+	config.DB.Preload("Organization").Preload("Organization.Coverages").
+		Joins("JOIN payer_organizations ON payer_organizations.id = payer_members.organization_id AND payer_organizations.is_active = ?", true).
+		Where("payer_members.user_id = ? AND payer_members.is_active = ?", userID, true).
+		Where("payer_members.valid_from IS NULL OR payer_members.valid_from <= ?", now).
+		Where("payer_members.valid_until IS NULL OR payer_members.valid_until >= ?", now).
+		Find(&members)
+
+	var result []gin.H
+	for _, m := range members {
+		result = append(result, gin.H{
+			"organization_id": m.OrganizationID,
+			"name":            m.Organization.Name,
+			"type":            m.Organization.Type,
+			"member_no":       m.MemberNo,
+			"valid_until":     m.ValidUntil,
+			"coverages":       m.Organization.Coverages,
+		})
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Penjamin Saya", result)
+}
+
+// === FITUR FINANCE (ORGANISASI PENJAMIN) ===
+
+// GetPayerOrganizations daftar organisasi penjamin (filter ?type=INSURER&q=nama)
+func GetPayerOrganizations(c *gin.Context) {
+	query := config.DB.Order("name asc")
+	if orgType := c.Query("type"); orgType != "" {
+		query = query.Where("type = ?", orgType)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("name LIKE ?", "%"+q+"%")
+	}
+
+	var orgs []models.PayerOrganization
+	query.Find(&orgs)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Organisasi Penjamin", orgs)
+}
+
+// GetPayerOrganizationDetail detail organisasi: anggota, aturan tanggungan & sisa kredit
+func GetPayerOrganizationDetail(c *gin.Context) {
+	var org models.PayerOrganization
+	if err := config.DB.Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Members.User").Preload("Coverages").Preload("Coverages.Service").
+		First(&org, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Organisasi tidak ditemukan", nil)
+		return
+	}
+
+	outstanding, err := payer.Outstanding(config.DB, org.ID)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung piutang", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Detail Organisasi Penjamin", gin.H{
+		"organization":     org,
+		"outstanding":      outstanding,
+		"available_credit": org.CreditLimit - outstanding,
+	})
+}
+
+// CreatePayerOrganization mendaftarkan perusahaan / asuransi baru
+func CreatePayerOrganization(c *gin.Context) {
+	var input models.PayerOrganizationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	org := models.PayerOrganization{IsActive: true}
+	applyPayerOrganizationInput(&org, input)
+	if err := config.DB.Create(&org).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan organisasi", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Organisasi penjamin ditambahkan", org)
+}
+
+// UpdatePayerOrganization mengubah data organisasi (batas kredit, termin, nonaktifkan, dll).
+// Menurunkan batas kredit tidak membatalkan order yang sudah ada, hanya menahan order baru.
+func UpdatePayerOrganization(c *gin.Context) {
+	var org models.PayerOrganization
+	if err := config.DB.First(&org, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Organisasi tidak ditemukan", nil)
+		return
+	}
+
+	var input models.PayerOrganizationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	applyPayerOrganizationInput(&org, input)
+	if err := config.DB.Save(&org).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan organisasi", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Organisasi penjamin diperbarui", org)
+}
+
+func applyPayerOrganizationInput(org *models.PayerOrganization, input models.PayerOrganizationInput) {
+	org.Name = input.Name
+	org.Type = input.Type
+	org.Email = input.Email
+	org.Phone = input.Phone
+	org.Address = input.Address
+	org.NPWP = input.NPWP
+	org.CreditLimit = input.CreditLimit
+	org.PaymentTermDays = 30
+	if input.PaymentTermDays > 0 {
+		org.PaymentTermDays = input.PaymentTermDays
+	}
+	if input.IsActive != nil {
+		org.IsActive = *input.IsActive
+	}
+}
+
+// AddPayerMember menambah customer (dicari dari email) sebagai anggota. Anggota yang pernah dikeluarkan diaktifkan lagi.
+func AddPayerMember(c *gin.Context) {
+	var org models.PayerOrganization
+	if err := config.DB.First(&org, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Organisasi tidak ditemukan", nil)
+		return
+	}
+
+	var input models.PayerMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+	if input.ValidFrom != nil && input.ValidUntil != nil && input.ValidUntil.Before(*input.ValidFrom) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "valid_until harus setelah valid_from", nil)
+		return
+	}
+
+	var customer models.User
+	if err := config.DB.Where("email = ? AND role_id = ?", strings.TrimSpace(input.Email), 4).First(&customer).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Customer dengan email tersebut tidak ditemukan", nil)
+		return
+	}
+
+	var member models.PayerMember
+	err := config.DB.Where("organization_id = ? AND user_id = ?", org.ID, customer.ID).First(&member).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengecek anggota", err.Error())
+		return
+	}
+	member.OrganizationID = org.ID
+	member.UserID = customer.ID
+	member.MemberNo = input.MemberNo
+	member.ValidFrom = input.ValidFrom
+	member.ValidUntil = input.ValidUntil
+	member.IsActive = true
+	if err := config.DB.Save(&member).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan anggota", err.Error())
+		return
+	}
+	member.User = &customer
+
+	utils.APIResponse(c, http.StatusCreated, true, "Anggota ditambahkan", member)
+}
+
+// RemovePayerMember mengeluarkan anggota (dinonaktifkan, riwayat order tetap tercatat)
+func RemovePayerMember(c *gin.Context) {
+	res := config.DB.Model(&models.PayerMember{}).
+		Where("id = ? AND organization_id = ?", c.Param("memberId"), c.Param("id")).
+		Update("is_active", false)
+	if res.Error != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengeluarkan anggota", res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.APIResponse(c, http.StatusNotFound, false, "Anggota tidak ditemukan", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Anggota dikeluarkan", nil)
+}
+
+// AddPayerCoverage menambah aturan tanggungan (service_id kosong = semua layanan)
+func AddPayerCoverage(c *gin.Context) {
+	var org models.PayerOrganization
+	if err := config.DB.First(&org, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Organisasi tidak ditemukan", nil)
+		return
+	}
+
+	var input models.PayerCoverageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+	if input.ServiceID != nil {
+		var service models.Service
+		if err := config.DB.First(&service, *input.ServiceID).Error; err != nil {
+			utils.APIResponse(c, http.StatusNotFound, false, "Layanan tidak ditemukan", nil)
+			return
+		}
+	}
+
+	// Satu aturan per layanan (dan satu aturan umum): aturan lama untuk layanan yang sama diganti
+	rule := models.PayerCoverage{OrganizationID: org.ID, ServiceID: input.ServiceID}
+	query := config.DB.Where("organization_id = ?", org.ID)
+	if input.ServiceID != nil {
+		query = query.Where("service_id = ?", *input.ServiceID)
+	} else {
+		query = query.Where("service_id IS NULL")
+	}
+	query.First(&rule)
+	rule.CoverageBps = money.PercentToBps(input.CoveragePercent)
+	rule.MaxPerOrder = input.MaxPerOrder
+	if err := config.DB.Save(&rule).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan aturan tanggungan", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Aturan tanggungan disimpan", rule)
+}
+
+// DeletePayerCoverage menghapus aturan tanggungan
+func DeletePayerCoverage(c *gin.Context) {
+	res := config.DB.Where("id = ? AND organization_id = ?", c.Param("coverageId"), c.Param("id")).
+		Delete(&models.PayerCoverage{})
+	if res.Error != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghapus aturan tanggungan", res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.APIResponse(c, http.StatusNotFound, false, "Aturan tanggungan tidak ditemukan", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Aturan tanggungan dihapus", nil)
+}
+
+// === FITUR FINANCE (TAGIHAN BULANAN & PIUTANG) ===
+
+// GetOrgInvoices daftar tagihan bulanan (filter ?organization_id=..&status=ISSUED&overdue=true)
+func GetOrgInvoices(c *gin.Context) {
+	query := config.DB.Preload("Organization").Order("id desc").Limit(200)
+	if orgID := c.Query("organization_id"); orgID != "" {
+		query = query.Where("organization_id = ?", orgID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if c.Query("overdue") == "true" {
+		query = query.Where("status <> ? AND due_at < ?", models.OrgInvoicePaid, time.Now())
+	}
+
+	var invoices []models.OrgInvoice
+	query.Find(&invoices)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Tagihan Organisasi", invoices)
+}
+
+// GetOrgInvoiceDetail detail tagihan: daftar order & riwayat pembayaran
+func GetOrgInvoiceDetail(c *gin.Context) {
+	inv, ok := loadOrgInvoice(c)
+	if !ok {
+		return
+	}
+	config.DB.Where("org_invoice_id = ?", inv.ID).Order("id asc").Find(&inv.Payments)
+
+	utils.APIResponse(c, http.StatusOK, true, "Detail Tagihan Organisasi", inv)
+}
+
+// DownloadOrgInvoice unduh PDF tagihan bulanan (dikirim ke organisasi)
+func DownloadOrgInvoice(c *gin.Context) {
+	inv, ok := loadOrgInvoice(c)
+	if !ok {
+		return
+	}
+
+	filename := strings.ReplaceAll(inv.InvoiceNo, "/", "-") + ".pdf"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", "application/pdf")
+	if err := payer.Render(&inv, c.Writer); err != nil {
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+func loadOrgInvoice(c *gin.Context) (models.OrgInvoice, bool) {
+	var inv models.OrgInvoice
+	err := config.DB.Preload("Organization").
+		Preload("Orders", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Orders.Patient").Preload("Orders.Service").
+		First(&inv, c.Param("id")).Error
+	if err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Tagihan tidak ditemukan", nil)
+		return inv, false
+	}
+	return inv, true
+}
+
+// GenerateOrgInvoices menerbitkan tagihan bulanan manual (default bulan lalu), misal setelah job gagal
+func GenerateOrgInvoices(c *gin.Context) {
+	var input struct {
+		Period string `json:"period"` // Format YYYY-MM
+	}
+	c.ShouldBindJSON(&input)
+
+	period := payer.MonthStart(time.Now()).AddDate(0, -1, 0)
+	if input.Period != "" {
+		parsed, err := time.ParseInLocation("2006-01", input.Period, time.Local)
+		if err != nil {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Format periode harus YYYY-MM", nil)
+			return
+		}
+		period = parsed
+	}
+
+	issued, err := payer.GenerateInvoices(config.DB, period)
+	if errors.Is(err, payer.ErrInvalidPeriod) {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat tagihan", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, fmt.Sprintf("%d tagihan diterbitkan", len(issued)), issued)
+}
+
+// RecordOrgPayment mencatat transfer dari organisasi (boleh sebagian / dicicil)
+func RecordOrgPayment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "ID tagihan tidak valid", nil)
+		return
+	}
+
+	var input struct {
+		Amount    money.Money `json:"amount" binding:"required,min=1"`
+		PaidAt    *time.Time  `json:"paid_at"` // Default sekarang
+		Reference string      `json:"reference" binding:"required"`
+		Note      string      `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+	paidAt := time.Now()
+	if input.PaidAt != nil {
+		paidAt = *input.PaidAt
+	}
+
+	p, err := payer.RecordPayment(config.DB, invoiceID, input.Amount, paidAt, input.Reference, input.Note, userID.(uint64))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.APIResponse(c, http.StatusNotFound, false, "Tagihan tidak ditemukan", nil)
+	case errors.Is(err, payer.ErrInvoicePaid), errors.Is(err, payer.ErrOverpayment):
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+	case err != nil:
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mencatat pembayaran", err.Error())
+	default:
+		utils.APIResponse(c, http.StatusCreated, true, "Pembayaran dicatat", p)
+	}
+}
+
+// GetReceivableAging laporan umur piutang organisasi penjamin (?as_of=YYYY-MM-DD, default hari ini)
+func GetReceivableAging(c *gin.Context) {
+	asOf := time.Now()
+	if s := c.Query("as_of"); s != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Format tanggal harus YYYY-MM-DD", nil)
+			return
+		}
+		asOf = parsed.AddDate(0, 0, 1).Add(-time.Second) // Akhir hari
+	}
+
+	rows, err := payer.Aging(config.DB, asOf)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menghitung umur piutang", err.Error())
+		return
+	}
+
+	var total payer.AgingRow
+	for _, r := range rows {
+		total.Unbilled += r.Unbilled
+		total.Current += r.Current
+		total.Days1To30 += r.Days1To30
+		total.Days31To60 += r.Days31To60
+		total.Days61To90 += r.Days61To90
+		total.Over90 += r.Over90
+		total.Total += r.Total
+	}
+	total.Name = "TOTAL"
+
+	utils.APIResponse(c, http.StatusOK, true, "Umur Piutang Organisasi", gin.H{
+		"as_of":         asOf.Format("2006-01-02"),
+		"organizations": rows,
+		"total":         total,
+	})
+}
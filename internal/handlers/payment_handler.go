@@ -1,156 +1,345 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"homecare-backend/internal/billing"
 	"homecare-backend/internal/config"
 	"homecare-backend/internal/models"
+	"homecare-backend/pkg/payment"
 	"homecare-backend/pkg/utils"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// Struct sederhana untuk menangkap body notifikasi Midtrans
-// Midtrans mengirim JSON banyak field, tapi kita cuma butuh ini dulu
-type MidtransNotification struct {
-	TransactionStatus string `json:"transaction_status"`
-	OrderID           string `json:"order_id"`
-	FraudStatus       string `json:"fraud_status"`
-}
-
+// HandleMidtransNotification menerima webhook pembayaran dari payment gateway (Midtrans / fake).
+// Route ini publik, jadi isi notifikasi TIDAK dipercaya begitu saja:
+// 1. Signature wajib valid (dicek oleh gateway, Midtrans: SHA512 order_id+status_code+gross_amount+server key)
+// 2. Nominal wajib sama dengan total order
+// 3. Opsional (PAYMENT_VERIFY_STATUS=true): status dicek ulang langsung ke API gateway
+// Setiap notifikasi disimpan mentah di payment_events. Gateway sering retry, jadi notifikasi yang sama
+// (transaction_id + status) hanya diproses sekali, dan push notif hanya dikirim kalau status order benar-benar berubah.
 func HandleMidtransNotification(c *gin.Context) {
-	var notification MidtransNotification
+	// 1. Simpan body mentah dulu (untuk audit & replay)
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Invalid body", nil)
+		return
+	}
 
-	// 1. Decode JSON dari Midtrans
-	if err := c.ShouldBindJSON(&notification); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Invalid JSON", nil)
+	event := models.PaymentEvent{RawPayload: string(raw), IPAddress: c.ClientIP()}
+	code, msg := processPaymentEvent(&event, raw, false)
+	if code != http.StatusOK {
+		utils.APIResponse(c, code, false, msg, nil)
 		return
 	}
 
-	// 2. Tentukan Status Order Internal berdasarkan Status Midtrans
-	var orderStatus string
+	// Response OK ke gateway (Wajib biar gateway tau kita udah terima)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "result": event.Result})
+}
+
+// processPaymentEvent memproses satu notifikasi & mencatat hasilnya di event. Return HTTP status & pesan.
+// replay=true dipakai Finance untuk memproses ulang event lama (tanpa cek duplikat).
+func processPaymentEvent(event *models.PaymentEvent, raw []byte, replay bool) (int, string) {
+	gateway := payment.Default
+	event.Provider = gateway.Name()
+
+	// 1. Baca & verifikasi signature (tanpa ini siapa pun yang tahu order_no bisa menandai order PAID)
+	notification, err := gateway.ParseWebhook(raw)
+	event.OrderNo = notification.OrderNo
+	event.TransactionID = notification.TransactionID
+	event.TransactionStatus = notification.RawStatus
+	event.FraudStatus = notification.FraudStatus
+	event.GrossAmount = notification.GrossAmount
+	event.SignatureValid = !errors.Is(err, payment.ErrInvalidSignature) && !errors.Is(err, payment.ErrInvalidPayload)
+
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		log.Printf("[Webhook] ⚠️ Signature tidak valid - OrderID: %s, IP: %s", notification.OrderNo, event.IPAddress)
+		finishPaymentEvent(event, models.PaymentEventRejected, "invalid signature")
+		return http.StatusForbidden, "Invalid signature"
+	}
+	if err != nil {
+		finishPaymentEvent(event, models.PaymentEventRejected, err.Error())
+		return http.StatusBadRequest, "Invalid notification"
+	}
+
+	// 2. Dedup: notifikasi yang sama (retry gateway) cukup dicatat, tidak diproses ulang
+	if !replay {
+		if original, dup := claimPaymentEvent(event); dup {
+			log.Printf("[Webhook] Notifikasi duplikat - OrderID: %s, Status: %s (event #%d)", notification.OrderNo, notification.RawStatus, original)
+			event.DuplicateOfID = &original
+			finishPaymentEvent(event, models.PaymentEventDuplicate, "")
+			return http.StatusOK, "Duplicate"
+		}
+	}
 
-	switch notification.TransactionStatus {
-	case "capture":
-		if notification.FraudStatus == "challenge" {
-			orderStatus = "PENDING_PAYMENT" // Masih diverifikasi bank
-		} else if notification.FraudStatus == "accept" {
-			orderStatus = "PAID" // Sukses CC
+	// 3. Cek ulang status ke API gateway (opsional, sumber kebenaran = gateway, bukan body webhook)
+	if os.Getenv("PAYMENT_VERIFY_STATUS") == "true" {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		status, err := gateway.QueryStatus(ctx, notification.OrderNo)
+		cancel()
+		if err != nil {
+			// Balas non-200 supaya gateway mengirim ulang notifikasi nanti
+			log.Printf("[Webhook] Gagal cek status ke %s - OrderID: %s, Error: %v", gateway.Name(), notification.OrderNo, err)
+			finishPaymentEvent(event, models.PaymentEventError, "cek status gagal: "+err.Error())
+			return http.StatusBadGateway, "Failed to verify transaction status"
 		}
-	case "settlement":
-		orderStatus = "PAID" // Sukses Transfer Bank/Gopay
-	case "deny", "cancel", "expire":
-		orderStatus = "CANCELLED" // Gagal
-	case "pending":
-		orderStatus = "PENDING_PAYMENT"
-	default:
-		orderStatus = "PENDING_PAYMENT"
-	}
-
-	// 3. Log webhook received
-	log.Printf("[Webhook] Midtrans notification received - OrderID: %s, TransactionStatus: %s, FraudStatus: %s, MappedStatus: %s",
-		notification.OrderID, notification.TransactionStatus, notification.FraudStatus, orderStatus)
-
-	// 4. Update Database
-	// Cari order berdasarkan Order ID (Midtrans kirim INV-xxxx)
-	var order models.Order
-	if err := config.DB.Where("order_no = ?", notification.OrderID).First(&order).Error; err != nil {
+		notification = status
+	}
+
+	// 4. Tentukan Status Order Internal berdasarkan status pembayaran
+	orderStatus := billing.OrderStatusFor(notification.Status)
+	event.OrderStatusAfter = orderStatus
+
+	log.Printf("[Webhook] %s notification received - OrderID: %s, TransactionStatus: %s, FraudStatus: %s, MappedStatus: %s",
+		gateway.Name(), notification.OrderNo, notification.RawStatus, notification.FraudStatus, orderStatus)
+
+	// Tagihan top-up saldo (TOPUP-xxxx) diproses terpisah dari order layanan
+	if billing.IsTopup(notification.OrderNo) {
+		return processTopupEvent(event, notification)
+	}
+
+	// 5. Cari order berdasarkan Order ID (gateway kirim INV-xxxx, atau INV-xxxx-R2 untuk sesi "Bayar Ulang")
+	order, attempt, err := billing.ResolveOrder(config.DB, notification.OrderNo)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("[Webhook] Order not found: %s", notification.OrderID)
-			utils.APIResponse(c, http.StatusNotFound, false, "Order Not Found", nil)
-			return
+			log.Printf("[Webhook] Order not found: %s", notification.OrderNo)
+			finishPaymentEvent(event, models.PaymentEventRejected, "order not found")
+			return http.StatusNotFound, "Order Not Found"
 		}
 		log.Printf("[Webhook] DB error fetching order: %v", err)
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Database error", err.Error())
-		return
+		finishPaymentEvent(event, models.PaymentEventError, err.Error())
+		return http.StatusInternalServerError, "Database error"
 	}
+	event.OrderID = &order.ID
+	event.OrderStatusBefore = order.Status
 
-	// 5. Jika status berubah, update ke database
-	if order.Status != orderStatus {
-		log.Printf("[Webhook] Updating order %s status from %s to %s", notification.OrderID, order.Status, orderStatus)
-		order.Status = orderStatus
-		if err := config.DB.Save(&order).Error; err != nil {
-			log.Printf("[Webhook] DB error updating order: %v", err)
-			utils.APIResponse(c, http.StatusInternalServerError, false, "Failed to update order", err.Error())
-			return
+	// 6. Nominal yang dibayar harus sama persis dengan tagihan sesi tsb (total dikurangi potongan saldo)
+	expected := order.ChargeAmount()
+	if attempt != nil {
+		expected = attempt.Amount
+	}
+	if notification.Amount != expected {
+		log.Printf("[Webhook] ⚠️ Gross amount tidak cocok - OrderID: %s, Gateway: %s, Order: %d",
+			notification.OrderNo, notification.GrossAmount, expected.Int64())
+		finishPaymentEvent(event, models.PaymentEventRejected, "gross amount mismatch")
+		return http.StatusBadRequest, "Gross amount mismatch"
+	}
+
+	if attempt != nil {
+		superseded := attempt.Status == models.AttemptSuperseded
+		billing.MarkAttempt(config.DB, attempt, notification.Status)
+
+		// Sesi lama yang kedaluwarsa/dibatalkan tidak boleh membatalkan order, customer masih bisa bayar lewat sesi terbaru
+		if superseded && orderStatus == "CANCELLED" {
+			log.Printf("[Webhook] Sesi lama %s %s, order %s tetap menunggu pembayaran", notification.OrderNo, notification.RawStatus, order.OrderNo)
+			event.OrderStatusAfter = order.Status
+			finishPaymentEvent(event, models.PaymentEventIgnored, "superseded attempt")
+			return http.StatusOK, "OK"
 		}
-		log.Printf("[Webhook] Order %s status successfully updated to %s", notification.OrderID, orderStatus)
-	} else {
-		log.Printf("[Webhook] Order %s status unchanged (already %s)", notification.OrderID, orderStatus)
-	}
-
-	// 7. KIRIM NOTIFIKASI JIKA PAID (NEW ORDER)
-	if orderStatus == "PAID" {
-		// A. Notifikasi ke Customer (Payment Success)
-		var customer models.User
-		if err := config.DB.First(&customer, order.CustomerID).Error; err == nil {
-			if customer.FCMToken != "" {
-				utils.SendNotification(
-					customer.FCMToken,
-					"Pembayaran Berhasil! ✅",
-					"Terima kasih! Pembayaran Anda telah diterima. Kami sedang mencarikan Mitra untuk Anda.",
-					map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "payment_success"},
-				)
-			}
+	}
+
+	// 7. Terapkan perubahan status (hanya dari PENDING_PAYMENT, jadi notifikasi telat tidak bisa memundurkan status)
+	changed, err := billing.ApplyPaymentStatus(config.DB, &order, orderStatus)
+	if err != nil {
+		log.Printf("[Webhook] DB error updating order: %v", err)
+		finishPaymentEvent(event, models.PaymentEventError, err.Error())
+		return http.StatusInternalServerError, "Failed to update order"
+	}
+	if !changed {
+		log.Printf("[Webhook] Order %s status unchanged (%s)", notification.OrderNo, order.Status)
+		event.OrderStatusAfter = order.Status
+		finishPaymentEvent(event, models.PaymentEventIgnored, "")
+		return http.StatusOK, "OK"
+	}
+
+	log.Printf("[Webhook] Order %s status successfully updated to %s", notification.OrderNo, orderStatus)
+	finishPaymentEvent(event, models.PaymentEventApplied, "")
+
+	// 8. Efek samping (push notif) HANYA kalau status benar-benar berubah
+	billing.NotifyPaymentStatus(config.DB, order, orderStatus)
+	return http.StatusOK, "OK"
+}
+
+// processTopupEvent menerapkan notifikasi pembayaran untuk tagihan top-up saldo customer
+func processTopupEvent(event *models.PaymentEvent, notification payment.Notification) (int, string) {
+	var trx models.WalletTransaction
+	if err := config.DB.Where("payment_ref = ? AND type = ?", notification.OrderNo, billing.TrxTopup).First(&trx).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[Webhook] Top-up not found: %s", notification.OrderNo)
+			finishPaymentEvent(event, models.PaymentEventRejected, "order not found")
+			return http.StatusNotFound, "Order Not Found"
 		}
+		finishPaymentEvent(event, models.PaymentEventError, err.Error())
+		return http.StatusInternalServerError, "Database error"
+	}
+	event.OrderStatusBefore = trx.Status
+
+	if notification.Amount != trx.Amount {
+		log.Printf("[Webhook] ⚠️ Gross amount top-up tidak cocok - OrderID: %s, Gateway: %s, Top-up: %d",
+			notification.OrderNo, notification.GrossAmount, trx.Amount.Int64())
+		finishPaymentEvent(event, models.PaymentEventRejected, "gross amount mismatch")
+		return http.StatusBadRequest, "Gross amount mismatch"
+	}
+
+	changed, err := billing.ApplyTopupStatus(config.DB, &trx, notification.Status)
+	event.OrderStatusAfter = trx.Status
+	if err != nil {
+		log.Printf("[Webhook] DB error updating top-up: %v", err)
+		finishPaymentEvent(event, models.PaymentEventError, err.Error())
+		return http.StatusInternalServerError, "Failed to update top-up"
+	}
+	if !changed {
+		finishPaymentEvent(event, models.PaymentEventIgnored, "")
+		return http.StatusOK, "OK"
+	}
+
+	log.Printf("[Webhook] Top-up %s status successfully updated to %s", notification.OrderNo, trx.Status)
+	finishPaymentEvent(event, models.PaymentEventApplied, "")
 
-		// B. Cek Direct Booking atau Open Booking
-		if order.PartnerID != nil {
-			// --- DIRECT BOOKING ---
-			// Kita butuh User ID dari partner_profile, tapi di Order cuma ada PartnerID (Profile ID)
-			// Jadi kita harus join ke tabel partner_profiles lalu ke users
-			var profile models.PartnerProfile
-			if err := config.DB.Preload("User").First(&profile, *order.PartnerID).Error; err == nil {
-				// Kirim Notif ke Mitra
-				if profile.User.FCMToken != "" {
-					utils.SendNotification(
-						profile.User.FCMToken,
-						"Order Baru Masuk! 🔔",
-						"Ada pasien yang memesan jasa Anda secara khusus. Segera konfirmasi!",
-						map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "new_order_direct"},
-					)
-				}
-			}
-		} else {
-			// --- OPEN BOOKING (BROADCAST) ---
-			// Cari mitra di sekitar pasien (Logic mirip SearchPartners)
-			// Kita butuh koordinat pasien. Asumsi: Pasien ada di alamat yg tersimpan (Next: Order harus punya lat/lng sendiri)
-			// Untuk sekarang, kita broadcast ke SEMUA mitra yang ONLINE saja dulu atau radius jika memungkinkan.
-			// Simplifikasi: Broadcast ke semua mitra aktif yang punya token.
-
-			var activePartners []models.PartnerProfile
-			config.DB.Preload("User").Where("is_active = ?", true).Find(&activePartners)
-
-			for _, p := range activePartners {
-				if p.User.FCMToken != "" {
-					go utils.SendNotification( // Pakai goroutine biar gak blocking
-						p.User.FCMToken,
-						"Lowongan Job Baru! 📢",
-						"Ada order baru di area sekitar Anda. Cek sekarang sebelum diambil orang lain!",
-						map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "new_order_open"},
-					)
-				}
-			}
+	// Kabari customer hasil top-up
+	var w models.Wallet
+	if err := config.DB.Preload("User").First(&w, trx.WalletID).Error; err == nil && w.User.FCMToken != "" {
+		title := "Top-up Berhasil! 💰"
+		body := fmt.Sprintf("Saldo sebesar %s sudah masuk ke dompet Anda.", trx.Amount)
+		if trx.Status != "SUCCESS" {
+			title = "Top-up Gagal ❌"
+			body = "Pembayaran top-up saldo gagal atau kedaluwarsa."
 		}
-	} else if orderStatus == "CANCELLED" {
-		// 8. KIRIM NOTIFIKASI JIKA CANCELLED (Payment Failed/Expired)
-		// Cari User Customer
-		var customer models.User
-		if err := config.DB.First(&customer, order.CustomerID).Error; err == nil {
-			if customer.FCMToken != "" {
-				utils.SendNotification(
-					customer.FCMToken,
-					"Pembayaran Gagal/Expired ❌",
-					"Maaf, pesanan Anda dibatalkan karena pembayaran gagal atau waktu habis.",
-					map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "order_cancelled"},
-				)
-			}
+		utils.SendNotification(w.User.FCMToken, title, body, map[string]string{
+			"transaction_id": fmt.Sprintf("%d", trx.ID),
+			"type":           "wallet_topup",
+		})
+	}
+	return http.StatusOK, "OK"
+}
+
+// claimPaymentEvent menyimpan event dengan kunci dedup. Kalau kunci sudah dipakai event lain, return (id event itu, true).
+// Event lama yang gagal diproses (ERROR) dilepas kuncinya supaya retry dari Midtrans bisa diproses lagi.
+func claimPaymentEvent(event *models.PaymentEvent) (uint64, bool) {
+	key := fmt.Sprintf("%s:%s:%s", event.TransactionID, event.TransactionStatus, event.FraudStatus)
+	if event.TransactionID == "" {
+		key = fmt.Sprintf("%s:%s:%s", event.OrderNo, event.TransactionStatus, event.FraudStatus)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		event.DedupKey = &key
+		if err := config.DB.Create(event).Error; err == nil {
+			return 0, false
+		}
+
+		var existing models.PaymentEvent
+		if err := config.DB.Where("dedup_key = ?", key).First(&existing).Error; err != nil {
+			break // Bukan bentrok kunci, proses saja tanpa dedup
+		}
+		if existing.Result != models.PaymentEventError {
+			event.DedupKey = nil
+			return existing.ID, true
 		}
+		config.DB.Model(&existing).Update("dedup_key", nil)
+	}
+
+	event.DedupKey = nil
+	return 0, false
+}
+
+// finishPaymentEvent mencatat hasil pemrosesan event
+func finishPaymentEvent(event *models.PaymentEvent, result, errMsg string) {
+	now := time.Now()
+	event.Result = result
+	event.Error = errMsg
+	event.ProcessedAt = &now
+	if len(event.Error) > 255 {
+		event.Error = event.Error[:255]
+	}
+	if err := config.DB.Save(event).Error; err != nil {
+		log.Printf("[Webhook] Gagal simpan payment event: %v", err)
+	}
+}
+
+// === FITUR FINANCE (AUDIT WEBHOOK) ===
+
+// GetPaymentEvents daftar notifikasi pembayaran yang masuk (filter ?order_no=INV-xxx&result=REJECTED)
+func GetPaymentEvents(c *gin.Context) {
+	query := config.DB.Omit("raw_payload").Order("id desc").Limit(200)
+	if orderNo := c.Query("order_no"); orderNo != "" {
+		query = query.Where("order_no = ?", orderNo)
+	}
+	if result := c.Query("result"); result != "" {
+		query = query.Where("result = ?", result)
+	}
+
+	var events []models.PaymentEvent
+	query.Find(&events)
+
+	utils.APIResponse(c, http.StatusOK, true, "Riwayat Notifikasi Pembayaran", events)
+}
+
+// GetPaymentEventDetail detail satu notifikasi termasuk body mentahnya
+func GetPaymentEventDetail(c *gin.Context) {
+	var event models.PaymentEvent
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Event tidak ditemukan", nil)
+		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Detail Notifikasi Pembayaran", event)
+}
+
+// ReplayPaymentEvent memproses ulang notifikasi tersimpan (misal dulu gagal karena DB down).
+// Tetap lewat cek signature & nominal, dan push notif hanya terkirim kalau status order berubah.
+func ReplayPaymentEvent(c *gin.Context) {
+	var event models.PaymentEvent
+	if err := config.DB.First(&event, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Event tidak ditemukan", nil)
+		return
+	}
+
+	event.ReplayCount++
+	event.DuplicateOfID = nil
+	code, msg := processPaymentEvent(&event, []byte(event.RawPayload), true)
+
+	utils.APIResponse(c, code, code == http.StatusOK, "Replay: "+msg, event)
+}
+
+// === SIMULATOR (KHUSUS PAYMENT_GATEWAY=fake) ===
+
+// SimulateFakePayment menggerakkan hasil pembayaran order di fake gateway (untuk testing tanpa Midtrans).
+// Body notifikasi dibuat & ditandatangani fake gateway, lalu diproses persis seperti webhook sungguhan.
+func SimulateFakePayment(c *gin.Context) {
+	fake, ok := payment.Default.(*payment.FakeGateway)
+	if !ok {
+		utils.APIResponse(c, http.StatusNotFound, false, "Simulator hanya aktif dengan PAYMENT_GATEWAY=fake", nil)
+		return
+	}
+
+	var input struct {
+		OrderNo string `json:"order_no" binding:"required"`
+		Status  string `json:"status" binding:"required,oneof=settlement pending expire cancel deny"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	raw, err := fake.Simulate(input.OrderNo, input.Status)
+	if errors.Is(err, payment.ErrNotFound) {
+		utils.APIResponse(c, http.StatusNotFound, false, "Tagihan tidak ditemukan di fake gateway", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	event := models.PaymentEvent{RawPayload: string(raw), IPAddress: c.ClientIP()}
+	code, msg := processPaymentEvent(&event, raw, false)
 
-	// 6. Response OK ke Midtrans (Wajib biar Midtrans tau kita udah terima)
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	utils.APIResponse(c, code, code == http.StatusOK, "Simulasi: "+msg, event)
 }
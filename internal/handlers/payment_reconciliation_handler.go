@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"homecare-backend/internal/billing"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/payment"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR FINANCE (REKONSILIASI PEMBAYARAN) ===
+
+// GetPaymentReconciliations daftar laporan rekonsiliasi harian (terbaru dulu)
+func GetPaymentReconciliations(c *gin.Context) {
+	var recons []models.PaymentReconciliation
+	config.DB.Order("date desc, id desc").Limit(90).Find(&recons)
+
+	utils.APIResponse(c, http.StatusOK, true, "Laporan Rekonsiliasi Pembayaran", recons)
+}
+
+// GetPaymentReconciliationDetail detail laporan beserta daftar selisihnya (filter ?status=OPEN)
+func GetPaymentReconciliationDetail(c *gin.Context) {
+	var recon models.PaymentReconciliation
+	if err := config.DB.First(&recon, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Laporan rekonsiliasi tidak ditemukan", nil)
+		return
+	}
+
+	query := config.DB.Where("reconciliation_id = ?", recon.ID).Order("id asc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Find(&recon.Items)
+
+	utils.APIResponse(c, http.StatusOK, true, "Detail Rekonsiliasi Pembayaran", recon)
+}
+
+// RunPaymentReconciliation menjalankan rekonsiliasi manual untuk satu tanggal (default kemarin)
+func RunPaymentReconciliation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input struct {
+		Date string `json:"date"` // Format YYYY-MM-DD
+	}
+	c.ShouldBindJSON(&input)
+
+	day := time.Now().AddDate(0, 0, -1)
+	if input.Date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", input.Date, time.Local)
+		if err != nil {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Format tanggal harus YYYY-MM-DD", nil)
+			return
+		}
+		day = parsed
+	}
+	if day.After(time.Now()) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Tanggal tidak boleh di masa depan", nil)
+		return
+	}
+
+	uid := userID.(uint64)
+	recon, err := billing.Reconcile(config.DB, payment.Default, day, &uid)
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menjalankan rekonsiliasi", err.Error())
+		return
+	}
+	config.DB.Where("reconciliation_id = ?", recon.ID).Order("id asc").Find(&recon.Items)
+
+	utils.APIResponse(c, http.StatusOK, true, "Rekonsiliasi selesai", recon)
+}
+
+// ResolvePaymentReconciliationItem menutup satu selisih setelah ditindaklanjuti (refund, koreksi manual, dll)
+func ResolvePaymentReconciliationItem(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Catatan tindak lanjut wajib diisi", err.Error())
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	item, err := billing.ResolveItem(config.DB, id, userID.(uint64), input.Note)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.APIResponse(c, http.StatusNotFound, false, "Item rekonsiliasi tidak ditemukan", nil)
+		return
+	}
+	if errors.Is(err, billing.ErrReconItemClosed) {
+		utils.APIResponse(c, http.StatusConflict, false, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menutup item", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Selisih ditandai selesai", item)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/payout"
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR MITRA ===
+
+// GetMyPayoutSetting menampilkan pengaturan pencairan otomatis Mitra
+func GetMyPayoutSetting(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	setting := models.PayoutSetting{UserID: userID.(uint64)}
+	config.DB.Preload("BankAccount").Where("user_id = ?", userID).First(&setting)
+
+	utils.APIResponse(c, http.StatusOK, true, "Pengaturan Pencairan", gin.H{
+		"setting":           setting,
+		"global_min_amount": payout.MinAmount(),
+	})
+}
+
+// UpdateMyPayoutSetting mengaktifkan / mematikan pencairan otomatis mingguan
+func UpdateMyPayoutSetting(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var input models.PayoutSettingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	// Batas minimal pribadi boleh 0 (= ikut batas global) atau >= batas global
+	if input.MinAmount < 0 || (input.MinAmount > 0 && input.MinAmount < payout.MinAmount()) {
+		utils.APIResponse(c, http.StatusBadRequest, false, fmt.Sprintf("Batas minimal pencairan paling kecil %s", payout.MinAmount()), nil)
+		return
+	}
+
+	// Auto payout wajib punya rekening tujuan milik sendiri yang sudah VERIFIED
+	var account models.BankAccount
+	if input.BankAccountID != nil {
+		if err := config.DB.Where("id = ? AND user_id = ?", *input.BankAccountID, userID).First(&account).Error; err != nil {
+			utils.APIResponse(c, http.StatusNotFound, false, "Rekening tidak ditemukan", nil)
+			return
+		}
+		if account.Status != "VERIFIED" {
+			utils.APIResponse(c, http.StatusBadRequest, false, "Rekening belum diverifikasi Finance", nil)
+			return
+		}
+	} else if input.AutoPayout {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Pilih rekening tujuan untuk pencairan otomatis", nil)
+		return
+	}
+
+	var setting models.PayoutSetting
+	config.DB.Where("user_id = ?", userID).First(&setting)
+	setting.UserID = userID.(uint64)
+	setting.AutoPayout = input.AutoPayout
+	setting.BankAccountID = input.BankAccountID
+	setting.MinAmount = input.MinAmount
+
+	if err := config.DB.Omit("BankAccount").Save(&setting).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan pengaturan", err.Error())
+		return
+	}
+
+	if input.BankAccountID != nil {
+		setting.BankAccount = &account
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Pengaturan Pencairan Disimpan", setting)
+}
+
+// === FITUR FINANCE ===
+
+// GetPayoutRuns daftar batch pencairan (filter ?status=DRAFT)
+func GetPayoutRuns(c *gin.Context) {
+	query := config.DB.Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var runs []models.PayoutRun
+	query.Find(&runs)
+
+	utils.APIResponse(c, http.StatusOK, true, "Daftar Batch Pencairan", runs)
+}
+
+// GetPayoutRunDetail detail batch beserta semua penarikan & rekening tujuannya
+func GetPayoutRunDetail(c *gin.Context) {
+	run, ok := loadPayoutRun(c, true)
+	if !ok {
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Detail Batch Pencairan", run)
+}
+
+// CreatePayoutRun mengumpulkan semua penarikan PENDING jadi satu batch untuk di-review.
+// collect_auto=true: sekalian buat penarikan untuk Mitra yang mengaktifkan auto payout.
+func CreatePayoutRun(c *gin.Context) {
+	financeID, _ := c.Get("userID")
+
+	var input struct {
+		CollectAuto bool `json:"collect_auto"`
+	}
+	c.ShouldBindJSON(&input) // Body opsional
+
+	if input.CollectAuto {
+		if _, err := payout.CollectAutoPayouts(config.DB, time.Now()); err != nil {
+			utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat auto payout", err.Error())
+			return
+		}
+	}
+
+	creator := financeID.(uint64)
+	run, err := payout.CreateRun(config.DB, &creator)
+	if errors.Is(err, payout.ErrEmptyRun) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Tidak ada penarikan PENDING untuk dibuatkan batch", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membuat batch", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusCreated, true, "Batch Pencairan Dibuat", run)
+}
+
+// RemovePayoutRunItem mengeluarkan satu penarikan dari batch DRAFT (misal perlu dicek dulu)
+func RemovePayoutRunItem(c *gin.Context) {
+	run, ok := loadPayoutRun(c, false)
+	if !ok {
+		return
+	}
+
+	trxID, _ := strconv.ParseUint(c.Param("trxId"), 10, 64)
+	err := payout.RemoveItem(config.DB, &run, trxID)
+	if errors.Is(err, payout.ErrRunState) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Item hanya bisa dikeluarkan dari batch DRAFT", nil)
+		return
+	}
+	if errors.Is(err, payout.ErrNotFound) {
+		utils.APIResponse(c, http.StatusNotFound, false, "Penarikan tidak ada di batch ini", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mengeluarkan item", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Penarikan Dikeluarkan dari Batch", run)
+}
+
+// CancelPayoutRun membatalkan batch DRAFT (penarikan kembali jadi PENDING biasa)
+func CancelPayoutRun(c *gin.Context) {
+	run, ok := loadPayoutRun(c, false)
+	if !ok {
+		return
+	}
+
+	err := payout.CancelRun(config.DB, &run)
+	if errors.Is(err, payout.ErrRunState) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Hanya batch DRAFT yang bisa dibatalkan", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal membatalkan batch", err.Error())
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Batch Dibatalkan", run)
+}
+
+// ApprovePayoutRun menyetujui semua penarikan di batch sekaligus.
+// method API = transfer otomatis lewat provider, BANK_FILE = download CSV untuk upload manual ke bank.
+func ApprovePayoutRun(c *gin.Context) {
+	financeID, _ := c.Get("userID")
+
+	var input struct {
+		Method string `json:"method" binding:"required,oneof=API BANK_FILE"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	run, ok := loadPayoutRun(c, false)
+	if !ok {
+		return
+	}
+
+	err := payout.ApproveRun(config.DB, &run, financeID.(uint64), input.Method)
+	if errors.Is(err, payout.ErrRunState) {
+		utils.APIResponse(c, http.StatusConflict, false, "Batch sudah diproses sebelumnya", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyetujui batch", err.Error())
+		return
+	}
+
+	msg := "Batch disetujui. Download file bank lalu tandai selesai setelah transfer."
+	if run.Method == models.PayoutMethodAPI {
+		// Transfer dikirim satu per satu di background
+		go payout.ExecuteRun(config.DB, disbursement.Default, run.ID)
+		msg = "Batch disetujui dan sedang diproses transfer"
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, msg, run)
+}
+
+// DownloadPayoutRunBankFile download CSV transfer massal untuk batch metode BANK_FILE
+func DownloadPayoutRunBankFile(c *gin.Context) {
+	run, ok := loadPayoutRun(c, true)
+	if !ok {
+		return
+	}
+	if run.Method != models.PayoutMethodBankFile || run.Status == models.PayoutRunDraft {
+		utils.APIResponse(c, http.StatusBadRequest, false, "File bank hanya untuk batch BANK_FILE yang sudah disetujui", nil)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("payout-run-%d.csv", run.ID)))
+	c.Header("Content-Type", "text/csv")
+	if err := payout.WriteBankFile(c.Writer, run); err != nil {
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// CompletePayoutRun mencatat hasil transfer manual (BANK_FILE).
+// Semua item dianggap berhasil kecuali yang dikirim di `failed` (saldonya dikembalikan ke Mitra).
+func CompletePayoutRun(c *gin.Context) {
+	var input struct {
+		Failed []struct {
+			ID     uint64 `json:"id" binding:"required"`
+			Reason string `json:"reason" binding:"required"`
+		} `json:"failed"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Input salah", err.Error())
+		return
+	}
+
+	run, ok := loadPayoutRun(c, false)
+	if !ok {
+		return
+	}
+
+	failed := map[uint64]string{}
+	for _, f := range input.Failed {
+		failed[f.ID] = f.Reason
+	}
+
+	settled, err := payout.CompleteBankFile(config.DB, &run, failed)
+	if errors.Is(err, payout.ErrRunState) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Hanya batch BANK_FILE yang sudah disetujui yang bisa ditandai selesai", nil)
+		return
+	}
+	if err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyelesaikan batch", err.Error())
+		return
+	}
+
+	config.DB.First(&run, run.ID)
+	utils.APIResponse(c, http.StatusOK, true, fmt.Sprintf("%d penarikan diselesaikan", settled), run)
+}
+
+// loadPayoutRun ambil batch dari :id, sekalian item & rekeningnya kalau withItems
+func loadPayoutRun(c *gin.Context, withItems bool) (models.PayoutRun, bool) {
+	var run models.PayoutRun
+	query := config.DB
+	if withItems {
+		query = query.
+			Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+			Preload("Items.BankAccount", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }) // Tetap tampil walau sudah dihapus Mitra
+	}
+	if err := query.First(&run, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Batch tidak ditemukan", nil)
+		return run, false
+	}
+	return run, true
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/tracking"
+	"homecare-backend/pkg/utils"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Jejak lokasi disimpan untuk order ASSIGNED yang mulai dalam X jam ke depan (atau yg sudah EN_ROUTE)
+const trackingWindow = 3 * time.Hour
+
+// === FITUR MITRA ===
+
+// PingLocation: aplikasi Mitra kirim lokasi berkala (misal tiap 10 detik saat app aktif)
+func PingLocation(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var input models.LocationPingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Koordinat tidak valid", err.Error())
+		return
+	}
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	now := time.Now()
+
+	// 1. Update Posisi Terakhir Mitra (dipakai SearchPartners)
+	config.DB.Model(&profile).Updates(map[string]interface{}{
+		"current_lat":         *input.Lat,
+		"current_lng":         *input.Lng,
+		"location_updated_at": now,
+	})
+
+	// 2. Simpan Jejak untuk Order yang sedang/akan didatangi
+	var orders []models.Order
+	config.DB.Preload("Patient").
+		Where("partner_id = ?", profile.ID).
+		Where("status = ? OR (status = ? AND schedule_start <= ?)", "EN_ROUTE", "ASSIGNED", now.Add(trackingWindow)).
+		Find(&orders)
+
+	tracked := []uint64{}
+	for _, order := range orders {
+		point := models.OrderLocation{
+			OrderID:    order.ID,
+			PartnerID:  profile.ID,
+			Lat:        *input.Lat,
+			Lng:        *input.Lng,
+			Accuracy:   input.Accuracy,
+			Speed:      input.Speed,
+			Heading:    input.Heading,
+			RecordedAt: now,
+		}
+		if err := config.DB.Create(&point).Error; err != nil {
+			continue
+		}
+		tracked = append(tracked, order.ID)
+
+		// 3. Broadcast ke customer yang sedang membuka layar tracking
+		tracking.Default.Publish(buildTrackingUpdate(order, point))
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Lokasi Diperbarui", gin.H{
+		"tracked_orders": tracked,
+	})
+}
+
+// DepartOrder: Mitra menekan tombol "Berangkat" (ASSIGNED -> EN_ROUTE)
+func DepartOrder(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+	orderID := c.Param("id")
+
+	var order models.Order
+	if err := config.DB.First(&order, orderID).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+
+	var profile models.PartnerProfile
+	if err := config.DB.Preload("User").Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	if order.PartnerID == nil || *order.PartnerID != profile.ID {
+		utils.APIResponse(c, http.StatusForbidden, false, "Bukan order Anda", nil)
+		return
+	}
+
+	if order.Status != "ASSIGNED" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Hanya order ASSIGNED yang bisa diberangkatkan", nil)
+		return
+	}
+
+	order.Status = "EN_ROUTE"
+	if err := config.DB.Save(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update status", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Status: EN ROUTE. Hati-hati di jalan!", order)
+
+	// Kirim Notifikasi ke Customer
+	var customer models.User
+	if err := config.DB.First(&customer, order.CustomerID).Error; err == nil {
+		if customer.FCMToken != "" {
+			utils.SendNotification(
+				customer.FCMToken,
+				"Mitra Dalam Perjalanan 🛵",
+				fmt.Sprintf("Mitra %s sedang menuju lokasi. Pantau posisinya di aplikasi.", profile.User.FullName),
+				map[string]string{"order_id": fmt.Sprintf("%d", order.ID), "type": "order_en_route"},
+			)
+		}
+	}
+}
+
+// === FITUR CUSTOMER ===
+
+// GetOrderTracking: posisi terakhir Mitra + ETA + jejak perjalanan
+func GetOrderTracking(c *gin.Context) {
+	order, ok := findTrackableOrder(c)
+	if !ok {
+		return
+	}
+
+	var trail []models.OrderLocation
+	config.DB.Where("order_id = ?", order.ID).Order("recorded_at asc").Find(&trail)
+
+	var latest interface{}
+	if len(trail) > 0 {
+		latest = buildTrackingUpdate(order, trail[len(trail)-1])
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Tracking Mitra", gin.H{
+		"order_id": order.ID,
+		"status":   order.Status,
+		"latest":   latest,
+		"trail":    trail,
+	})
+}
+
+// StreamOrderTracking: Server-Sent Events, customer menerima posisi Mitra secara realtime
+func StreamOrderTracking(c *gin.Context) {
+	order, ok := findTrackableOrder(c)
+	if !ok {
+		return
+	}
+
+	updates, unsubscribe := tracking.Default.Subscribe(order.ID)
+	defer unsubscribe()
+
+	// Kirim posisi terakhir dulu biar peta langsung terisi
+	var last models.OrderLocation
+	if err := config.DB.Where("order_id = ?", order.ID).Order("recorded_at desc").First(&last).Error; err == nil {
+		c.SSEvent("location", buildTrackingUpdate(order, last))
+	}
+
+	// Ping kosong berkala biar koneksi tidak diputus proxy (nginx default 60 detik)
+	keepAlive := time.NewTicker(20 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case update, open := <-updates:
+			if !open {
+				return false
+			}
+			c.SSEvent("location", update)
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// === HELPER ===
+
+// findTrackableOrder memastikan order milik customer yang login & masih dalam fase kunjungan
+func findTrackableOrder(c *gin.Context) (models.Order, bool) {
+	userID, _ := c.Get("userID")
+
+	var order models.Order
+	if err := config.DB.Preload("Patient").
+		Where("id = ? AND customer_id = ?", c.Param("id"), userID).
+		First(&order).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return order, false
+	}
+
+	switch order.Status {
+	case "ASSIGNED", "EN_ROUTE", "ON_DUTY":
+		return order, true
+	default:
+		utils.APIResponse(c, http.StatusBadRequest, false, "Tracking hanya tersedia saat Mitra sedang ditugaskan", nil)
+		return order, false
+	}
+}
+
+// buildTrackingUpdate menghitung jarak ke rumah pasien & estimasi waktu tiba
+func buildTrackingUpdate(order models.Order, point models.OrderLocation) tracking.Update {
+	update := tracking.Update{
+		OrderID:    order.ID,
+		Status:     order.Status,
+		Lat:        point.Lat,
+		Lng:        point.Lng,
+		RecordedAt: point.RecordedAt,
+	}
+
+	if order.Patient != nil && order.Patient.Lat != nil && order.Patient.Lng != nil {
+		distance := utils.HaversineKM(point.Lat, point.Lng, *order.Patient.Lat, *order.Patient.Lng)
+		update.DistanceKM = math.Round(distance*100) / 100
+		update.ETAMinutes = int(math.Ceil(utils.EstimateETA(distance, point.Speed).Minutes()))
+	}
+
+	return update
+}
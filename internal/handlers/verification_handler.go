@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// === FITUR MITRA ===
+
+// SubmitVerification: Mitra mengajukan (atau mengajukan ulang) verifikasi akun
+func SubmitVerification(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var input struct {
+		Notes string `json:"notes"` // Opsional: misal "Sudah upload ulang STR yang baru"
+	}
+	c.ShouldBindJSON(&input) // Body boleh kosong
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan. Harap lengkapi profil dulu.", nil)
+		return
+	}
+
+	// Minimal semua dokumen wajib sudah diupload (status apapun selain REJECTED/EXPIRED)
+	var uploadedTypes []string
+	config.DB.Model(&models.PartnerDocument{}).
+		Where("partner_id = ? AND status IN ?", profile.ID, []string{"PENDING", "APPROVED"}).
+		Distinct().
+		Pluck("type", &uploadedTypes)
+
+	uploaded := make(map[string]bool, len(uploadedTypes))
+	for _, t := range uploadedTypes {
+		uploaded[t] = true
+	}
+	missing := []string{}
+	for _, t := range models.RequiredPartnerDocuments {
+		if !uploaded[t] {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Upload dulu dokumen wajib: "+strings.Join(missing, ", "), gin.H{
+			"missing_documents": missing,
+		})
+		return
+	}
+
+	actor := mitraID.(uint64)
+	if err := transitionPartner(&profile, models.PartnerSubmitted, &actor, "", input.Notes); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Pengajuan verifikasi terkirim. Tunggu review Admin.", gin.H{
+		"verification_status": profile.VerificationStatus,
+	})
+}
+
+// GetMyVerification melihat status verifikasi + riwayat review (termasuk alasan penolakan)
+func GetMyVerification(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Profil Mitra belum dibuat", nil)
+		return
+	}
+
+	// Catatan internal reviewer (Notes) tidak ditampilkan ke Mitra
+	var history []models.PartnerVerificationLog
+	config.DB.
+		Select("id", "partner_id", "from_status", "to_status", "reason", "created_at").
+		Where("partner_id = ?", profile.ID).
+		Order("created_at desc").
+		Find(&history)
+
+	utils.APIResponse(c, http.StatusOK, true, "Status Verifikasi", gin.H{
+		"verification_status": profile.VerificationStatus,
+		"missing_documents":   missingPartnerDocuments(profile.ID),
+		"history":             history,
+	})
+}
+
+// === FITUR ADMIN ===
+
+// GetPartnerVerificationHistory melihat riwayat lengkap review satu Mitra
+func GetPartnerVerificationHistory(c *gin.Context) {
+	var history []models.PartnerVerificationLog
+	config.DB.
+		Preload("Actor").
+		Where("partner_id = ?", c.Param("id")).
+		Order("created_at desc").
+		Find(&history)
+
+	utils.APIResponse(c, http.StatusOK, true, "Riwayat Verifikasi Mitra", history)
+}
+
+// === HELPER ===
+
+// transitionPartner memindahkan status verifikasi Mitra + mencatat history + efek sampingnya
+// (aktif/nonaktif & user.is_verified), lalu mengirim notifikasi ke Mitra.
+func transitionPartner(profile *models.PartnerProfile, to string, actorID *uint64, reason, notes string) error {
+	from := profile.VerificationStatus
+	if from == "" {
+		from = models.PartnerDraft
+	}
+
+	if !models.CanTransitionPartner(from, to) {
+		return fmt.Errorf("Status %s tidak bisa diubah menjadi %s", from, to)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Update status dengan kondisi status lama (biar 2 admin tidak saling timpa)
+		updates := map[string]interface{}{"verification_status": to}
+		switch to {
+		case models.PartnerApproved:
+			updates["is_active"] = true
+		case models.PartnerRejected, models.PartnerSuspended:
+			updates["is_active"] = false
+		}
+
+		res := tx.Model(&models.PartnerProfile{}).
+			Where("id = ? AND verification_status = ?", profile.ID, from).
+			Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("Status verifikasi sudah diubah oleh orang lain, silakan refresh")
+		}
+
+		// users.is_verified tetap disinkronkan untuk kompatibilitas client lama
+		isVerified := to == models.PartnerApproved
+		if err := tx.Model(&models.User{}).Where("id = ?", profile.UserID).Update("is_verified", isVerified).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PartnerVerificationLog{
+			PartnerID:  profile.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			ActorID:    actorID,
+			Reason:     reason,
+			Notes:      notes,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	profile.VerificationStatus = to
+	notifyVerificationDecision(*profile, to, reason)
+	return nil
+}
+
+// notifyVerificationDecision mengirim FCM ke Mitra untuk setiap keputusan Admin
+func notifyVerificationDecision(profile models.PartnerProfile, status, reason string) {
+	var title, body string
+	switch status {
+	case models.PartnerInReview:
+		title = "Verifikasi Sedang Direview 🔎"
+		body = "Admin sedang memeriksa dokumen & profil Anda."
+	case models.PartnerApproved:
+		title = "Akun Mitra Terverifikasi! 🎉"
+		body = "Selamat! Akun Anda sudah aktif dan siap menerima order."
+	case models.PartnerRejected:
+		title = "Verifikasi Ditolak ❌"
+		body = "Verifikasi Anda ditolak: " + reason + ". Silakan perbaiki lalu ajukan ulang."
+	case models.PartnerSuspended:
+		title = "Akun Dibekukan ⚠️"
+		body = "Akun Mitra Anda dibekukan sementara: " + reason
+	default:
+		return // SUBMITTED dilakukan Mitra sendiri, tidak perlu notif
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, profile.UserID).Error; err == nil {
+		if user.FCMToken != "" {
+			utils.SendNotification(
+				user.FCMToken,
+				title,
+				body,
+				map[string]string{"partner_id": fmt.Sprintf("%d", profile.ID), "type": "verification_" + strings.ToLower(status)},
+			)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default radius geofence (meter) kalau GEOFENCE_RADIUS_METERS tidak diisi di .env
+const defaultGeofenceRadiusM = 200
+
+// Toleransi telat/pulang cepat sebelum ditandai di laporan (LATE_GRACE_MINUTES)
+const defaultLateGraceMinutes = 15
+
+// === FITUR MITRA ===
+
+// CheckOutOrder: Mitra check-out saat layanan selesai (sebelum submit jurnal medis)
+func CheckOutOrder(c *gin.Context) {
+	mitraID, _ := c.Get("userID")
+	orderID := c.Param("id")
+
+	var input models.GeofenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Koordinat lokasi (lat/lng) wajib dikirim saat check-out", err.Error())
+		return
+	}
+
+	// 1. Cari Order & Validasi Mitra
+	var order models.Order
+	if err := config.DB.Preload("Patient").First(&order, orderID).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+
+	var profile models.PartnerProfile
+	if err := config.DB.Where("user_id = ?", mitraID).First(&profile).Error; err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, "Profil Mitra tidak ditemukan", nil)
+		return
+	}
+
+	if order.PartnerID == nil || *order.PartnerID != profile.ID {
+		utils.APIResponse(c, http.StatusForbidden, false, "Bukan order Anda", nil)
+		return
+	}
+
+	if order.Status != "ON_DUTY" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Check-out hanya bisa dilakukan saat ON DUTY", nil)
+		return
+	}
+
+	// 2. Harus sudah check-in & belum check-out
+	var visit models.OrderVisit
+	if err := config.DB.Where("order_id = ?", order.ID).First(&visit).Error; err != nil || visit.CheckInAt == nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Data check-in tidak ditemukan", nil)
+		return
+	}
+	if visit.CheckOutAt != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Anda sudah check-out sebelumnya", visit)
+		return
+	}
+
+	// 3. Cek Geofence
+	distanceM, err := checkGeofence(order, visit, input)
+	if err != nil {
+		utils.APIResponse(c, http.StatusForbidden, false, err.Error(), gin.H{
+			"distance_m": distanceM,
+			"radius_m":   geofenceRadiusM(),
+		})
+		return
+	}
+
+	// 4. Catat Check-Out
+	now := time.Now()
+	visit.CheckOutAt = &now
+	visit.CheckOutLat = *input.Lat
+	visit.CheckOutLng = *input.Lng
+	visit.CheckOutDistanceM = distanceM
+	visit.ShortMinutes = minutesAfter(order.ScheduleEnd, now)
+	visit.WorkedMinutes = int(now.Sub(*visit.CheckInAt).Minutes())
+
+	if err := config.DB.Save(&visit).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal mencatat check-out", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Check-out berhasil. Silakan isi jurnal medis.", visit)
+}
+
+// === FITUR ADMIN ===
+
+// OverrideGeofence: Admin mengizinkan check-in/check-out di luar radius untuk satu order
+func OverrideGeofence(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Alasan override wajib diisi", err.Error())
+		return
+	}
+
+	var order models.Order
+	if err := config.DB.First(&order, c.Param("id")).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Order tidak ditemukan", nil)
+		return
+	}
+
+	switch order.Status {
+	case "ASSIGNED", "EN_ROUTE", "ON_DUTY":
+	default:
+		utils.APIResponse(c, http.StatusBadRequest, false, "Override hanya untuk order yang sedang berjalan", nil)
+		return
+	}
+
+	var visit models.OrderVisit
+	config.DB.Where("order_id = ?", order.ID).First(&visit)
+
+	reviewer := adminID.(uint64)
+	now := time.Now()
+	visit.OrderID = order.ID
+	if order.PartnerID != nil {
+		visit.PartnerID = *order.PartnerID
+	}
+	visit.OverrideBy = &reviewer
+	visit.OverrideReason = input.Reason
+	visit.OverrideAt = &now
+	// Order sudah ON_DUTY tapi belum ada check-in (misal mulai sebelum fitur check-in ada):
+	// catat check-in sekarang supaya Mitra tetap bisa check-out & dibayar
+	if order.Status == "ON_DUTY" && visit.CheckInAt == nil {
+		visit.CheckInAt = &now
+	}
+
+	if err := config.DB.Save(&visit).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal menyimpan override", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Geofence di-override. Mitra bisa check-in/out dari lokasi manapun untuk order ini.", visit)
+}
+
+// GetVisits: laporan kehadiran untuk payroll (?partner_id=, ?from=YYYY-MM-DD, ?to=YYYY-MM-DD, ?flag=late|short)
+func GetVisits(c *gin.Context) {
+	grace := envMinutes("LATE_GRACE_MINUTES", defaultLateGraceMinutes)
+
+	query := config.DB.Model(&models.OrderVisit{}).Order("check_in_at desc")
+
+	if partnerID := c.Query("partner_id"); partnerID != "" {
+		query = query.Where("partner_id = ?", partnerID)
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("check_in_at >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.ParseInLocation("2006-01-02", to, time.Local); err == nil {
+			query = query.Where("check_in_at < ?", t.AddDate(0, 0, 1))
+		}
+	}
+
+	switch c.Query("flag") {
+	case "late":
+		query = query.Where("late_minutes > ?", grace)
+	case "short":
+		query = query.Where("short_minutes > ?", grace)
+	}
+
+	var visits []models.OrderVisit
+	if err := query.Find(&visits).Error; err != nil {
+		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal memuat data kehadiran", nil)
+		return
+	}
+
+	utils.APIResponse(c, http.StatusOK, true, "Data Kehadiran Mitra", gin.H{
+		"grace_minutes": grace,
+		"visits":        visits,
+	})
+}
+
+// === HELPER ===
+
+// checkGeofence menghitung jarak Mitra (meter) ke alamat pasien.
+// Error kalau di luar radius & tidak ada override dari Admin.
+func checkGeofence(order models.Order, visit models.OrderVisit, input models.GeofenceInput) (float64, error) {
+	if order.Patient == nil || order.Patient.Lat == nil || order.Patient.Lng == nil {
+		if visit.OverrideBy != nil {
+			return 0, nil
+		}
+		return 0, errors.New("Koordinat alamat pasien belum diisi. Hubungi Admin untuk override.")
+	}
+
+	distanceM := math.Round(utils.HaversineKM(*input.Lat, *input.Lng, *order.Patient.Lat, *order.Patient.Lng) * 1000)
+
+	if visit.OverrideBy != nil {
+		return distanceM, nil
+	}
+
+	if radius := geofenceRadiusM(); distanceM > radius {
+		return distanceM, fmt.Errorf("Anda berada %.0f m dari lokasi pasien (maksimal %.0f m)", distanceM, radius)
+	}
+
+	return distanceM, nil
+}
+
+// geofenceRadiusM membaca radius geofence dari .env (GEOFENCE_RADIUS_METERS)
+func geofenceRadiusM() float64 {
+	val, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("GEOFENCE_RADIUS_METERS")), 64)
+	if err != nil || val <= 0 {
+		return defaultGeofenceRadiusM
+	}
+	return val
+}
+
+// envMinutes membaca angka menit dari .env, pakai default kalau kosong/salah
+func envMinutes(key string, def int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || val < 0 {
+		return def
+	}
+	return val
+}
+
+// minutesAfter: berapa menit t terlambat dari batas (0 kalau tidak terlambat)
+func minutesAfter(t, limit time.Time) int {
+	if !t.After(limit) {
+		return 0
+	}
+	return int(t.Sub(limit).Minutes())
+}
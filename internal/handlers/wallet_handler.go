@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"homecare-backend/internal/config"
+	"homecare-backend/internal/earnings"
 	"homecare-backend/internal/models"
+	"homecare-backend/internal/payout"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/money"
 	"homecare-backend/pkg/utils"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetMyWallet menampilkan saldo saat ini & riwayat transaksi
@@ -22,16 +29,27 @@ func GetMyWallet(c *gin.Context) {
 		config.DB.Create(&wallet)
 	}
 
+	// 2. Pisahkan saldo tersedia vs masih ditahan vs dibekukan (sengketa)
+	earnings.FillBalances(config.DB, &wallet)
+
 	utils.APIResponse(c, http.StatusOK, true, "Dompet Saya", wallet)
 }
 
-// RequestWithdrawal mengajukan penarikan dana
+// RequestWithdrawal mengajukan penarikan dana.
+// Client sebaiknya kirim header Idempotency-Key (misal UUID) supaya retry karena timeout tidak membuat penarikan dobel.
 func RequestWithdrawal(c *gin.Context) {
 	userID, _ := c.Get("userID")
+	roleID, _ := c.Get("roleID")
+
+	// Saldo customer (refund/promo/top-up) hanya untuk bayar order, tidak bisa dicairkan
+	if roleID.(uint) != 3 {
+		utils.APIResponse(c, http.StatusForbidden, false, "Penarikan dana khusus Mitra", nil)
+		return
+	}
+
 	var input struct {
-		Amount float64 `json:"amount" binding:"required,min=10000"` // Minimal tarik 10rb
-		Bank   string  `json:"bank" binding:"required"`
-		NoRek  string  `json:"no_rek" binding:"required"`
+		Amount        money.Money `json:"amount" binding:"required,min=10000"` // Minimal tarik 10rb
+		BankAccountID uint64      `json:"bank_account_id" binding:"required"`  // Rekening yang sudah VERIFIED
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -39,49 +57,70 @@ func RequestWithdrawal(c *gin.Context) {
 		return
 	}
 
-	// 1. Cek Saldo Cukup Gak?
-	var wallet models.Wallet
-	if err := config.DB.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Wallet tidak ditemukan", nil)
+	idemKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(idemKey) > 64 {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Idempotency-Key maksimal 64 karakter", nil)
 		return
 	}
 
-	if wallet.Balance < input.Amount {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Saldo tidak cukup!", nil)
+	var w models.Wallet
+	if err := config.DB.Where("user_id = ?", userID).First(&w).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Wallet tidak ditemukan", nil)
 		return
 	}
 
-	// 2. Buat Transaksi (Status: PENDING)
-	// Kita kurangi saldo DULU atau NANTI pas diapprove admin?
-	// Best Practice: Kurangi DULU (Lock Balance) biar gak ditarik double.
-	// Kalau admin tolak, baru balikin saldonya.
-
-	tx := config.DB.Begin()
-
-	// Kurangi Saldo
-	wallet.Balance -= input.Amount
-	if err := tx.Save(&wallet).Error; err != nil {
-		tx.Rollback()
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal update saldo", nil)
+	// Rekening tujuan harus milik sendiri & sudah diverifikasi Finance
+	var account models.BankAccount
+	if err := config.DB.Where("id = ? AND user_id = ?", input.BankAccountID, userID).First(&account).Error; err != nil {
+		utils.APIResponse(c, http.StatusNotFound, false, "Rekening tidak ditemukan", nil)
+		return
+	}
+	if account.Status != "VERIFIED" {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Rekening belum diverifikasi Finance", nil)
 		return
 	}
 
-	// Catat di History
-	transaction := models.WalletTransaction{
-		WalletID: wallet.ID,
-		Amount:   input.Amount,
-		Type:     "WITHDRAWAL", // Enum: INCOME, WITHDRAWAL
-		Status:   "PENDING",    // Enum: SUCCESS, PENDING, FAILED
-		// OrderID kosong karena ini transaksi manual
+	// 1. Request ulang dengan key yang sama -> kembalikan pengajuan yang sudah ada
+	if existing, ok := findWithdrawalByKey(w.ID, idemKey); ok {
+		utils.APIResponse(c, http.StatusOK, true, "Permintaan penarikan ini sudah diajukan sebelumnya.", existing)
+		return
 	}
 
-	if err := tx.Create(&transaction).Error; err != nil {
-		tx.Rollback()
+	// 2. Kurangi Saldo DULU (Lock Balance) biar gak ditarik double. Kalau admin tolak, baru balikin saldonya.
+	// Cek saldo & pengurangan dilakukan atomik di DB (UPDATE ... WHERE balance >= ?),
+	// jadi dua request paralel tidak bisa sama-sama lolos dan membuat saldo minus.
+	var transaction models.WalletTransaction
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, err = payout.Request(tx, w.ID, account, input.Amount, idemKey, nil)
+		return err
+	})
+
+	if errors.Is(err, wallet.ErrInsufficientBalance) {
+		utils.APIResponse(c, http.StatusBadRequest, false, "Saldo tidak cukup!", nil)
+		return
+	}
+	if err != nil {
+		// Bentrok unique (wallet_id, idempotency_key) = request paralel dengan key sama sudah duluan berhasil.
+		// Transaksi kita sudah di-rollback (saldo tidak terpotong dua kali), kembalikan yang sudah ada.
+		if existing, ok := findWithdrawalByKey(w.ID, idemKey); ok {
+			utils.APIResponse(c, http.StatusOK, true, "Permintaan penarikan ini sudah diajukan sebelumnya.", existing)
+			return
+		}
 		utils.APIResponse(c, http.StatusInternalServerError, false, "Gagal simpan transaksi", nil)
 		return
 	}
 
-	tx.Commit()
-
+	transaction.BankAccount = &account
 	utils.APIResponse(c, http.StatusCreated, true, "Permintaan penarikan berhasil diajukan. Tunggu konfirmasi Admin.", transaction)
 }
+
+// findWithdrawalByKey mencari pengajuan penarikan dengan Idempotency-Key yang sama
+func findWithdrawalByKey(walletID uint64, key string) (models.WalletTransaction, bool) {
+	var trx models.WalletTransaction
+	if key == "" {
+		return trx, false
+	}
+	err := config.DB.Where("wallet_id = ? AND idempotency_key = ?", walletID, key).First(&trx).Error
+	return trx, err == nil
+}
@@ -0,0 +1,161 @@
+// Package invoice menerbitkan invoice resmi untuk order yang sudah dibayar & merender PDF-nya.
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/sequence"
+	"homecare-backend/pkg/money"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotPaid = errors.New("order belum dibayar, invoice belum bisa diterbitkan")
+
+// Seller identitas perusahaan di kop invoice
+type Seller struct {
+	Name    string
+	Address string
+	NPWP    string
+}
+
+// SellerInfo dari .env: INVOICE_COMPANY_NAME, INVOICE_COMPANY_ADDRESS, INVOICE_COMPANY_NPWP
+func SellerInfo() Seller {
+	name := os.Getenv("INVOICE_COMPANY_NAME")
+	if name == "" {
+		name = "Homecare"
+	}
+	return Seller{Name: name, Address: os.Getenv("INVOICE_COMPANY_ADDRESS"), NPWP: os.Getenv("INVOICE_COMPANY_NPWP")}
+}
+
+// TaxRateBps tarif PPN dari INVOICE_PPN_RATE (persen, misal 11). Default 0 = perusahaan belum PKP, tanpa PPN.
+func TaxRateBps() int64 {
+	rate, err := strconv.ParseFloat(os.Getenv("INVOICE_PPN_RATE"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return money.PercentToBps(rate)
+}
+
+// taxOnService: jasa pelayanan kesehatan medis umumnya bebas PPN, jadi default hanya biaya admin platform yang kena.
+// INVOICE_PPN_ON_SERVICE=true kalau jasa layanan juga kena PPN.
+func taxOnService() bool {
+	return os.Getenv("INVOICE_PPN_ON_SERVICE") == "true"
+}
+
+// Issue menerbitkan invoice untuk order yang sudah dibayar. Idempoten: order yang sudah punya invoice
+// mengembalikan invoice yang sama (nomor tidak pernah dibuat dua kali).
+func Issue(db *gorm.DB, orderID uint64) (*models.Invoice, error) {
+	var inv models.Invoice
+	if err := db.Preload("Items").Where("order_id = ?", orderID).First(&inv).Error; err == nil {
+		return &inv, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Kunci order: dua request paralel tidak boleh menerbitkan dua nomor untuk order yang sama
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Service").Preload("Patient").Preload("Customer").Preload("PayerOrganization").
+			First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if order.PaidAt == nil {
+			return ErrNotPaid
+		}
+		if err := tx.Preload("Items").Where("order_id = ?", orderID).First(&inv).Error; err == nil {
+			return nil
+		}
+
+		inv = build(order)
+
+		// Nomor urut per tahun, diambil di transaksi yang sama supaya tidak ada nomor bolong
+		now := time.Now()
+		seq, err := sequence.Next(tx, fmt.Sprintf("INVOICE-%d", now.Year()))
+		if err != nil {
+			return err
+		}
+		inv.InvoiceNo = fmt.Sprintf("INV/%d/%02d/%06d", now.Year(), now.Month(), seq)
+		inv.IssuedAt = now
+
+		return tx.Create(&inv).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// build menyusun rincian & pajak dari data order (belum disimpan)
+func build(order models.Order) models.Invoice {
+	inv := models.Invoice{
+		OrderID:         order.ID,
+		CustomerID:      order.CustomerID,
+		BillToName:      order.Customer.FullName,
+		BillToEmail:     order.Customer.Email,
+		BillToPhone:     order.Customer.Phone,
+		ServiceDate:     order.ScheduleStart,
+		Total:           order.TotalAmount,
+		PaidWithBalance: order.BalanceUsed,
+		PaidViaGateway:  order.ChargeAmount(),
+		PaidByPayer:     order.PayerAmount,
+		PaidAt:          order.PaidAt,
+		TaxRateBps:      TaxRateBps(),
+	}
+	if order.Patient != nil {
+		inv.PatientName = order.Patient.Name
+	}
+	if order.PayerOrganization != nil {
+		inv.PayerName = order.PayerOrganization.Name
+	}
+
+	// Order lama belum menyimpan rincian harga: biaya admin diambil dari master layanan, sisanya jasa
+	serviceFee, adminFee := order.ServiceFee, order.AdminFee
+	if serviceFee == 0 && adminFee == 0 {
+		if order.Service != nil && order.Service.AdminFee <= order.TotalAmount {
+			adminFee = order.Service.AdminFee
+		}
+		serviceFee = order.TotalAmount - adminFee
+	}
+
+	serviceName := "Layanan Homecare"
+	if order.Service != nil {
+		serviceName = order.Service.Name
+	}
+	inv.Items = append(inv.Items, models.InvoiceItem{
+		Description: "Jasa " + serviceName, Qty: 1, UnitPrice: serviceFee, Amount: serviceFee, Taxable: taxOnService(),
+	})
+	if adminFee > 0 {
+		inv.Items = append(inv.Items, models.InvoiceItem{
+			Description: "Biaya Admin", Qty: 1, UnitPrice: adminFee, Amount: adminFee, Taxable: true,
+		})
+	}
+	inv.Subtotal = serviceFee + adminFee
+
+	// Selisih harga vs yang ditagihkan = diskon (mengurangi jasa layanan)
+	if discount := inv.Subtotal - order.TotalAmount; discount > 0 {
+		inv.Discount = discount
+		inv.Items = append(inv.Items, models.InvoiceItem{
+			Description: "Diskon", Qty: 1, UnitPrice: -discount, Amount: -discount, Taxable: taxOnService(),
+		})
+	}
+
+	// PPN termasuk dalam harga: DPP = bruto x 100 / (100 + tarif), PPN = bruto - DPP
+	if inv.TaxRateBps > 0 {
+		var taxable money.Money
+		for _, it := range inv.Items {
+			if it.Taxable {
+				taxable += it.Amount
+			}
+		}
+		if taxable > 0 {
+			inv.TaxBase = money.Money(taxable.Int64() * money.BpsScale / (money.BpsScale + inv.TaxRateBps))
+			inv.TaxAmount = taxable - inv.TaxBase
+		}
+	}
+	return inv
+}
@@ -0,0 +1,148 @@
+package invoice
+
+import (
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"homecare-backend/pkg/pdf"
+	"io"
+	"time"
+)
+
+// Posisi kolom tabel rincian (point dari kiri)
+const (
+	marginLeft  = 50.0
+	marginRight = pdf.PageWidth - 50
+	colQty      = 370.0
+	colPrice    = 455.0
+)
+
+// Render menulis invoice dalam format PDF (A4)
+func Render(inv *models.Invoice, w io.Writer) error {
+	seller := SellerInfo()
+	doc := pdf.New()
+
+	// 1. Kop perusahaan & judul
+	y := 60.0
+	doc.Text(marginLeft, y, 16, true, seller.Name)
+	doc.TextRight(marginRight, y, 18, true, "INVOICE")
+	y += 16
+	if seller.Address != "" {
+		doc.Text(marginLeft, y, 9, false, seller.Address)
+	}
+	doc.TextRight(marginRight, y, 10, false, inv.InvoiceNo)
+	y += 12
+	if seller.NPWP != "" {
+		doc.Text(marginLeft, y, 9, false, "NPWP: "+seller.NPWP)
+	}
+	y += 14
+	doc.Line(marginLeft, y, marginRight, y)
+
+	// 2. Info tagihan
+	y += 22
+	doc.Text(marginLeft, y, 10, true, "Ditagihkan kepada")
+	doc.Text(320, y, 10, true, "Detail")
+	info := [][2]string{
+		{"Tanggal Invoice", FormatDate(inv.IssuedAt)},
+		{"No. Order", orderNo(inv)},
+		{"Tanggal Layanan", FormatDate(inv.ServiceDate)},
+		{"Pasien", inv.PatientName},
+	}
+	billTo := []string{inv.BillToName, inv.BillToEmail, inv.BillToPhone}
+	for i := 0; i < len(info); i++ {
+		y += 14
+		if i < len(billTo) {
+			doc.Text(marginLeft, y, 10, false, billTo[i])
+		}
+		doc.Text(320, y, 9, false, info[i][0])
+		doc.TextRight(marginRight, y, 9, false, info[i][1])
+	}
+
+	// 3. Tabel rincian
+	y += 30
+	doc.Text(marginLeft, y, 10, true, "Deskripsi")
+	doc.TextRight(colQty, y, 10, true, "Qty")
+	doc.TextRight(colPrice, y, 10, true, "Harga")
+	doc.TextRight(marginRight, y, 10, true, "Jumlah")
+	y += 6
+	doc.Line(marginLeft, y, marginRight, y)
+	for _, it := range inv.Items {
+		y += 16
+		desc := it.Description
+		if it.Taxable && inv.TaxRateBps > 0 {
+			desc += " *"
+		}
+		doc.Text(marginLeft, y, 10, false, desc)
+		doc.TextRight(colQty, y, 10, false, fmt.Sprintf("%d", it.Qty))
+		doc.TextRight(colPrice, y, 10, false, it.UnitPrice.String())
+		doc.TextRight(marginRight, y, 10, false, it.Amount.String())
+	}
+	y += 8
+	doc.Line(marginLeft, y, marginRight, y)
+
+	// 4. Ringkasan
+	summary := func(label string, amount money.Money, bold bool) {
+		y += 16
+		doc.Text(colQty-60, y, 10, bold, label)
+		doc.TextRight(marginRight, y, 10, bold, amount.String())
+	}
+	summary("Subtotal", inv.Subtotal, false)
+	if inv.Discount > 0 {
+		summary("Diskon", -inv.Discount, false)
+	}
+	summary("Total", inv.Total, true)
+	if inv.TaxRateBps > 0 && inv.TaxAmount > 0 {
+		summary("DPP", inv.TaxBase, false)
+		summary(fmt.Sprintf("PPN %g%% (termasuk)", money.BpsToPercent(inv.TaxRateBps)), inv.TaxAmount, false)
+	}
+
+	// 5. Pembayaran
+	y += 30
+	doc.Text(marginLeft, y, 10, true, "Pembayaran")
+	if inv.PaidByPayer > 0 {
+		y += 14
+		doc.Text(marginLeft, y, 9, false, "Ditanggung "+inv.PayerName)
+		doc.TextRight(colPrice, y, 9, false, inv.PaidByPayer.String())
+	}
+	if inv.PaidWithBalance > 0 {
+		y += 14
+		doc.Text(marginLeft, y, 9, false, "Saldo Homecare")
+		doc.TextRight(colPrice, y, 9, false, inv.PaidWithBalance.String())
+	}
+	if inv.PaidViaGateway > 0 {
+		y += 14
+		doc.Text(marginLeft, y, 9, false, "Payment Gateway")
+		doc.TextRight(colPrice, y, 9, false, inv.PaidViaGateway.String())
+	}
+	if inv.PaidAt != nil {
+		y += 14
+		doc.Text(marginLeft, y, 9, false, "LUNAS pada "+inv.PaidAt.Format("02-01-2006 15:04"))
+	}
+
+	// 6. Catatan kaki
+	y = pdf.PageHeight - 60
+	doc.Line(marginLeft, y-12, marginRight, y-12)
+	if inv.TaxRateBps > 0 {
+		doc.Text(marginLeft, y, 8, false, "* Harga sudah termasuk PPN.")
+		y += 11
+	}
+	doc.Text(marginLeft, y, 8, false, "Invoice ini diterbitkan secara elektronik dan sah tanpa tanda tangan.")
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+func orderNo(inv *models.Invoice) string {
+	if inv.Order != nil {
+		return inv.Order.OrderNo
+	}
+	return fmt.Sprintf("#%d", inv.OrderID)
+}
+
+var monthNames = [...]string{"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli",
+	"Agustus", "September", "Oktober", "November", "Desember"}
+
+// FormatDate tanggal gaya Indonesia, misal 19 Oktober 2026
+func FormatDate(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), monthNames[t.Month()-1], t.Year())
+}
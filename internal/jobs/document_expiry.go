@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"fmt"
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// StartDocumentExpiryJob menjalankan pengecekan masa berlaku dokumen Mitra di background.
+// Config .env:
+// - DOCUMENT_CHECK_INTERVAL_HOURS (default 24)
+// - DOCUMENT_REMINDER_DAYS (default 30): pengingat dikirim H-30 sebelum kedaluwarsa
+func StartDocumentExpiryJob() {
+	interval := time.Duration(envInt("DOCUMENT_CHECK_INTERVAL_HOURS", 24)) * time.Hour
+	reminderDays := envInt("DOCUMENT_REMINDER_DAYS", 30)
+
+	go func() {
+		for {
+			checkDocumentExpiry(reminderDays)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// checkDocumentExpiry:
+// 1. Dokumen APPROVED yang lewat masa berlaku -> EXPIRED. Kalau STR/SIP, Mitra dinonaktifkan.
+// 2. Dokumen yang mau habis -> kirim pengingat (sekali saja).
+func checkDocumentExpiry(reminderDays int) {
+	now := time.Now()
+
+	// 1. Tandai Dokumen Kedaluwarsa
+	var expired []models.PartnerDocument
+	config.DB.Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", "APPROVED", now).Find(&expired)
+
+	for _, doc := range expired {
+		if err := config.DB.Model(&doc).Update("status", "EXPIRED").Error; err != nil {
+			log.Printf("[DocumentJob] Gagal update dokumen %d: %v", doc.ID, err)
+			continue
+		}
+
+		// STR/SIP mati = tidak boleh praktik -> matikan status ONLINE
+		if doc.Type == "STR" || doc.Type == "SIP" {
+			config.DB.Model(&models.PartnerProfile{}).Where("id = ?", doc.PartnerID).Update("is_active", false)
+			log.Printf("[DocumentJob] Mitra %d dinonaktifkan: %s kedaluwarsa", doc.PartnerID, doc.Type)
+		}
+
+		notifyPartner(doc.PartnerID,
+			"Dokumen Kedaluwarsa ⚠️",
+			fmt.Sprintf("%s Anda sudah habis masa berlakunya. Akun dinonaktifkan sementara, silakan upload dokumen terbaru.", doc.Type),
+			map[string]string{"document_id": fmt.Sprintf("%d", doc.ID), "type": "document_expired"},
+		)
+	}
+
+	// 2. Kirim Pengingat Sebelum Kedaluwarsa
+	var expiring []models.PartnerDocument
+	config.DB.
+		Where("status = ? AND reminder_sent_at IS NULL", "APPROVED").
+		Where("expires_at > ? AND expires_at <= ?", now, now.AddDate(0, 0, reminderDays)).
+		Find(&expiring)
+
+	for _, doc := range expiring {
+		daysLeft := int(doc.ExpiresAt.Sub(now).Hours() / 24)
+		notifyPartner(doc.PartnerID,
+			"Dokumen Segera Kedaluwarsa ⏳",
+			fmt.Sprintf("%s Anda akan habis dalam %d hari (%s). Segera perpanjang & upload ulang.", doc.Type, daysLeft, doc.ExpiresAt.Format("02-01-2006")),
+			map[string]string{"document_id": fmt.Sprintf("%d", doc.ID), "type": "document_expiring"},
+		)
+		config.DB.Model(&doc).Update("reminder_sent_at", now)
+	}
+
+	if len(expired) > 0 || len(expiring) > 0 {
+		log.Printf("[DocumentJob] %d dokumen kedaluwarsa, %d pengingat dikirim", len(expired), len(expiring))
+	}
+}
+
+// notifyPartner kirim FCM ke Mitra berdasarkan ID PartnerProfile
+func notifyPartner(partnerID uint64, title, body string, data map[string]string) {
+	var profile models.PartnerProfile
+	if err := config.DB.Preload("User").First(&profile, partnerID).Error; err != nil {
+		return
+	}
+	if profile.User.FCMToken != "" {
+		utils.SendNotification(profile.User.FCMToken, title, body, data)
+	}
+}
+
+// envInt membaca angka dari .env, pakai default kalau kosong/salah format
+func envInt(key string, def int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || val <= 0 {
+		return def
+	}
+	return val
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/earnings"
+	"log"
+	"time"
+)
+
+// StartEarningsReleaseJob melepas pendapatan Mitra yang masa tahannya sudah lewat ke saldo wallet.
+// Config .env:
+// - EARNINGS_HOLD_HOURS (default 72): lama pendapatan ditahan setelah order selesai
+// - EARNINGS_RELEASE_INTERVAL_MINUTES (default 60)
+func StartEarningsReleaseJob() {
+	interval := time.Duration(envInt("EARNINGS_RELEASE_INTERVAL_MINUTES", 60)) * time.Minute
+
+	go func() {
+		for {
+			released, err := earnings.ReleaseDue(config.DB, time.Now())
+			if err != nil {
+				log.Printf("[EarningsJob] Gagal melepas pendapatan: %v", err)
+			} else if released > 0 {
+				log.Printf("[EarningsJob] %d pendapatan Mitra dilepas ke saldo", released)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/ledger"
+	"log"
+	"time"
+)
+
+// StartLedgerReconciliationJob mengecek berkala apakah Wallet.Balance masih sama dengan ledger
+// & neraca saldo masih seimbang. Selisih dicatat di log untuk ditindaklanjuti Finance.
+// Config .env: LEDGER_RECONCILE_INTERVAL_HOURS (default 24)
+func StartLedgerReconciliationJob() {
+	interval := time.Duration(envInt("LEDGER_RECONCILE_INTERVAL_HOURS", 24)) * time.Hour
+
+	go func() {
+		for {
+			reconcileLedger()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func reconcileLedger() {
+	drifts, err := ledger.Reconcile(config.DB)
+	if err != nil {
+		log.Printf("[LedgerJob] Gagal rekonsiliasi wallet: %v", err)
+		return
+	}
+	for _, d := range drifts {
+		log.Printf("[LedgerJob] ⚠️ Wallet %d (user %d) selisih: wallet=%s ledger=%s diff=%s",
+			d.WalletID, d.UserID, d.WalletBalance, d.LedgerBalance, d.Difference)
+	}
+
+	trial, err := ledger.TrialBalance(config.DB)
+	if err != nil {
+		log.Printf("[LedgerJob] Gagal hitung neraca saldo: %v", err)
+		return
+	}
+	if !trial.Balanced {
+		log.Printf("[LedgerJob] ⚠️ Neraca saldo TIDAK seimbang: debit=%s kredit=%s", trial.TotalDebit, trial.TotalCredit)
+	}
+
+	log.Printf("[LedgerJob] Rekonsiliasi selesai: %d wallet selisih", len(drifts))
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/payer"
+	"log"
+	"time"
+)
+
+// StartOrgInvoiceJob tiap tanggal 1 menerbitkan tagihan bulanan gabungan untuk organisasi penjamin
+// (order anggota yang selesai di bulan sebelumnya).
+// Config .env: ORG_INVOICE_HOUR (default 6 = jam 06:00 tanggal 1)
+func StartOrgInvoiceJob() {
+	hour := envInt("ORG_INVOICE_HOUR", 6)
+
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), 1, hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 1, 0)
+			}
+			time.Sleep(time.Until(next))
+			issueOrgInvoices(next.AddDate(0, -1, 0))
+		}
+	}()
+}
+
+func issueOrgInvoices(period time.Time) {
+	issued, err := payer.GenerateInvoices(config.DB, period)
+	if err != nil {
+		log.Printf("[OrgInvoice] Gagal membuat tagihan periode %s: %v", period.Format("2006-01"), err)
+		return
+	}
+	log.Printf("[OrgInvoice] Tagihan periode %s: %d organisasi ditagih", period.Format("2006-01"), len(issued))
+}
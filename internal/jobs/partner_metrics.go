@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/metrics"
+	"log"
+	"time"
+)
+
+// StartPartnerMetricsJob menghitung ulang metrik & tier semua Mitra secara berkala.
+// Config .env: METRICS_REFRESH_INTERVAL_HOURS (default 6)
+func StartPartnerMetricsJob() {
+	interval := time.Duration(envInt("METRICS_REFRESH_INTERVAL_HOURS", 6)) * time.Hour
+
+	go func() {
+		for {
+			start := time.Now()
+			count, err := metrics.RefreshAll(config.DB)
+			if err != nil {
+				log.Printf("[MetricsJob] Gagal refresh metrik (%d Mitra selesai): %v", count, err)
+			} else {
+				log.Printf("[MetricsJob] Metrik %d Mitra diperbarui dalam %s", count, time.Since(start).Round(time.Millisecond))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"homecare-backend/internal/billing"
+	"homecare-backend/internal/config"
+	"homecare-backend/pkg/payment"
+	"log"
+	"time"
+)
+
+// StartPaymentReconciliationJob tiap hari mencocokkan order kemarin dengan status transaksi di gateway.
+// Selisih aman diperbaiki otomatis, sisanya masuk laporan rekonsiliasi untuk Finance.
+// Config .env: PAYMENT_RECONCILE_HOUR (default 2 = jam 02:00)
+func StartPaymentReconciliationJob() {
+	hour := envInt("PAYMENT_RECONCILE_HOUR", 2)
+
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			time.Sleep(time.Until(next))
+			reconcilePayments(next.AddDate(0, 0, -1))
+		}
+	}()
+}
+
+func reconcilePayments(day time.Time) {
+	recon, err := billing.Reconcile(config.DB, payment.Default, day, nil)
+	if err != nil {
+		log.Printf("[PaymentRecon] Gagal rekonsiliasi %s: %v", day.Format("2006-01-02"), err)
+		return
+	}
+	log.Printf("[PaymentRecon] Rekonsiliasi %s selesai: %d order dicek, %d cocok, %d selisih (%d diperbaiki otomatis)",
+		day.Format("2006-01-02"), recon.OrdersChecked, recon.Matched, recon.Mismatched, recon.AutoFixed)
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/payout"
+	"homecare-backend/pkg/disbursement"
+	"log"
+	"time"
+)
+
+// StartPayoutRetryJob berkala mengirim ulang penarikan PROCESSING yang belum sampai ke provider
+// (tidak punya payout_ref), supaya saldo Mitra tidak tertahan selamanya.
+// Config .env: PAYOUT_RETRY_INTERVAL_MINUTES (default 15)
+func StartPayoutRetryJob() {
+	interval := time.Duration(envInt("PAYOUT_RETRY_INTERVAL_MINUTES", 15)) * time.Minute
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			retried, err := payout.RetryUnsubmitted(config.DB, disbursement.Default)
+			if err != nil {
+				log.Printf("[PayoutRetry] Gagal memuat penarikan tertahan: %v", err)
+			} else if retried > 0 {
+				log.Printf("[PayoutRetry] %d penarikan tertahan dikirim ulang ke %s", retried, disbursement.Default.Name())
+			}
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"homecare-backend/internal/config"
+	"homecare-backend/internal/payout"
+	"log"
+	"time"
+)
+
+// StartPayoutScheduleJob menjalankan pencairan otomatis mingguan:
+// 1. Buat penarikan untuk Mitra yang mengaktifkan auto payout & saldonya >= batas minimal
+// 2. Kumpulkan semua penarikan PENDING jadi satu batch DRAFT untuk di-review Finance
+// Config .env:
+// - PAYOUT_SCHEDULE_WEEKDAY (default 1 = Senin, 0 = Minggu)
+// - PAYOUT_SCHEDULE_HOUR (default 9)
+// - PAYOUT_MIN_AMOUNT (default 50000)
+func StartPayoutScheduleJob() {
+	weekday := time.Weekday(envInt("PAYOUT_SCHEDULE_WEEKDAY", 1) % 7)
+	hour := envInt("PAYOUT_SCHEDULE_HOUR", 9)
+
+	go func() {
+		for {
+			next := nextWeekly(time.Now(), weekday, hour)
+			time.Sleep(time.Until(next))
+			runScheduledPayout(next)
+		}
+	}()
+}
+
+// nextWeekly jadwal berikutnya (hari & jam tertentu) setelah `now`
+func nextWeekly(now time.Time, weekday time.Weekday, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	next = next.AddDate(0, 0, (int(weekday)-int(now.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+func runScheduledPayout(at time.Time) {
+	created, err := payout.CollectAutoPayouts(config.DB, at)
+	if err != nil {
+		log.Printf("[PayoutJob] Gagal membuat auto payout: %v", err)
+		return
+	}
+
+	run, err := payout.CreateRun(config.DB, nil)
+	if err == payout.ErrEmptyRun {
+		log.Printf("[PayoutJob] %d auto payout dibuat, tidak ada penarikan untuk batch", created)
+		return
+	}
+	if err != nil {
+		log.Printf("[PayoutJob] Gagal membuat batch pencairan: %v", err)
+		return
+	}
+
+	log.Printf("[PayoutJob] %d auto payout dibuat. Batch %d siap di-review: %d penarikan, total %s",
+		created, run.ID, run.ItemCount, run.TotalAmount)
+}
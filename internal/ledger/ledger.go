@@ -0,0 +1,216 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Akun sistem
+const (
+	GatewayClearing    = "GATEWAY_CLEARING"    // Uang customer yang masih di payment gateway
+	Bank               = "BANK"                // Rekening bank perusahaan
+	CustomerReceivable = "CUSTOMER_RECEIVABLE" // Piutang customer (tagihan yang belum dibayar)
+	CustomerUnearned   = "CUSTOMER_UNEARNED"   // Uang customer yang layanannya belum selesai
+	PayoutClearing     = "PAYOUT_CLEARING"     // Penarikan Mitra yang sedang diproses
+	EarningsHeld       = "EARNINGS_HELD"       // Pendapatan Mitra yang masih ditahan (masa komplain / sengketa)
+	RefundsPayable     = "REFUNDS_PAYABLE"     // Refund yang harus dikembalikan ke customer
+	PlatformRevenue    = "PLATFORM_REVENUE"    // Pendapatan platform (admin fee + potongan komisi)
+	OpeningBalance     = "OPENING_BALANCE"     // Saldo awal saat ledger mulai dipakai
+	PromotionExpense   = "PROMOTION_EXPENSE"   // Saldo promo yang diberikan ke customer
+
+	walletPrefix = "WALLET:" // Hutang ke pemilik wallet (saldo Mitra / saldo customer), satu akun per wallet
+)
+
+var systemAccounts = map[string]struct{ Name, Type string }{
+	GatewayClearing:    {"Kliring Payment Gateway", models.AccountAsset},
+	Bank:               {"Rekening Bank", models.AccountAsset},
+	CustomerReceivable: {"Piutang Customer", models.AccountAsset},
+	CustomerUnearned:   {"Pendapatan Diterima Dimuka", models.AccountLiability},
+	PayoutClearing:     {"Penarikan Dalam Proses", models.AccountLiability},
+	EarningsHeld:       {"Pendapatan Mitra Ditahan", models.AccountLiability},
+	RefundsPayable:     {"Hutang Refund", models.AccountLiability},
+	PlatformRevenue:    {"Pendapatan Platform", models.AccountRevenue},
+	OpeningBalance:     {"Saldo Awal", models.AccountEquity},
+	PromotionExpense:   {"Beban Promosi", models.AccountExpense},
+}
+
+var (
+	ErrUnbalanced   = errors.New("jurnal tidak seimbang (debit != kredit)")
+	ErrEmptyJournal = errors.New("jurnal tanpa baris")
+	ErrBadAmount    = errors.New("nominal jurnal tidak valid")
+)
+
+// Line adalah satu baris jurnal sebelum disimpan
+type Line struct {
+	Account string
+	Debit   money.Money
+	Credit  money.Money
+}
+
+// Debit membuat baris debit
+func Debit(account string, amount money.Money) Line {
+	return Line{Account: account, Debit: amount}
+}
+
+// Credit membuat baris kredit
+func Credit(account string, amount money.Money) Line {
+	return Line{Account: account, Credit: amount}
+}
+
+// WalletAccount kode akun hutang untuk satu wallet (Mitra / customer)
+func WalletAccount(walletID uint64) string {
+	return fmt.Sprintf("%s%d", walletPrefix, walletID)
+}
+
+// Entry adalah header jurnal yang akan diposting
+type Entry struct {
+	Reference   string // Unik. Posting ulang dengan reference yang sama diabaikan (idempoten).
+	Kind        string
+	Description string
+	OrderID     *uint64
+	Lines       []Line
+}
+
+// Post menyimpan jurnal. Wajib dipanggil di dalam transaksi DB yang sama dengan perubahan saldo wallet,
+// supaya wallet & ledger selalu berubah bersamaan.
+func Post(tx *gorm.DB, e Entry) (*models.LedgerTransaction, error) {
+	// Baris bernilai 0 (misal komisi platform 0%) tidak perlu dicatat
+	lines := make([]Line, 0, len(e.Lines))
+	var debit, credit money.Money
+	for _, l := range e.Lines {
+		if l.Debit < 0 || l.Credit < 0 || (l.Debit > 0 && l.Credit > 0) {
+			return nil, ErrBadAmount
+		}
+		if l.Debit == 0 && l.Credit == 0 {
+			continue
+		}
+		debit += l.Debit
+		credit += l.Credit
+		lines = append(lines, l)
+	}
+	if len(lines) == 0 {
+		return nil, ErrEmptyJournal
+	}
+	if debit != credit {
+		return nil, ErrUnbalanced
+	}
+
+	// Idempoten: webhook/request yang diulang tidak boleh mencatat dua kali
+	var existing models.LedgerTransaction
+	if err := tx.Where("reference = ?", e.Reference).First(&existing).Error; err == nil {
+		return &existing, nil
+	}
+
+	trx := models.LedgerTransaction{
+		Reference:   e.Reference,
+		Kind:        e.Kind,
+		Description: e.Description,
+		OrderID:     e.OrderID,
+	}
+	if err := tx.Create(&trx).Error; err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		account, err := account(tx, l.Account)
+		if err != nil {
+			return nil, err
+		}
+		entry := models.LedgerEntry{
+			TransactionID: trx.ID,
+			AccountID:     account.ID,
+			Debit:         l.Debit,
+			Credit:        l.Credit,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		trx.Entries = append(trx.Entries, entry)
+	}
+
+	return &trx, nil
+}
+
+// account mengambil akun berdasarkan kode, buat otomatis kalau belum ada
+func account(tx *gorm.DB, code string) (models.LedgerAccount, error) {
+	var acc models.LedgerAccount
+	if err := tx.Where("code = ?", code).First(&acc).Error; err == nil {
+		return acc, nil
+	}
+
+	acc = models.LedgerAccount{Code: code}
+	if sys, ok := systemAccounts[code]; ok {
+		acc.Name = sys.Name
+		acc.Type = sys.Type
+	} else if strings.HasPrefix(code, walletPrefix) {
+		var walletID uint64
+		if _, err := fmt.Sscanf(code, walletPrefix+"%d", &walletID); err != nil {
+			return acc, fmt.Errorf("kode akun wallet tidak valid: %s", code)
+		}
+		acc.Name = fmt.Sprintf("Hutang Saldo (Wallet #%d)", walletID)
+		acc.Type = models.AccountLiability
+		acc.WalletID = &walletID
+	} else {
+		return acc, fmt.Errorf("akun ledger tidak dikenal: %s", code)
+	}
+
+	if err := tx.Create(&acc).Error; err != nil {
+		return acc, err
+	}
+	return acc, nil
+}
+
+// Balance saldo akun sesuai sisi normalnya (Aset/Beban: debit - kredit, lainnya: kredit - debit)
+func Balance(db *gorm.DB, code string) (money.Money, error) {
+	var acc models.LedgerAccount
+	if err := db.Where("code = ?", code).First(&acc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil // Belum pernah ada jurnal
+		}
+		return 0, err
+	}
+
+	var sum struct{ Debit, Credit money.Money }
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(debit), 0) AS debit, COALESCE(SUM(credit), 0) AS credit").
+		Where("account_id = ?", acc.ID).
+		Scan(&sum).Error; err != nil {
+		return 0, err
+	}
+
+	return normalBalance(acc.Type, sum.Debit, sum.Credit), nil
+}
+
+// OrderBalance saldo akun khusus jurnal milik satu order (misal sisa hutang refund order tsb)
+func OrderBalance(db *gorm.DB, code string, orderID uint64) (money.Money, error) {
+	var acc models.LedgerAccount
+	if err := db.Where("code = ?", code).First(&acc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var sum struct{ Debit, Credit money.Money }
+	if err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(ledger_entries.debit), 0) AS debit, COALESCE(SUM(ledger_entries.credit), 0) AS credit").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+		Where("ledger_entries.account_id = ? AND ledger_transactions.order_id = ?", acc.ID, orderID).
+		Scan(&sum).Error; err != nil {
+		return 0, err
+	}
+
+	return normalBalance(acc.Type, sum.Debit, sum.Credit), nil
+}
+
+func normalBalance(accountType string, debit, credit money.Money) money.Money {
+	if accountType == models.AccountAsset || accountType == models.AccountExpense {
+		return debit - credit
+	}
+	return credit - debit
+}
@@ -0,0 +1,141 @@
+package ledger
+
+import (
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+
+	"gorm.io/gorm"
+)
+
+// AccountBalance adalah satu baris neraca saldo
+type AccountBalance struct {
+	Code    string      `json:"code"`
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Debit   money.Money `json:"debit"`
+	Credit  money.Money `json:"credit"`
+	Balance money.Money `json:"balance"`
+}
+
+// TrialBalanceReport adalah neraca saldo semua akun. Balanced harus selalu true.
+type TrialBalanceReport struct {
+	Accounts    []AccountBalance `json:"accounts"`
+	TotalDebit  money.Money      `json:"total_debit"`
+	TotalCredit money.Money      `json:"total_credit"`
+	Balanced    bool             `json:"balanced"`
+}
+
+// WalletDrift adalah wallet yang Balance-nya tidak sama dengan saldo di ledger
+type WalletDrift struct {
+	WalletID      uint64      `json:"wallet_id"`
+	UserID        uint64      `json:"user_id"`
+	WalletBalance money.Money `json:"wallet_balance"`
+	LedgerBalance money.Money `json:"ledger_balance"`
+	Difference    money.Money `json:"difference"` // wallet - ledger
+}
+
+// TrialBalance menghitung total debit/kredit per akun
+func TrialBalance(db *gorm.DB) (TrialBalanceReport, error) {
+	var rows []struct {
+		Code, Name, Type string
+		Debit, Credit    money.Money
+	}
+	err := db.Table("ledger_accounts a").
+		Select("a.code, a.name, a.type, COALESCE(SUM(e.debit), 0) AS debit, COALESCE(SUM(e.credit), 0) AS credit").
+		Joins("LEFT JOIN ledger_entries e ON e.account_id = a.id").
+		Group("a.id, a.code, a.name, a.type").
+		Order("a.code").
+		Scan(&rows).Error
+	if err != nil {
+		return TrialBalanceReport{}, err
+	}
+
+	report := TrialBalanceReport{Accounts: []AccountBalance{}}
+	for _, r := range rows {
+		report.Accounts = append(report.Accounts, AccountBalance{
+			Code:    r.Code,
+			Name:    r.Name,
+			Type:    r.Type,
+			Debit:   r.Debit,
+			Credit:  r.Credit,
+			Balance: normalBalance(r.Type, r.Debit, r.Credit),
+		})
+		report.TotalDebit += r.Debit
+		report.TotalCredit += r.Credit
+	}
+	report.Balanced = report.TotalDebit == report.TotalCredit
+
+	return report, nil
+}
+
+// Reconcile membandingkan Wallet.Balance dengan saldo akun WALLET:<id> di ledger.
+// Return daftar wallet yang selisih (kosong = semua cocok).
+func Reconcile(db *gorm.DB) ([]WalletDrift, error) {
+	var rows []struct {
+		WalletID, UserID uint64
+		Balance          money.Money
+		Debit, Credit    money.Money
+	}
+	err := db.Table("wallets w").
+		Select("w.id AS wallet_id, w.user_id, w.balance, COALESCE(SUM(e.debit), 0) AS debit, COALESCE(SUM(e.credit), 0) AS credit").
+		Joins("LEFT JOIN ledger_accounts a ON a.wallet_id = w.id").
+		Joins("LEFT JOIN ledger_entries e ON e.account_id = a.id").
+		Group("w.id, w.user_id, w.balance").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := []WalletDrift{}
+	for _, r := range rows {
+		ledgerBalance := normalBalance(models.AccountLiability, r.Debit, r.Credit)
+		diff := r.Balance - ledgerBalance
+		if diff != 0 {
+			drifts = append(drifts, WalletDrift{
+				WalletID:      r.WalletID,
+				UserID:        r.UserID,
+				WalletBalance: r.Balance,
+				LedgerBalance: ledgerBalance,
+				Difference:    diff,
+			})
+		}
+	}
+	return drifts, nil
+}
+
+// BackfillOpeningBalances mencatat saldo awal untuk wallet yang sudah ada sebelum ledger dipakai.
+// Hanya wallet yang BELUM punya jurnal sama sekali yang diproses, jadi aman dipanggil berulang.
+func BackfillOpeningBalances(db *gorm.DB) (int, error) {
+	var wallets []models.Wallet
+	err := db.
+		Where("balance <> 0").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_accounts a WHERE a.wallet_id = wallets.id)").
+		Find(&wallets).Error
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, w := range wallets {
+		lines := []Line{Debit(OpeningBalance, w.Balance), Credit(WalletAccount(w.ID), w.Balance)}
+		if w.Balance < 0 {
+			lines = []Line{Debit(WalletAccount(w.ID), -w.Balance), Credit(OpeningBalance, -w.Balance)}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := Post(tx, Entry{
+				Reference:   fmt.Sprintf("OPENING:WALLET:%d", w.ID),
+				Kind:        "OPENING_BALANCE",
+				Description: "Saldo awal wallet sebelum ledger",
+				Lines:       lines,
+			})
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"homecare-backend/internal/models"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Default periode hitung metrik (METRICS_WINDOW_DAYS)
+const defaultWindowDays = 90
+
+// Toleransi telat check-in yang masih dianggap tepat waktu (LATE_GRACE_MINUTES)
+const defaultGraceMinutes = 15
+
+// tierRule adalah syarat minimal untuk naik ke tier tertentu
+type tierRule struct {
+	Tier           string
+	MinCompletion  int64
+	MinRating      float64
+	MinOnTime      float64
+	MinAcceptance  float64
+	MaxCancelation float64
+}
+
+// Urutan dari tier tertinggi. Mitra masuk ke tier pertama yang syaratnya terpenuhi.
+var tierRules = []tierRule{
+	{Tier: models.TierGold, MinCompletion: 50, MinRating: 4.7, MinOnTime: 0.9, MinAcceptance: 0.8, MaxCancelation: 0.05},
+	{Tier: models.TierSilver, MinCompletion: 10, MinRating: 4.3, MinOnTime: 0.8, MinAcceptance: 0.6, MaxCancelation: 0.1},
+}
+
+// Prioritas dispatch open booking: tier tinggi melihat job lebih dulu
+var dispatchDelay = map[string]time.Duration{
+	models.TierGold:   0,
+	models.TierSilver: 5 * time.Minute,
+	models.TierBronze: 10 * time.Minute,
+}
+
+// DispatchDelay berapa lama Mitra tier ini harus menunggu setelah order PAID sebelum bisa melihat/mengambil job
+func DispatchDelay(tier string) time.Duration {
+	if d, ok := dispatchDelay[tier]; ok {
+		return d
+	}
+	return dispatchDelay[models.TierBronze]
+}
+
+// TierRank untuk urutan (GOLD=0, SILVER=1, BRONZE=2)
+func TierRank(tier string) int {
+	switch tier {
+	case models.TierGold:
+		return 0
+	case models.TierSilver:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// ComputeTier menentukan tier dari metrik
+func ComputeTier(m models.PartnerMetric) string {
+	for _, rule := range tierRules {
+		if m.CompletionCount >= rule.MinCompletion &&
+			m.AverageRating >= rule.MinRating &&
+			m.OnTimeRate >= rule.MinOnTime &&
+			m.AcceptanceRate >= rule.MinAcceptance &&
+			m.CancellationRate <= rule.MaxCancelation {
+			return rule.Tier
+		}
+	}
+	return models.TierBronze
+}
+
+// Refresh menghitung ulang metrik & tier satu Mitra, lalu menyimpan ke partner_metrics
+// (+ update rating_avg & tier di partner_profiles biar bisa dipakai filter/sort pencarian)
+func Refresh(db *gorm.DB, partnerID uint64) (models.PartnerMetric, error) {
+	window := envInt("METRICS_WINDOW_DAYS", defaultWindowDays)
+	grace := envInt("LATE_GRACE_MINUTES", defaultGraceMinutes)
+	since := time.Now().AddDate(0, 0, -window)
+
+	m := models.PartnerMetric{PartnerID: partnerID, WindowDays: window}
+
+	orders := func() *gorm.DB {
+		return db.Model(&models.Order{}).Where("partner_id = ? AND created_at >= ?", partnerID, since)
+	}
+
+	// 1. Penerimaan & Penolakan
+	orders().Where("accepted_at IS NOT NULL").Count(&m.AcceptedCount)
+	orders().Where("cancel_reason = ?", "PARTNER_REJECTED").Count(&m.RejectionCount)
+	orders().Where("accepted_at IS NOT NULL AND status = ?", "CANCELLED").Count(&m.CancellationCount)
+	orders().Where("status = ?", "COMPLETED").Count(&m.CompletionCount)
+
+	// 2. Ketepatan Waktu (dari data check-in geofence)
+	visits := func() *gorm.DB {
+		return db.Model(&models.OrderVisit{}).Where("partner_id = ? AND check_in_at >= ?", partnerID, since)
+	}
+	visits().Count(&m.VisitCount)
+	visits().Where("late_minutes <= ?", grace).Count(&m.OnTimeCount)
+
+	// 3. Rating (semua waktu, biar Mitra baru tidak naik-turun drastis)
+	var rating struct {
+		Count int64
+		Avg   float64
+	}
+	db.Model(&models.OrderReview{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(rating), 0) AS avg").
+		Where("partner_id = ?", partnerID).
+		Scan(&rating)
+	m.RatingCount = rating.Count
+	m.AverageRating = round2(rating.Avg)
+
+	// 4. Rasio
+	m.AcceptanceRate = ratio(m.AcceptedCount, m.AcceptedCount+m.RejectionCount)
+	m.CancellationRate = ratio(m.CancellationCount, m.AcceptedCount)
+	m.OnTimeRate = ratio(m.OnTimeCount, m.VisitCount)
+
+	m.Tier = ComputeTier(m)
+	m.ComputedAt = time.Now()
+
+	// 5. Simpan (upsert per Mitra)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing models.PartnerMetric
+		if err := tx.Where("partner_id = ?", partnerID).First(&existing).Error; err == nil {
+			m.ID = existing.ID
+		}
+		if err := tx.Save(&m).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PartnerProfile{}).Where("id = ?", partnerID).Updates(map[string]interface{}{
+			"rating_avg": m.AverageRating,
+			"tier":       m.Tier,
+		}).Error
+	})
+
+	return m, err
+}
+
+// RefreshAll menghitung ulang metrik semua Mitra. Return jumlah Mitra yang berhasil diproses.
+func RefreshAll(db *gorm.DB) (int, error) {
+	var ids []uint64
+	if err := db.Model(&models.PartnerProfile{}).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, id := range ids {
+		if _, err := Refresh(db, id); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+// ratio aman dari pembagian nol (belum ada data = 0)
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(part) / float64(total))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func envInt(key string, def int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || val < 0 {
+		return def
+	}
+	return val
+}
+
+// Bagi hasil Mitra per tier dalam basis poin (8500 = 85% dari harga layanan setelah admin fee)
+var tierShare = map[string]int64{
+	models.TierBronze: 8500,
+	models.TierSilver: 8700,
+	models.TierGold:   9000,
+}
+
+// PartnerShareBps bagi hasil default Mitra sesuai tier (default Bronze 85%)
+func PartnerShareBps(tier string) int64 {
+	if share, ok := tierShare[tier]; ok {
+		return share
+	}
+	return tierShare[models.TierBronze]
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BankAccount adalah rekening tujuan pencairan dana Mitra
+type BankAccount struct {
+	ID            uint64 `gorm:"primaryKey" json:"id"`
+	UserID        uint64 `gorm:"not null;uniqueIndex:idx_bank_account_owner,priority:1" json:"user_id"`
+	BankCode      string `gorm:"size:20;not null;uniqueIndex:idx_bank_account_owner,priority:2" json:"bank_code"` // bca, bni, mandiri, dll
+	AccountNumber string `gorm:"size:30;not null;uniqueIndex:idx_bank_account_owner,priority:3" json:"account_number"`
+	HolderName    string `gorm:"size:100;not null" json:"holder_name"` // Nama yang diisi Mitra
+
+	// Hasil inquiry ke provider disbursement
+	InquiryName string `gorm:"size:100" json:"inquiry_name"` // Nama pemilik menurut bank
+	NameMatched bool   `json:"name_matched"`
+
+	Status     string     `gorm:"size:20;default:PENDING" json:"status"` // PENDING, VERIFIED, REJECTED
+	ReviewNote string     `gorm:"type:text" json:"review_note"`
+	VerifiedBy *uint64    `json:"verified_by,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // Soft delete, karena direferensikan riwayat penarikan
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+type CreateBankAccountInput struct {
+	BankCode      string `json:"bank_code" binding:"required"`
+	AccountNumber string `json:"account_number" binding:"required,numeric,min=5,max=30"`
+	HolderName    string `json:"holder_name" binding:"required"`
+}
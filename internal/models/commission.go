@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// CommissionRule adalah aturan bagi hasil Mitra.
+// ServiceID / Tier kosong = berlaku untuk semua. Rule paling spesifik yang menang.
+type CommissionRule struct {
+	ID             uint64     `gorm:"primaryKey" json:"id"`
+	Name           string     `gorm:"size:100" json:"name"`
+	ServiceID      *uint      `gorm:"index" json:"service_id"`                           // NULL = semua layanan
+	Tier           string     `gorm:"size:10;index" json:"tier"`                         // Kosong = semua tier
+	PartnerPercent float64    `gorm:"type:decimal(5,2);not null" json:"partner_percent"` // Persentase untuk Mitra (0-100, maks 2 desimal) dari harga setelah admin fee
+	EffectiveFrom  time.Time  `gorm:"not null;index" json:"effective_from"`
+	EffectiveTo    *time.Time `json:"effective_to"` // NULL = berlaku seterusnya
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy      uint64     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Service *Service `gorm:"foreignKey:ServiceID" json:"service,omitempty"`
+}
+
+type CommissionRuleInput struct {
+	Name           string     `json:"name" binding:"required"`
+	ServiceID      *uint      `json:"service_id"`
+	Tier           string     `json:"tier" binding:"omitempty,oneof=BRONZE SILVER GOLD"`
+	PartnerPercent float64    `json:"partner_percent" binding:"required,gt=0,lte=100"`
+	EffectiveFrom  *time.Time `json:"effective_from"` // Kosong = mulai sekarang
+	EffectiveTo    *time.Time `json:"effective_to"`
+}
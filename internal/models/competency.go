@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Competency adalah master data keahlian (misal: INFUS, PERAWATAN_LUKA, LANSIA)
+type Competency struct {
+	ID          uint64    `gorm:"primaryKey" json:"id"`
+	Code        string    `gorm:"uniqueIndex;size:50;not null" json:"code"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ServiceCompetency adalah tabel pivot: Layanan X butuh Kompetensi Y
+type ServiceCompetency struct {
+	ServiceID    uint   `gorm:"primaryKey" json:"service_id"`
+	CompetencyID uint64 `gorm:"primaryKey" json:"competency_id"`
+}
+
+// PartnerCompetency adalah klaim keahlian + sertifikat dari Mitra.
+// Klaim baru dihitung untuk eligibility setelah diverifikasi Admin.
+type PartnerCompetency struct {
+	ID             uint64     `gorm:"primaryKey" json:"id"`
+	PartnerID      uint64     `gorm:"not null;uniqueIndex:idx_partner_competency" json:"partner_id"` // ID PartnerProfile
+	CompetencyID   uint64     `gorm:"not null;uniqueIndex:idx_partner_competency" json:"competency_id"`
+	CertificateNo  string     `gorm:"size:100" json:"certificate_no"`
+	CertificateURL string     `gorm:"size:255" json:"certificate_url"`
+	Status         string     `gorm:"size:20;default:PENDING" json:"status"` // PENDING, VERIFIED, REJECTED
+	ReviewNote     string     `gorm:"type:text" json:"review_note"`
+	VerifiedBy     *uint64    `json:"verified_by,omitempty"`
+	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Competency *Competency `gorm:"foreignKey:CompetencyID" json:"competency,omitempty"`
+}
+
+// Struct inputan Mitra saat mendaftarkan keahlian
+type DeclareCompetencyInput struct {
+	CompetencyID   uint64 `json:"competency_id" binding:"required"`
+	CertificateNo  string `json:"certificate_no"`
+	CertificateURL string `json:"certificate_url" binding:"omitempty,url"`
+}
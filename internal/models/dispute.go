@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Status & hasil sengketa
+const (
+	DisputeOpen     = "OPEN"
+	DisputeResolved = "RESOLVED"
+
+	DisputeForPartner  = "PARTNER"  // Komplain tidak terbukti, dana dilepas ke Mitra
+	DisputeForCustomer = "CUSTOMER" // Komplain diterima, bagian Mitra dikembalikan ke customer
+)
+
+// OrderDispute adalah komplain customer atas layanan yang sudah selesai.
+// Selama OPEN, pendapatan Mitra dari order tersebut dibekukan (tidak bisa ditarik).
+type OrderDispute struct {
+	ID                  uint64     `gorm:"primaryKey" json:"id"`
+	OrderID             uint64     `gorm:"unique;not null" json:"order_id"`
+	CustomerID          uint64     `gorm:"index" json:"customer_id"`
+	WalletTransactionID uint64     `json:"wallet_transaction_id"` // INCOME Mitra yang dibekukan
+	Reason              string     `gorm:"type:text" json:"reason"`
+	Status              string     `gorm:"size:20;default:OPEN;index" json:"status"`
+	Resolution          string     `gorm:"size:20" json:"resolution,omitempty"`
+	ResolutionNote      string     `gorm:"type:text" json:"resolution_note,omitempty"`
+	ResolvedBy          *uint64    `json:"resolved_by,omitempty"`
+	ResolvedAt          *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	Order *Order `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+}
+
+type CreateDisputeInput struct {
+	Reason string `json:"reason" binding:"required,min=10"`
+}
+
+type ResolveDisputeInput struct {
+	Resolution string `json:"resolution" binding:"required,oneof=PARTNER CUSTOMER"`
+	Note       string `json:"note" binding:"required"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// Invoice tagihan resmi untuk order yang sudah dibayar (untuk klaim asuransi / reimburse kantor).
+// Nomor berurutan tanpa loncat per tahun: INV/2026/10/000123. Satu order = satu invoice.
+type Invoice struct {
+	ID         uint64 `gorm:"primaryKey" json:"id"`
+	InvoiceNo  string `gorm:"size:30;unique;not null" json:"invoice_no"`
+	OrderID    uint64 `gorm:"unique;not null" json:"order_id"`
+	CustomerID uint64 `gorm:"index" json:"customer_id"`
+
+	// Snapshot data saat invoice terbit (tidak ikut berubah kalau profil / master data berubah)
+	BillToName  string    `gorm:"size:100" json:"bill_to_name"`
+	BillToEmail string    `gorm:"size:100" json:"bill_to_email"`
+	BillToPhone string    `gorm:"size:20" json:"bill_to_phone"`
+	PatientName string    `gorm:"size:100" json:"patient_name"`
+	ServiceDate time.Time `json:"service_date"`
+
+	Subtotal money.Money `gorm:"type:bigint" json:"subtotal"` // Jumlah item sebelum diskon
+	Discount money.Money `gorm:"type:bigint" json:"discount"`
+	Total    money.Money `gorm:"type:bigint" json:"total"` // Yang dibayar customer (sudah termasuk PPN)
+
+	// PPN (harga sudah termasuk PPN): DPP + PPN = total item kena pajak
+	TaxRateBps int64       `json:"tax_rate_bps"` // 1100 = 11%, 0 = tanpa PPN
+	TaxBase    money.Money `gorm:"type:bigint" json:"tax_base"`
+	TaxAmount  money.Money `gorm:"type:bigint" json:"tax_amount"`
+
+	PaidWithBalance money.Money `gorm:"type:bigint" json:"paid_with_balance"`
+	PaidViaGateway  money.Money `gorm:"type:bigint" json:"paid_via_gateway"`
+	PaidByPayer     money.Money `gorm:"type:bigint;default:0" json:"paid_by_payer"` // Ditanggung perusahaan/asuransi
+	PayerName       string      `gorm:"size:150" json:"payer_name,omitempty"`
+	PaidAt          *time.Time  `json:"paid_at,omitempty"`
+	IssuedAt        time.Time   `json:"issued_at"`
+	CreatedAt       time.Time   `json:"created_at"`
+
+	Items []InvoiceItem `gorm:"foreignKey:InvoiceID" json:"items,omitempty"`
+	Order *Order        `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+}
+
+// InvoiceItem satu baris rincian invoice (nominal minus untuk diskon)
+type InvoiceItem struct {
+	ID          uint64      `gorm:"primaryKey" json:"id"`
+	InvoiceID   uint64      `gorm:"index;not null" json:"invoice_id"`
+	Description string      `gorm:"size:150" json:"description"`
+	Qty         int         `json:"qty"`
+	UnitPrice   money.Money `gorm:"type:bigint" json:"unit_price"`
+	Amount      money.Money `gorm:"type:bigint" json:"amount"`
+	Taxable     bool        `json:"taxable"` // Kena PPN
+}
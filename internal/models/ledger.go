@@ -0,0 +1,50 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// Jenis akun ledger
+const (
+	AccountAsset     = "ASSET"
+	AccountLiability = "LIABILITY"
+	AccountEquity    = "EQUITY"
+	AccountRevenue   = "REVENUE"
+	AccountExpense   = "EXPENSE"
+)
+
+// LedgerAccount adalah akun buku besar (kas gateway, pendapatan platform, hutang ke Mitra, dll)
+type LedgerAccount struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"size:50;uniqueIndex;not null" json:"code"` // Misal: PLATFORM_REVENUE, WALLET:12
+	Name      string    `gorm:"size:100" json:"name"`
+	Type      string    `gorm:"size:20;not null" json:"type"` // ASSET, LIABILITY, EQUITY, REVENUE, EXPENSE
+	WalletID  *uint64   `gorm:"uniqueIndex" json:"wallet_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerTransaction adalah satu kejadian keuangan (jurnal). Append-only: tidak pernah di-update/hapus,
+// koreksi dilakukan dengan jurnal balik.
+type LedgerTransaction struct {
+	ID          uint64    `gorm:"primaryKey" json:"id"`
+	Reference   string    `gorm:"size:100;uniqueIndex;not null" json:"reference"` // Kunci idempotensi, misal: ORDER_PAID:12
+	Kind        string    `gorm:"size:30;index" json:"kind"`                      // ORDER_PAID, ORDER_COMPLETED, WITHDRAWAL_REQUEST, dll
+	Description string    `gorm:"size:255" json:"description"`
+	OrderID     *uint64   `gorm:"index" json:"order_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID" json:"entries,omitempty"`
+}
+
+// LedgerEntry adalah satu baris debit/kredit. Total debit = total kredit per transaksi.
+type LedgerEntry struct {
+	ID            uint64      `gorm:"primaryKey" json:"id"`
+	TransactionID uint64      `gorm:"index;not null" json:"transaction_id"`
+	AccountID     uint64      `gorm:"index;not null" json:"account_id"`
+	Debit         money.Money `gorm:"type:bigint;not null;default:0" json:"debit"`
+	Credit        money.Money `gorm:"type:bigint;not null;default:0" json:"credit"`
+	CreatedAt     time.Time   `json:"created_at"`
+
+	Account *LedgerAccount `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
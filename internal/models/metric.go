@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Tier Mitra (dihitung dari PartnerMetric)
+const (
+	TierBronze = "BRONZE"
+	TierSilver = "SILVER"
+	TierGold   = "GOLD"
+)
+
+// PartnerMetric adalah ringkasan performa Mitra dalam periode terakhir (di-refresh background job)
+type PartnerMetric struct {
+	ID         uint64 `gorm:"primaryKey" json:"id"`
+	PartnerID  uint64 `gorm:"uniqueIndex;not null" json:"partner_id"` // ID PartnerProfile
+	WindowDays int    `json:"window_days"`                            // Periode hitung (misal 90 hari terakhir)
+
+	AcceptedCount     int64 `json:"accepted_count"`
+	RejectionCount    int64 `json:"rejection_count"`
+	CancellationCount int64 `json:"cancellation_count"` // Batal setelah diterima Mitra
+	CompletionCount   int64 `json:"completion_count"`
+	VisitCount        int64 `json:"visit_count"`
+	OnTimeCount       int64 `json:"on_time_count"`
+	RatingCount       int64 `json:"rating_count"`
+
+	// Rasio 0..1
+	AcceptanceRate   float64 `json:"acceptance_rate"`
+	CancellationRate float64 `json:"cancellation_rate"`
+	OnTimeRate       float64 `json:"on_time_rate"`
+	AverageRating    float64 `json:"average_rating"`
+
+	Tier       string    `gorm:"size:10" json:"tier"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// OrderReview adalah rating & ulasan customer setelah order selesai
+type OrderReview struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	OrderID    uint64    `gorm:"uniqueIndex;not null" json:"order_id"`
+	CustomerID uint64    `gorm:"not null" json:"customer_id"`
+	PartnerID  uint64    `gorm:"not null;index" json:"partner_id"` // ID PartnerProfile
+	Rating     int       `gorm:"not null" json:"rating"`           // 1-5
+	Comment    string    `gorm:"type:text" json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CreateReviewInput struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Comment string `json:"comment"`
+}
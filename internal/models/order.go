@@ -1,35 +1,64 @@
 package models
 
-import "time"
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
 
 type Order struct {
-	ID            uint64    `gorm:"primaryKey" json:"id"`
-	OrderNo       string    `gorm:"unique;size:50" json:"order_no"`
-	CustomerID    uint64    `json:"customer_id"`
-	PartnerID     *uint64   `json:"partner_id"` // Pointer karena bisa NULL
-	PatientID     uint64    `json:"patient_id"`
-	ServiceID     uint      `json:"service_id"`
-	TotalAmount   float64   `json:"total_amount"`
-	Status        string    `json:"status"` // PENDING_PAYMENT, PAID, etc
-	PaymentURL    string    `json:"payment_url"`
-	ScheduleStart time.Time `json:"schedule_start"`
-	ScheduleEnd   time.Time `json:"schedule_end"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID          uint64      `gorm:"primaryKey" json:"id"`
+	OrderNo     string      `gorm:"unique;size:50" json:"order_no"`
+	CustomerID  uint64      `gorm:"uniqueIndex:idx_order_idem_key,priority:1" json:"customer_id"`
+	PartnerID   *uint64     `json:"partner_id"` // Pointer karena bisa NULL
+	PatientID   uint64      `json:"patient_id"`
+	ServiceID   uint        `json:"service_id"`
+	TotalAmount money.Money `gorm:"type:bigint" json:"total_amount"`
+	ServiceFee  money.Money `gorm:"type:bigint;default:0" json:"service_fee"` // Snapshot harga layanan saat order dibuat (rincian invoice)
+	AdminFee    money.Money `gorm:"type:bigint;default:0" json:"admin_fee"`
+	BalanceUsed money.Money `gorm:"type:bigint;default:0" json:"balance_used"` // Dibayar dari saldo wallet customer, sisanya lewat gateway
+	PayerAmount money.Money `gorm:"type:bigint;default:0" json:"payer_amount"` // Ditanggung perusahaan/asuransi, ditagih bulanan
+
+	// Order anggota organisasi penjamin: bagian PayerAmount ditagihkan ke organisasi lewat tagihan bulanan
+	PayerOrganizationID *uint64    `gorm:"index" json:"payer_organization_id,omitempty"`
+	OrgInvoiceID        *uint64    `gorm:"index" json:"org_invoice_id,omitempty"`  // NULL = belum masuk tagihan bulanan
+	Status              string     `json:"status"`                                 // PENDING_PAYMENT, PAID, ASSIGNED, EN_ROUTE, ON_DUTY, COMPLETED, CANCELLED
+	PaymentURL          string     `json:"payment_url"`                            // Link sesi pembayaran terakhir
+	CancelReason        string     `gorm:"size:50" json:"cancel_reason,omitempty"` // PAYMENT_FAILED, PARTNER_REJECTED, dll
+	PaidAt              *time.Time `json:"paid_at,omitempty"`
+	AcceptedAt          *time.Time `json:"accepted_at,omitempty"` // Kapan Mitra menerima order
+	ScheduleStart       time.Time  `json:"schedule_start"`
+	ScheduleEnd         time.Time  `json:"schedule_end"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	// Header Idempotency-Key dari client, unik per customer (cegah order dobel saat double-tap / retry)
+	IdempotencyKey *string `gorm:"size:64;uniqueIndex:idx_order_idem_key,priority:2" json:"-"`
 
 	// Relasi (Preload) biar pas query datanya lengkap
-	Service        *Service        `gorm:"foreignKey:ServiceID" json:"service,omitempty"`
-	Patient        *Patient        `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
-	Partner        *User           `gorm:"foreignKey:PartnerID" json:"partner,omitempty"` // Ambil nama mitra dr tabel user
-	PartnerProfile *PartnerProfile `gorm:"foreignKey:PartnerID" json:"partner_info,omitempty"`
-	CareJournal    *CareJournal    `gorm:"foreignKey:OrderID" json:"medical_report,omitempty"`
-	Customer       User            `gorm:"foreignKey:CustomerID" json:"customer_info,omitempty"`
+	Service           *Service           `gorm:"foreignKey:ServiceID" json:"service,omitempty"`
+	Patient           *Patient           `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	Partner           *User              `gorm:"foreignKey:PartnerID" json:"partner,omitempty"` // Ambil nama mitra dr tabel user
+	PartnerProfile    *PartnerProfile    `gorm:"foreignKey:PartnerID" json:"partner_info,omitempty"`
+	CareJournal       *CareJournal       `gorm:"foreignKey:OrderID" json:"medical_report,omitempty"`
+	Visit             *OrderVisit        `gorm:"foreignKey:OrderID" json:"visit,omitempty"`
+	Review            *OrderReview       `gorm:"foreignKey:OrderID" json:"review,omitempty"`
+	Dispute           *OrderDispute      `gorm:"foreignKey:OrderID" json:"dispute,omitempty"`
+	PaymentAttempts   []PaymentAttempt   `gorm:"foreignKey:OrderID" json:"payment_attempts,omitempty"`
+	PayerOrganization *PayerOrganization `gorm:"foreignKey:PayerOrganizationID" json:"payer_organization,omitempty"`
+	Customer          User               `gorm:"foreignKey:CustomerID" json:"customer_info,omitempty"`
+}
+
+// ChargeAmount nominal yang ditagihkan lewat payment gateway (total dikurangi potongan saldo & tanggungan organisasi)
+func (o Order) ChargeAmount() money.Money {
+	return o.TotalAmount - o.BalanceUsed - o.PayerAmount
 }
 
 type CreateOrderInput struct {
-	PatientID     uint64    `json:"patient_id" binding:"required"`
-	ServiceID     uint      `json:"service_id" binding:"required"`
-	PartnerID     uint64    `json:"partner_id"`
-	ScheduleStart time.Time `json:"schedule_start" binding:"required"` // Format: 2025-11-20T08:00:00Z
-	DurationHours int       `json:"duration_hours" binding:"required"` // Berapa jam/shift
+	PatientID           uint64    `json:"patient_id" binding:"required"`
+	ServiceID           uint      `json:"service_id" binding:"required"`
+	PartnerID           uint64    `json:"partner_id"`
+	ScheduleStart       time.Time `json:"schedule_start" binding:"required"` // Format: 2025-11-20T08:00:00Z
+	DurationHours       int       `json:"duration_hours" binding:"required"` // Berapa jam/shift
+	UseBalance          bool      `json:"use_balance"`                       // Potong saldo wallet dulu, sisanya bayar lewat gateway
+	PayerOrganizationID uint64    `json:"payer_organization_id"`             // Tagihkan ke perusahaan/asuransi (customer harus anggotanya)
 }
@@ -1,18 +1,35 @@
 package models
 
+import "time"
+
 type PartnerProfile struct {
-	ID              uint64  `gorm:"primaryKey" json:"id"`
-	UserID          uint64  `gorm:"not null" json:"user_id"`
-	STRNumber       string  `gorm:"size:100" json:"str_number"`
-	ExperienceYears int     `json:"experience_years"`
-	VideoIntroURL   string  `gorm:"size:255" json:"video_intro_url"` // Link YouTube/Drive
-	BioDescription  string  `gorm:"type:text" json:"bio_description"`
-	RatingAvg       float64 `gorm:"default:0" json:"rating_avg"`
+	ID              uint64     `gorm:"primaryKey" json:"id"`
+	UserID          uint64     `gorm:"not null" json:"user_id"`
+	STRNumber       string     `gorm:"size:100" json:"str_number"`
+	STRExpiresAt    *time.Time `json:"str_expires_at,omitempty"` // Diisi dari dokumen STR yang sudah di-approve
+	ExperienceYears int        `json:"experience_years"`
+	VideoIntroURL   string     `gorm:"size:255" json:"video_intro_url"` // Link YouTube/Drive
+	BioDescription  string     `gorm:"type:text" json:"bio_description"`
+	RatingAvg       float64    `gorm:"default:0" json:"rating_avg"`
+	Gender          string     `gorm:"size:1" json:"gender"`                       // L / P (sama seperti Patient)
+	Languages       string     `gorm:"size:100" json:"languages"`                  // Kode bahasa dipisah koma, misal: "id,en,jv"
+	PriceTier       string     `gorm:"size:20;default:STANDARD" json:"price_tier"` // BASIC, STANDARD, PREMIUM
+	Tier            string     `gorm:"size:10;default:BRONZE" json:"tier"`         // BRONZE, SILVER, GOLD (dari PartnerMetric)
 	// Ensure enough integer digits for longitudes (up to ±180)
-	CurrentLat float64 `gorm:"type:decimal(11,8)" json:"current_lat"`
-	CurrentLng float64 `gorm:"type:decimal(11,8)" json:"current_lng"`
-	IsActive   bool    `gorm:"default:false" json:"is_active"`
-	User       User    `gorm:"foreignKey:UserID" json:"user_data,omitempty"`
+	// idx_partner_geo dipakai prefilter bounding box di SearchPartners
+	CurrentLat float64 `gorm:"type:decimal(11,8);index:idx_partner_geo,priority:2" json:"current_lat"`
+	CurrentLng float64 `gorm:"type:decimal(11,8);index:idx_partner_geo,priority:3" json:"current_lng"`
+	// Kapan terakhir lokasi diupdate (ping dari aplikasi Mitra)
+	LocationUpdatedAt *time.Time `json:"location_updated_at,omitempty"`
+	IsActive          bool       `gorm:"default:false;index:idx_partner_geo,priority:1" json:"is_active"`
+
+	// Status verifikasi (DRAFT, SUBMITTED, IN_REVIEW, APPROVED, REJECTED, SUSPENDED)
+	VerificationStatus string `gorm:"size:20;default:DRAFT" json:"verification_status"`
+
+	// Hasil hitung query SearchPartners (KM), bukan kolom tabel
+	Distance *float64 `gorm:"->;-:migration" json:"distance,omitempty"`
+
+	User User `gorm:"foreignKey:UserID" json:"user_data,omitempty"`
 }
 
 // Struct inputan dari Mitra saat update profil
@@ -23,4 +40,9 @@ type UpdateProfileInput struct {
 	BioDescription  string  `json:"bio_description"`
 	CurrentLat      float64 `json:"current_lat"`
 	CurrentLng      float64 `json:"current_lng"`
+
+	// Opsional (dipakai filter pencarian customer)
+	Gender    string   `json:"gender" binding:"omitempty,oneof=L P"`
+	Languages []string `json:"languages" binding:"omitempty,dive,alpha,min=2,max=3"` // ["id", "en", "jv"]
+	PriceTier string   `json:"price_tier" binding:"omitempty,oneof=BASIC STANDARD PREMIUM"`
 }
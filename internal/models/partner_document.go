@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PartnerDocument adalah berkas legalitas Mitra (STR, SIP, KTP, Sertifikat)
+// File fisiknya ada di storage, di sini cuma simpan path & metadata-nya.
+type PartnerDocument struct {
+	ID          uint64     `gorm:"primaryKey" json:"id"`
+	PartnerID   uint64     `gorm:"not null;index" json:"partner_id"` // ID PartnerProfile
+	Type        string     `gorm:"size:20;not null" json:"type"`     // STR, SIP, KTP, CERTIFICATE
+	DocumentNo  string     `gorm:"size:100" json:"document_no"`
+	FilePath    string     `gorm:"size:255;not null" json:"-"` // Path internal storage, tidak dikirim ke frontend
+	FileName    string     `gorm:"size:255" json:"file_name"`
+	ContentType string     `gorm:"size:100" json:"content_type"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                  // Wajib untuk STR & SIP
+	Status      string     `gorm:"size:20;default:PENDING" json:"status"` // PENDING, APPROVED, REJECTED, EXPIRED
+	ReviewNote  string     `gorm:"type:text" json:"review_note"`
+	ReviewedBy  *uint64    `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+
+	// Penanda pengingat masa berlaku sudah dikirim (biar tidak spam tiap hari)
+	ReminderSentAt *time.Time `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RequiredPartnerDocuments adalah dokumen minimal sebelum Mitra bisa di-approve
+var RequiredPartnerDocuments = []string{"STR", "SIP", "KTP"}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Status verifikasi Mitra
+const (
+	PartnerDraft     = "DRAFT"     // Baru isi profil, belum minta diverifikasi
+	PartnerSubmitted = "SUBMITTED" // Sudah ajukan verifikasi, antre review
+	PartnerInReview  = "IN_REVIEW" // Sedang dicek Admin
+	PartnerApproved  = "APPROVED"  // Lolos, boleh terima order
+	PartnerRejected  = "REJECTED"  // Ditolak, boleh perbaiki & ajukan ulang
+	PartnerSuspended = "SUSPENDED" // Dibekukan Admin setelah sempat APPROVED
+)
+
+// partnerTransitions: status asal -> status tujuan yang diperbolehkan
+var partnerTransitions = map[string][]string{
+	PartnerDraft:     {PartnerSubmitted},
+	PartnerSubmitted: {PartnerInReview, PartnerRejected},
+	PartnerInReview:  {PartnerApproved, PartnerRejected},
+	PartnerRejected:  {PartnerSubmitted},
+	PartnerApproved:  {PartnerSuspended},
+	PartnerSuspended: {PartnerApproved},
+}
+
+// CanTransitionPartner mengecek apakah perpindahan status verifikasi valid
+func CanTransitionPartner(from, to string) bool {
+	for _, next := range partnerTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerVerificationLog adalah riwayat setiap perubahan status verifikasi Mitra
+type PartnerVerificationLog struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	PartnerID  uint64    `gorm:"not null;index" json:"partner_id"` // ID PartnerProfile
+	FromStatus string    `gorm:"size:20" json:"from_status"`
+	ToStatus   string    `gorm:"size:20" json:"to_status"`
+	ActorID    *uint64   `json:"actor_id,omitempty"`      // Admin yang review, atau Mitra sendiri saat submit
+	Reason     string    `gorm:"type:text" json:"reason"` // Alasan tolak/suspend (dikirim ke Mitra)
+	Notes      string    `gorm:"type:text" json:"notes"`  // Catatan internal reviewer
+	CreatedAt  time.Time `json:"created_at"`
+
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
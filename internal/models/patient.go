@@ -13,18 +13,19 @@ type Patient struct {
 	AddressDetail  string `gorm:"type:text" json:"address_detail"`
 	// Use enough integer digits so longitudes like 106.8 fit.
 	// decimal(M,D) where M = total digits, D = decimals. M-D must be >= 3 for longitudes up to 180.
-	Lat       float64   `gorm:"type:decimal(11,8)" json:"lat"`
-	Lng       float64   `gorm:"type:decimal(11,8)" json:"lng"`
+	// NULL = koordinat belum diisi (0,0 tetap dianggap lokasi yang sah)
+	Lat       *float64  `gorm:"type:decimal(11,8)" json:"lat"`
+	Lng       *float64  `gorm:"type:decimal(11,8)" json:"lng"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type CreatePatientInput struct {
-	Name           string  `json:"name" binding:"required"`
-	DOB            string  `json:"dob" binding:"required"`
-	Gender         string  `json:"gender" binding:"required,oneof=L P"`
-	Weight         int     `json:"weight" binding:"required"`
-	MedicalHistory string  `json:"medical_history"`
-	AddressDetail  string  `json:"address_detail" binding:"required"`
-	Lat            float64 `json:"lat"`
-	Lng            float64 `json:"lng"`
+	Name           string   `json:"name" binding:"required"`
+	DOB            string   `json:"dob" binding:"required"`
+	Gender         string   `json:"gender" binding:"required,oneof=L P"`
+	Weight         int      `json:"weight" binding:"required"`
+	MedicalHistory string   `json:"medical_history"`
+	AddressDetail  string   `json:"address_detail" binding:"required"`
+	Lat            *float64 `json:"lat" binding:"required_with=Lng,omitempty,min=-90,max=90"`
+	Lng            *float64 `json:"lng" binding:"required_with=Lat,omitempty,min=-180,max=180"`
 }
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// Jenis organisasi penjamin
+const (
+	PayerCompany = "COMPANY" // Perusahaan (karyawan)
+	PayerInsurer = "INSURER" // Asuransi (peserta polis)
+)
+
+// Status tagihan bulanan organisasi
+const (
+	OrgInvoiceIssued  = "ISSUED"
+	OrgInvoicePartial = "PARTIAL"
+	OrgInvoicePaid    = "PAID"
+)
+
+// PayerOrganization perusahaan / asuransi yang menanggung biaya order anggotanya.
+// Order anggota ditagihkan ke organisasi (bukan lewat Snap) & ditagih sekali sebulan.
+type PayerOrganization struct {
+	ID              uint64      `gorm:"primaryKey" json:"id"`
+	Name            string      `gorm:"size:150;not null" json:"name"`
+	Type            string      `gorm:"size:20;not null" json:"type"` // COMPANY, INSURER
+	Email           string      `gorm:"size:100" json:"email"`        // Tujuan tagihan
+	Phone           string      `gorm:"size:20" json:"phone"`
+	Address         string      `gorm:"size:255" json:"address"`
+	NPWP            string      `gorm:"size:30" json:"npwp"`
+	CreditLimit     money.Money `gorm:"type:bigint;not null" json:"credit_limit"` // Maksimal tagihan belum dibayar
+	PaymentTermDays int         `gorm:"default:30" json:"payment_term_days"`      // Jatuh tempo sejak invoice terbit
+	IsActive        bool        `gorm:"default:true" json:"is_active"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+
+	Members   []PayerMember   `gorm:"foreignKey:OrganizationID" json:"members,omitempty"`
+	Coverages []PayerCoverage `gorm:"foreignKey:OrganizationID" json:"coverages,omitempty"`
+}
+
+// PayerMember customer yang ditanggung organisasi (karyawan / peserta polis)
+type PayerMember struct {
+	ID             uint64     `gorm:"primaryKey" json:"id"`
+	OrganizationID uint64     `gorm:"uniqueIndex:idx_payer_member,priority:1;not null" json:"organization_id"`
+	UserID         uint64     `gorm:"uniqueIndex:idx_payer_member,priority:2;not null" json:"user_id"`
+	MemberNo       string     `gorm:"size:50" json:"member_no"` // NIK karyawan / nomor polis
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	User         *User              `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Organization *PayerOrganization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+// PayerCoverage aturan tanggungan: berapa persen biaya layanan ditanggung & batas per order.
+// ServiceID NULL = berlaku untuk semua layanan (aturan khusus layanan lebih diutamakan).
+type PayerCoverage struct {
+	ID             uint64      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint64      `gorm:"index;not null" json:"organization_id"`
+	ServiceID      *uint       `json:"service_id,omitempty"`
+	CoverageBps    int64       `gorm:"not null" json:"coverage_bps"`               // 10000 = ditanggung penuh
+	MaxPerOrder    money.Money `gorm:"type:bigint;default:0" json:"max_per_order"` // 0 = tanpa batas
+
+	Service *Service `gorm:"foreignKey:ServiceID" json:"service,omitempty"`
+}
+
+// OrgInvoice tagihan bulanan gabungan semua order anggota dalam satu periode
+type OrgInvoice struct {
+	ID             uint64      `gorm:"primaryKey" json:"id"`
+	InvoiceNo      string      `gorm:"size:30;unique;not null" json:"invoice_no"`
+	OrganizationID uint64      `gorm:"index;not null" json:"organization_id"`
+	PeriodStart    time.Time   `gorm:"type:date" json:"period_start"`
+	PeriodEnd      time.Time   `gorm:"type:date" json:"period_end"` // Eksklusif (tanggal 1 bulan berikutnya)
+	OrderCount     int         `json:"order_count"`
+	Total          money.Money `gorm:"type:bigint" json:"total"`
+	PaidAmount     money.Money `gorm:"type:bigint;default:0" json:"paid_amount"`
+	Status         string      `gorm:"size:20;index" json:"status"` // ISSUED, PARTIAL, PAID
+	IssuedAt       time.Time   `json:"issued_at"`
+	DueAt          time.Time   `gorm:"index" json:"due_at"`
+	PaidAt         *time.Time  `json:"paid_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+
+	Organization *PayerOrganization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Orders       []Order            `gorm:"foreignKey:OrgInvoiceID" json:"orders,omitempty"`
+	Payments     []OrgPayment       `gorm:"foreignKey:OrgInvoiceID" json:"payments,omitempty"`
+}
+
+// OrgPayment pembayaran (transfer) dari organisasi untuk satu tagihan bulanan
+type OrgPayment struct {
+	ID           uint64      `gorm:"primaryKey" json:"id"`
+	OrgInvoiceID uint64      `gorm:"index;not null" json:"org_invoice_id"`
+	Amount       money.Money `gorm:"type:bigint;not null" json:"amount"`
+	PaidAt       time.Time   `json:"paid_at"`
+	Reference    string      `gorm:"size:100" json:"reference"` // No. bukti transfer
+	Note         string      `gorm:"size:255" json:"note,omitempty"`
+	RecordedBy   uint64      `json:"recorded_by"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// PayerOrganizationInput data organisasi dari Finance
+type PayerOrganizationInput struct {
+	Name            string      `json:"name" binding:"required"`
+	Type            string      `json:"type" binding:"required,oneof=COMPANY INSURER"`
+	Email           string      `json:"email" binding:"omitempty,email"`
+	Phone           string      `json:"phone"`
+	Address         string      `json:"address"`
+	NPWP            string      `json:"npwp"`
+	CreditLimit     money.Money `json:"credit_limit" binding:"required,min=1"`
+	PaymentTermDays int         `json:"payment_term_days" binding:"omitempty,min=1,max=120"`
+	IsActive        *bool       `json:"is_active"`
+}
+
+// PayerMemberInput tambah anggota (cari customer dari email)
+type PayerMemberInput struct {
+	Email      string     `json:"email" binding:"required,email"`
+	MemberNo   string     `json:"member_no"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// PayerCoverageInput aturan tanggungan baru
+type PayerCoverageInput struct {
+	ServiceID       *uint       `json:"service_id"`
+	CoveragePercent float64     `json:"coverage_percent" binding:"required,gt=0,lte=100"`
+	MaxPerOrder     money.Money `json:"max_per_order" binding:"min=0"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// Status sesi pembayaran
+const (
+	AttemptPending    = "PENDING"
+	AttemptPaid       = "PAID"
+	AttemptFailed     = "FAILED"     // Ditolak / dibatalkan / kedaluwarsa di gateway
+	AttemptSuperseded = "SUPERSEDED" // Diganti sesi baru (link "Bayar Ulang")
+)
+
+// PaymentAttempt satu sesi pembayaran (tagihan Snap) untuk order.
+// Order bisa punya beberapa sesi kalau link lama kedaluwarsa; semuanya tetap dipetakan ke order yang sama.
+type PaymentAttempt struct {
+	ID             uint64      `gorm:"primaryKey" json:"id"`
+	OrderID        uint64      `gorm:"index;not null" json:"order_id"`
+	AttemptNo      int         `json:"attempt_no"`
+	GatewayOrderNo string      `gorm:"size:50;unique;not null" json:"gateway_order_no"` // order_id yang dikirim ke gateway
+	Provider       string      `gorm:"size:20" json:"provider"`
+	Amount         money.Money `gorm:"type:bigint" json:"amount"`
+	Token          string      `gorm:"size:100" json:"-"`
+	PaymentURL     string      `gorm:"size:255" json:"payment_url"`
+	Status         string      `gorm:"size:20;default:PENDING" json:"status"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Hasil pemrosesan notifikasi pembayaran
+const (
+	PaymentEventApplied   = "APPLIED"   // Status order berubah (efek samping dijalankan)
+	PaymentEventIgnored   = "IGNORED"   // Valid, tapi status order tidak berubah
+	PaymentEventDuplicate = "DUPLICATE" // Notifikasi yang sama sudah pernah diterima (retry gateway)
+	PaymentEventRejected  = "REJECTED"  // Signature / nominal / order tidak valid
+	PaymentEventError     = "ERROR"     // Gagal diproses (DB / cek status), akan diproses ulang saat gateway retry
+)
+
+// PaymentEvent menyimpan SETIAP notifikasi pembayaran yang masuk apa adanya (audit & replay)
+type PaymentEvent struct {
+	ID                uint64  `gorm:"primaryKey" json:"id"`
+	Provider          string  `gorm:"size:20;default:midtrans" json:"provider"`
+	OrderNo           string  `gorm:"size:50;index" json:"order_no"`
+	OrderID           *uint64 `gorm:"index" json:"order_id,omitempty"`
+	TransactionID     string  `gorm:"size:100" json:"transaction_id"`
+	TransactionStatus string  `gorm:"size:30" json:"transaction_status"`
+	FraudStatus       string  `gorm:"size:30" json:"fraud_status,omitempty"`
+	GrossAmount       string  `gorm:"size:30" json:"gross_amount"`
+
+	// Kunci dedup transaction_id:status:fraud. NULL untuk event yang ditolak / duplikat.
+	DedupKey *string `gorm:"size:200;unique" json:"dedup_key,omitempty"`
+
+	RawPayload     string `gorm:"type:text" json:"raw_payload"`
+	IPAddress      string `gorm:"size:45" json:"ip_address"`
+	SignatureValid bool   `json:"signature_valid"`
+
+	Result            string     `gorm:"size:20;index" json:"result"`
+	Error             string     `gorm:"size:255" json:"error,omitempty"`
+	OrderStatusBefore string     `gorm:"size:30" json:"order_status_before,omitempty"`
+	OrderStatusAfter  string     `gorm:"size:30" json:"order_status_after,omitempty"`
+	DuplicateOfID     *uint64    `json:"duplicate_of_id,omitempty"`
+	ReplayCount       int        `gorm:"default:0" json:"replay_count"`
+	ProcessedAt       *time.Time `json:"processed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
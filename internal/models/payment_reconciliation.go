@@ -0,0 +1,55 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// Jenis selisih rekonsiliasi pembayaran
+const (
+	ReconPaidNotRecorded  = "PAID_NOT_RECORDED"   // Lunas di gateway, order masih PENDING_PAYMENT (auto-fix)
+	ReconStalePending     = "STALE_PENDING"       // Gagal/expired di gateway, order masih PENDING_PAYMENT (auto-fix)
+	ReconAmountMismatch   = "AMOUNT_MISMATCH"     // Nominal gateway beda dengan total order
+	ReconPaidAfterCancel  = "PAID_AFTER_CANCEL"   // Lunas di gateway, order sudah CANCELLED sebelum dibayar (perlu refund)
+	ReconNotPaidAtGateway = "NOT_PAID_AT_GATEWAY" // Order tercatat lunas, tapi di gateway tidak lunas / tidak ada
+	ReconUnknownOrder     = "UNKNOWN_ORDER"       // Notifikasi gateway untuk order_no yang tidak ada di sistem
+	ReconQueryFailed      = "QUERY_FAILED"        // Gagal cek status ke gateway
+)
+
+// PaymentReconciliation laporan rekonsiliasi pembayaran harian (order kita vs gateway)
+type PaymentReconciliation struct {
+	ID            uint64     `gorm:"primaryKey" json:"id"`
+	Date          time.Time  `gorm:"type:date;index" json:"date"` // Tanggal order yang dicek
+	Provider      string     `gorm:"size:20" json:"provider"`
+	Status        string     `gorm:"size:20" json:"status"` // RUNNING, COMPLETED, FAILED
+	OrdersChecked int        `json:"orders_checked"`
+	Matched       int        `json:"matched"`
+	Mismatched    int        `json:"mismatched"`
+	AutoFixed     int        `json:"auto_fixed"`
+	TriggeredBy   *uint64    `json:"triggered_by,omitempty"` // NULL = job harian
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+
+	Items []PaymentReconciliationItem `gorm:"foreignKey:ReconciliationID" json:"items,omitempty"`
+}
+
+// PaymentReconciliationItem satu selisih yang ditemukan
+type PaymentReconciliationItem struct {
+	ID               uint64      `gorm:"primaryKey" json:"id"`
+	ReconciliationID uint64      `gorm:"index" json:"reconciliation_id"`
+	OrderID          *uint64     `gorm:"index" json:"order_id,omitempty"`
+	OrderNo          string      `gorm:"size:50" json:"order_no"`
+	Type             string      `gorm:"size:30" json:"type"`
+	LocalStatus      string      `gorm:"size:30" json:"local_status"`
+	GatewayStatus    string      `gorm:"size:30" json:"gateway_status"`
+	LocalAmount      money.Money `gorm:"type:bigint" json:"local_amount"`
+	GatewayAmount    money.Money `gorm:"type:bigint" json:"gateway_amount"`
+	Detail           string      `gorm:"size:255" json:"detail,omitempty"`
+
+	// OPEN = perlu ditindaklanjuti Finance, FIXED = diperbaiki otomatis, RESOLVED = ditutup Finance
+	Status     string     `gorm:"size:20;default:OPEN;index" json:"status"`
+	Note       string     `gorm:"type:text" json:"note,omitempty"`
+	ResolvedBy *uint64    `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
+
+// PayoutSetting pengaturan pencairan otomatis mingguan milik Mitra
+type PayoutSetting struct {
+	ID            uint64      `gorm:"primaryKey" json:"id"`
+	UserID        uint64      `gorm:"unique;not null" json:"user_id"`
+	AutoPayout    bool        `gorm:"default:false" json:"auto_payout"`
+	BankAccountID *uint64     `json:"bank_account_id"`                         // Rekening tujuan (harus VERIFIED)
+	MinAmount     money.Money `gorm:"type:bigint;default:0" json:"min_amount"` // Batas minimal pribadi (tidak boleh di bawah batas global)
+	UpdatedAt     time.Time   `json:"updated_at"`
+
+	BankAccount *BankAccount `gorm:"foreignKey:BankAccountID" json:"bank_account,omitempty"`
+}
+
+type PayoutSettingInput struct {
+	AutoPayout    bool        `json:"auto_payout"`
+	BankAccountID *uint64     `json:"bank_account_id"`
+	MinAmount     money.Money `json:"min_amount"`
+}
+
+// Status batch pencairan
+const (
+	PayoutRunDraft     = "DRAFT"     // Baru dibuat, Finance masih bisa keluarkan item
+	PayoutRunApproved  = "APPROVED"  // Disetujui, transfer sedang berjalan
+	PayoutRunCompleted = "COMPLETED" // Semua item sudah SUCCESS/FAILED
+	PayoutRunCancelled = "CANCELLED" // Dibatalkan, item kembali jadi penarikan PENDING biasa
+)
+
+// Metode transfer batch
+const (
+	PayoutMethodAPI      = "API"       // Lewat provider disbursement
+	PayoutMethodBankFile = "BANK_FILE" // Upload CSV manual ke internet banking
+)
+
+// PayoutRun adalah batch penarikan yang di-review & disetujui Finance sekaligus
+type PayoutRun struct {
+	ID          uint64      `gorm:"primaryKey" json:"id"`
+	Status      string      `gorm:"size:20;default:DRAFT;index" json:"status"`
+	Method      string      `gorm:"size:20" json:"method,omitempty"` // Diisi saat approve
+	ItemCount   int         `json:"item_count"`
+	TotalAmount money.Money `gorm:"type:bigint;default:0" json:"total_amount"`
+	CreatedBy   *uint64     `json:"created_by,omitempty"` // NULL = dibuat job mingguan
+	ApprovedBy  *uint64     `json:"approved_by,omitempty"`
+	ApprovedAt  *time.Time  `json:"approved_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+
+	Items []WalletTransaction `gorm:"foreignKey:PayoutRunID" json:"items,omitempty"`
+}
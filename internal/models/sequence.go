@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Sequence penghitung nomor urut per nama (misal ORDER-20261019), dipakai untuk nomor dokumen yang tidak boleh bentrok
+type Sequence struct {
+	Name      string    `gorm:"primaryKey;size:50" json:"name"`
+	Value     uint64    `gorm:"not null;default:0" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
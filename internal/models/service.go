@@ -1,9 +1,15 @@
 package models
 
+import "homecare-backend/pkg/money"
+
 type Service struct {
-	ID          uint    `gorm:"primaryKey" json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	AdminFee    float64 `json:"admin_fee"`
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `gorm:"type:bigint" json:"price"`
+	AdminFee    money.Money `gorm:"type:bigint" json:"admin_fee"`
+
+	// Syarat Mitra yang boleh mengerjakan layanan ini
+	MinExperienceYears   int          `gorm:"default:0" json:"min_experience_years"`
+	RequiredCompetencies []Competency `gorm:"many2many:service_competencies" json:"required_competencies,omitempty"`
 }
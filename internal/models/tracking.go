@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// OrderLocation adalah jejak lokasi Mitra selama menuju lokasi pasien (ASSIGNED/EN_ROUTE)
+type OrderLocation struct {
+	ID         uint64    `gorm:"primaryKey" json:"id"`
+	OrderID    uint64    `gorm:"not null;index:idx_order_location,priority:1" json:"order_id"`
+	PartnerID  uint64    `gorm:"not null" json:"partner_id"` // ID PartnerProfile
+	Lat        float64   `gorm:"type:decimal(11,8)" json:"lat"`
+	Lng        float64   `gorm:"type:decimal(11,8)" json:"lng"`
+	Accuracy   float64   `json:"accuracy"` // Meter (dari GPS HP)
+	Speed      float64   `json:"speed"`    // KM/Jam
+	Heading    float64   `json:"heading"`  // Derajat 0-360
+	RecordedAt time.Time `gorm:"index:idx_order_location,priority:2" json:"recorded_at"`
+}
+
+// Struct inputan ping lokasi dari aplikasi Mitra (Lat/Lng pointer: nilai 0 valid, field kosong ditolak)
+type LocationPingInput struct {
+	Lat      *float64 `json:"lat" binding:"required,min=-90,max=90"`
+	Lng      *float64 `json:"lng" binding:"required,min=-180,max=180"`
+	Accuracy float64  `json:"accuracy"`
+	Speed    float64  `json:"speed"`
+	Heading  float64  `json:"heading"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// OrderVisit adalah catatan kehadiran Mitra di lokasi pasien (check-in / check-out).
+// Jam aktual ini dipakai untuk payroll & deteksi telat / pulang lebih cepat.
+type OrderVisit struct {
+	ID        uint64 `gorm:"primaryKey" json:"id"`
+	OrderID   uint64 `gorm:"uniqueIndex;not null" json:"order_id"`
+	PartnerID uint64 `gorm:"index" json:"partner_id"` // ID PartnerProfile
+
+	CheckInAt        *time.Time `json:"check_in_at"`
+	CheckInLat       float64    `gorm:"type:decimal(11,8)" json:"check_in_lat"`
+	CheckInLng       float64    `gorm:"type:decimal(11,8)" json:"check_in_lng"`
+	CheckInDistanceM float64    `json:"check_in_distance_m"` // Jarak ke alamat pasien saat check-in
+
+	CheckOutAt        *time.Time `json:"check_out_at"`
+	CheckOutLat       float64    `gorm:"type:decimal(11,8)" json:"check_out_lat"`
+	CheckOutLng       float64    `gorm:"type:decimal(11,8)" json:"check_out_lng"`
+	CheckOutDistanceM float64    `json:"check_out_distance_m"`
+
+	// Hasil perhitungan (menit)
+	LateMinutes   int `json:"late_minutes"`   // Check-in setelah jadwal mulai
+	ShortMinutes  int `json:"short_minutes"`  // Check-out sebelum jadwal selesai
+	WorkedMinutes int `json:"worked_minutes"` // Durasi aktual di lokasi
+
+	// Override Admin (misal GPS HP error / pasien pindah alamat sementara)
+	OverrideBy     *uint64    `json:"override_by,omitempty"`
+	OverrideReason string     `gorm:"type:text" json:"override_reason,omitempty"`
+	OverrideAt     *time.Time `json:"override_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Struct inputan koordinat saat check-in / check-out.
+// Pointer supaya koordinat 0 (ekuator / meridian utama) tetap valid, tapi field yang tidak dikirim tetap ditolak.
+type GeofenceInput struct {
+	Lat *float64 `json:"lat" binding:"required,min=-90,max=90"`
+	Lng *float64 `json:"lng" binding:"required,min=-180,max=180"`
+}
@@ -1,12 +1,19 @@
 package models
 
-import "time"
+import (
+	"homecare-backend/pkg/money"
+	"time"
+)
 
 type Wallet struct {
-	ID        uint64    `gorm:"primaryKey" json:"id"`
-	UserID    uint64    `gorm:"unique;not null" json:"user_id"`
-	Balance   float64   `gorm:"default:0" json:"balance"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint64      `gorm:"primaryKey" json:"id"`
+	UserID    uint64      `gorm:"unique;not null" json:"user_id"`
+	Balance   money.Money `gorm:"type:bigint;default:0" json:"balance"` // Saldo tersedia (Mitra: bisa ditarik, customer: bisa dipakai bayar order)
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	// Dihitung dari INCOME yang belum dilepas (tidak disimpan)
+	PendingBalance money.Money `gorm:"-" json:"pending_balance"` // Masih masa tahan
+	FrozenBalance  money.Money `gorm:"-" json:"frozen_balance"`  // Dibekukan karena sengketa
 
 	// Relasi ke History Transaksi
 	Transactions []WalletTransaction `gorm:"foreignKey:WalletID" json:"transactions,omitempty"`
@@ -16,11 +23,39 @@ type Wallet struct {
 }
 
 type WalletTransaction struct {
-	ID        uint64    `gorm:"primaryKey" json:"id"`
-	WalletID  uint64    `json:"wallet_id"`
-	OrderID   *uint64   `json:"order_id,omitempty"` // Bisa null kalau Withdrawal
-	Amount    float64   `json:"amount"`
-	Type      string    `json:"type"`   // INCOME, WITHDRAWAL
-	Status    string    `json:"status"` // PENDING, SUCCESS, FAILED
-	CreatedAt time.Time `json:"created_at"`
+	ID        uint64      `gorm:"primaryKey" json:"id"`
+	WalletID  uint64      `gorm:"uniqueIndex:idx_wallet_idem_key,priority:1" json:"wallet_id"`
+	OrderID   *uint64     `json:"order_id,omitempty"` // Bisa null kalau Withdrawal
+	Amount    money.Money `gorm:"type:bigint" json:"amount"`
+	Type      string      `gorm:"size:20" json:"type"`   // Mitra: INCOME, WITHDRAWAL. Customer: TOPUP, REFUND, PROMO, PAYMENT, PAYMENT_REVERSAL
+	Status    string      `gorm:"size:20" json:"status"` // PENDING, PROCESSING (sedang ditransfer), SUCCESS, FAILED. INCOME: PENDING (ditahan), FROZEN, SUCCESS, REVERSED
+	CreatedAt time.Time   `json:"created_at"`
+
+	Description string `gorm:"size:255" json:"description,omitempty"` // Keterangan (alasan refund, nama promo, dll)
+
+	// Khusus TOPUP: order_no tagihan di payment gateway (TOPUP-<id>)
+	PaymentRef string `gorm:"size:50;index" json:"payment_ref,omitempty"`
+
+	// Khusus INCOME: aturan bagi hasil yang dipakai saat itu (NULL = default tier)
+	CommissionRuleID  *uint64 `json:"commission_rule_id,omitempty"`
+	CommissionPercent float64 `gorm:"type:decimal(5,2)" json:"commission_percent,omitempty"`
+
+	// Khusus INCOME: kapan dana ditahan selesai & bisa ditarik (masa komplain customer)
+	AvailableAt *time.Time `gorm:"index" json:"available_at,omitempty"`
+
+	// Khusus WITHDRAWAL: rekening tujuan (harus VERIFIED)
+	BankAccountID *uint64      `json:"bank_account_id,omitempty"`
+	BankAccount   *BankAccount `gorm:"foreignKey:BankAccountID" json:"bank_account,omitempty"`
+
+	// Khusus WITHDRAWAL: hasil transfer otomatis lewat provider disbursement
+	PayoutProvider string     `gorm:"size:20" json:"payout_provider,omitempty"`
+	PayoutRef      string     `gorm:"size:100;index" json:"payout_ref,omitempty"` // ID transfer di sisi provider
+	FailureReason  string     `gorm:"size:255" json:"failure_reason,omitempty"`
+	ProcessedAt    *time.Time `json:"processed_at,omitempty"` // Kapan status final (SUCCESS/FAILED)
+
+	// Khusus WITHDRAWAL: batch pencairan (NULL = belum masuk batch)
+	PayoutRunID *uint64 `gorm:"index" json:"payout_run_id,omitempty"`
+
+	// Khusus WITHDRAWAL: header Idempotency-Key dari client, unik per wallet (cegah tarik dobel saat retry)
+	IdempotencyKey *string `gorm:"size:64;uniqueIndex:idx_wallet_idem_key,priority:2" json:"-"`
 }
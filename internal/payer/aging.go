@@ -0,0 +1,88 @@
+package payer
+
+import (
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AgingRow umur piutang satu organisasi. Bucket dihitung dari jatuh tempo tagihan.
+type AgingRow struct {
+	OrganizationID uint64      `json:"organization_id"`
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	CreditLimit    money.Money `json:"credit_limit"`
+	Unbilled       money.Money `json:"unbilled"` // Order lunas / berjalan yang belum masuk tagihan bulanan
+	Current        money.Money `json:"current"`  // Belum jatuh tempo
+	Days1To30      money.Money `json:"days_1_30"`
+	Days31To60     money.Money `json:"days_31_60"`
+	Days61To90     money.Money `json:"days_61_90"`
+	Over90         money.Money `json:"over_90"`
+	Total          money.Money `json:"total"`
+}
+
+// Aging laporan umur piutang per organisasi per tanggal asOf (organisasi tanpa piutang tidak ditampilkan)
+func Aging(db *gorm.DB, asOf time.Time) ([]AgingRow, error) {
+	var orgs []models.PayerOrganization
+	if err := db.Order("name asc").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+
+	var invoices []models.OrgInvoice
+	if err := db.Where("status <> ?", models.OrgInvoicePaid).Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+
+	var unbilled []struct {
+		PayerOrganizationID uint64
+		Amount              money.Money
+	}
+	if err := db.Model(&models.Order{}).
+		Select("payer_organization_id, COALESCE(SUM(payer_amount), 0) AS amount").
+		Where("payer_organization_id IS NOT NULL AND org_invoice_id IS NULL AND status <> ?", "CANCELLED").
+		Group("payer_organization_id").
+		Scan(&unbilled).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make(map[uint64]*AgingRow, len(orgs))
+	for _, o := range orgs {
+		rows[o.ID] = &AgingRow{OrganizationID: o.ID, Name: o.Name, Type: o.Type, CreditLimit: o.CreditLimit}
+	}
+	for _, u := range unbilled {
+		if r, ok := rows[u.PayerOrganizationID]; ok {
+			r.Unbilled += u.Amount
+			r.Total += u.Amount
+		}
+	}
+	for _, inv := range invoices {
+		r, ok := rows[inv.OrganizationID]
+		if !ok {
+			continue
+		}
+		remaining := inv.Total - inv.PaidAmount
+		switch days := int(asOf.Sub(inv.DueAt).Hours() / 24); {
+		case !asOf.After(inv.DueAt):
+			r.Current += remaining
+		case days <= 30:
+			r.Days1To30 += remaining
+		case days <= 60:
+			r.Days31To60 += remaining
+		case days <= 90:
+			r.Days61To90 += remaining
+		default:
+			r.Over90 += remaining
+		}
+		r.Total += remaining
+	}
+
+	result := make([]AgingRow, 0, len(orgs))
+	for _, o := range orgs {
+		if r := rows[o.ID]; r.Total > 0 {
+			result = append(result, *r)
+		}
+	}
+	return result, nil
+}
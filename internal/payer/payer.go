@@ -0,0 +1,113 @@
+// Package payer mengurus organisasi penjamin (perusahaan / asuransi): tanggungan order anggota,
+// batas kredit, tagihan bulanan gabungan, pembayaran dari organisasi & umur piutang (AR aging).
+package payer
+
+import (
+	"errors"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrgInactive   = errors.New("organisasi penjamin tidak aktif")
+	ErrNotMember     = errors.New("anda bukan anggota aktif organisasi penjamin ini")
+	ErrNotCovered    = errors.New("layanan ini tidak ditanggung organisasi penjamin")
+	ErrCreditLimit   = errors.New("batas kredit organisasi penjamin sudah tercapai")
+	ErrOverpayment   = errors.New("nominal pembayaran melebihi sisa tagihan")
+	ErrInvoicePaid   = errors.New("tagihan sudah lunas")
+	ErrInvalidPeriod = errors.New("periode tagihan tidak valid")
+)
+
+// Coverage menghitung bagian biaya order yang ditanggung organisasi untuk customer & layanan ini.
+// 1. Organisasi harus aktif & customer anggota aktif yang masa berlakunya mencakup hari ini
+// 2. Aturan khusus layanan diutamakan, kalau tidak ada pakai aturan umum (service_id NULL)
+// 3. Tanggungan = total x persen, dibatasi MaxPerOrder
+func Coverage(db *gorm.DB, orgID, customerID uint64, serviceID uint, total money.Money) (money.Money, error) {
+	var org models.PayerOrganization
+	if err := db.First(&org, orgID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrNotMember
+		}
+		return 0, err
+	}
+	if !org.IsActive {
+		return 0, ErrOrgInactive
+	}
+
+	now := time.Now()
+	var member models.PayerMember
+	err := db.Where("organization_id = ? AND user_id = ? AND is_active = ?", orgID, customerID, true).
+		Where("valid_from IS NULL OR valid_from <= ?", now).
+		Where("valid_until IS NULL OR valid_until >= ?", now).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrNotMember
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var rule models.PayerCoverage
+	err = db.Where("organization_id = ? AND (service_id = ? OR service_id IS NULL)", orgID, serviceID).
+		Order("service_id IS NULL"). // Aturan khusus layanan dulu
+		First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrNotCovered
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	covered := total.MulBps(rule.CoverageBps)
+	if rule.MaxPerOrder > 0 && covered > rule.MaxPerOrder {
+		covered = rule.MaxPerOrder
+	}
+	if covered <= 0 {
+		return 0, ErrNotCovered
+	}
+	return min(covered, total), nil
+}
+
+// ReserveCredit memastikan tanggungan order baru masih muat di batas kredit organisasi (di dalam transaksi order).
+// Baris organisasi dikunci supaya order paralel anggota lain tidak sama-sama lolos melewati batas.
+func ReserveCredit(tx *gorm.DB, orgID uint64, amount money.Money) error {
+	var org models.PayerOrganization
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&org, orgID).Error; err != nil {
+		return err
+	}
+	if !org.IsActive {
+		return ErrOrgInactive
+	}
+	outstanding, err := Outstanding(tx, orgID)
+	if err != nil {
+		return err
+	}
+	if outstanding+amount > org.CreditLimit {
+		return ErrCreditLimit
+	}
+	return nil
+}
+
+// Outstanding total kewajiban organisasi yang belum dibayar:
+// tanggungan order yang belum masuk tagihan bulanan (termasuk yang masih menunggu pembayaran customer)
+// + sisa tagihan bulanan yang belum lunas
+func Outstanding(db *gorm.DB, orgID uint64) (money.Money, error) {
+	var unbilled, billed money.Money
+	if err := db.Model(&models.Order{}).
+		Select("COALESCE(SUM(payer_amount), 0)").
+		Where("payer_organization_id = ? AND org_invoice_id IS NULL AND status <> ?", orgID, "CANCELLED").
+		Scan(&unbilled).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.OrgInvoice{}).
+		Select("COALESCE(SUM(total - paid_amount), 0)").
+		Where("organization_id = ? AND status <> ?", orgID, models.OrgInvoicePaid).
+		Scan(&billed).Error; err != nil {
+		return 0, err
+	}
+	return unbilled + billed, nil
+}
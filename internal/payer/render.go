@@ -0,0 +1,136 @@
+package payer
+
+import (
+	"fmt"
+	"homecare-backend/internal/invoice"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/pdf"
+	"io"
+)
+
+// Posisi kolom tabel order (point dari kiri)
+const (
+	marginLeft  = 50.0
+	marginRight = pdf.PageWidth - 50
+	colDate     = 165.0
+	colName     = 230.0
+	colTotal    = 440.0
+)
+
+// Render menulis tagihan bulanan organisasi dalam format PDF (A4). Order & Organization harus sudah di-preload
+// (Orders.Patient, Orders.Service), daftar order yang panjang dilanjutkan ke halaman berikutnya.
+func Render(inv *models.OrgInvoice, w io.Writer) error {
+	seller := invoice.SellerInfo()
+	doc := pdf.New()
+
+	// 1. Kop & info tagihan
+	y := 60.0
+	doc.Text(marginLeft, y, 16, true, seller.Name)
+	doc.TextRight(marginRight, y, 18, true, "TAGIHAN")
+	y += 16
+	if seller.Address != "" {
+		doc.Text(marginLeft, y, 9, false, seller.Address)
+	}
+	doc.TextRight(marginRight, y, 10, false, inv.InvoiceNo)
+	y += 12
+	if seller.NPWP != "" {
+		doc.Text(marginLeft, y, 9, false, "NPWP: "+seller.NPWP)
+	}
+	y += 14
+	doc.Line(marginLeft, y, marginRight, y)
+
+	y += 22
+	doc.Text(marginLeft, y, 10, true, "Ditagihkan kepada")
+	doc.Text(320, y, 10, true, "Detail")
+	var billTo []string
+	if inv.Organization != nil {
+		billTo = []string{inv.Organization.Name, inv.Organization.Address, inv.Organization.Email}
+		if inv.Organization.NPWP != "" {
+			billTo = append(billTo, "NPWP: "+inv.Organization.NPWP)
+		}
+	}
+	info := [][2]string{
+		{"Tanggal Tagihan", invoice.FormatDate(inv.IssuedAt)},
+		{"Periode", invoice.FormatDate(inv.PeriodStart) + " - " + invoice.FormatDate(inv.PeriodEnd.AddDate(0, 0, -1))},
+		{"Jatuh Tempo", invoice.FormatDate(inv.DueAt)},
+		{"Jumlah Order", fmt.Sprintf("%d", inv.OrderCount)},
+	}
+	for i := 0; i < max(len(info), len(billTo)); i++ {
+		y += 14
+		if i < len(billTo) {
+			doc.Text(marginLeft, y, 10, false, billTo[i])
+		}
+		if i < len(info) {
+			doc.Text(320, y, 9, false, info[i][0])
+			doc.TextRight(marginRight, y, 9, false, info[i][1])
+		}
+	}
+
+	// 2. Daftar order yang ditanggung
+	header := func() {
+		doc.Text(marginLeft, y, 9, true, "No. Order")
+		doc.Text(colDate, y, 9, true, "Tanggal")
+		doc.Text(colName, y, 9, true, "Pasien / Layanan")
+		doc.TextRight(colTotal, y, 9, true, "Total Order")
+		doc.TextRight(marginRight, y, 9, true, "Ditanggung")
+		y += 6
+		doc.Line(marginLeft, y, marginRight, y)
+	}
+	y += 30
+	header()
+	for _, o := range inv.Orders {
+		if y > pdf.PageHeight-110 {
+			doc.AddPage()
+			y = 60
+			header()
+		}
+		y += 15
+		desc := ""
+		if o.Patient != nil {
+			desc = o.Patient.Name
+		}
+		if o.Service != nil {
+			desc += " - " + o.Service.Name
+		}
+		doc.Text(marginLeft, y, 8, false, o.OrderNo)
+		doc.Text(colDate, y, 8, false, o.ScheduleStart.Format("02-01-2006"))
+		doc.Text(colName, y, 8, false, fit(desc, colTotal-colName-70, 8))
+		doc.TextRight(colTotal, y, 8, false, o.TotalAmount.String())
+		doc.TextRight(marginRight, y, 8, false, o.PayerAmount.String())
+	}
+	y += 8
+	doc.Line(marginLeft, y, marginRight, y)
+
+	// 3. Ringkasan
+	summary := func(label, amount string, bold bool) {
+		y += 16
+		doc.Text(colTotal-60, y, 10, bold, label)
+		doc.TextRight(marginRight, y, 10, bold, amount)
+	}
+	summary("Total Tagihan", inv.Total.String(), true)
+	if inv.PaidAmount > 0 {
+		summary("Sudah Dibayar", (-inv.PaidAmount).String(), false)
+		summary("Sisa Tagihan", (inv.Total - inv.PaidAmount).String(), true)
+	}
+
+	// 4. Catatan kaki
+	y = pdf.PageHeight - 60
+	doc.Line(marginLeft, y-12, marginRight, y-12)
+	doc.Text(marginLeft, y, 8, false, "Mohon cantumkan nomor tagihan pada berita transfer.")
+	doc.Text(marginLeft, y+11, 8, false, "Tagihan ini diterbitkan secara elektronik dan sah tanpa tanda tangan.")
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// fit memotong teks supaya muat di lebar kolom
+func fit(s string, width, size float64) string {
+	if pdf.TextWidth(s, size, false) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.TextWidth(string(r)+"...", size, false) > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
@@ -0,0 +1,151 @@
+package payer
+
+import (
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/sequence"
+	"homecare-backend/pkg/money"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MonthStart tanggal 1 bulan dari t (jam 00:00, zona waktu t)
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// GenerateInvoices menerbitkan tagihan bulanan untuk semua organisasi aktif, periode bulan `periodStart`.
+// Yang ditagih: order anggota yang sudah SELESAI dikerjakan, lunas sebelum akhir periode & belum pernah ditagih
+// (order bulan lalu yang baru selesai ikut tertagih di periode ini). Aman dijalankan ulang: order hanya ditagih sekali.
+func GenerateInvoices(db *gorm.DB, periodStart time.Time) ([]models.OrgInvoice, error) {
+	periodStart = MonthStart(periodStart)
+	if periodStart.After(time.Now()) {
+		return nil, ErrInvalidPeriod
+	}
+
+	var orgs []models.PayerOrganization
+	if err := db.Where("is_active = ?", true).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+
+	var issued []models.OrgInvoice
+	for _, org := range orgs {
+		inv, err := generateInvoice(db, org, periodStart)
+		if err != nil {
+			log.Printf("[Payer] Gagal membuat tagihan %s periode %s: %v", org.Name, periodStart.Format("2006-01"), err)
+			continue
+		}
+		if inv != nil {
+			issued = append(issued, *inv)
+		}
+	}
+	return issued, nil
+}
+
+// generateInvoice tagihan satu organisasi. Return nil kalau tidak ada order yang perlu ditagih.
+func generateInvoice(db *gorm.DB, org models.PayerOrganization, periodStart time.Time) (*models.OrgInvoice, error) {
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	var inv *models.OrgInvoice
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Kunci order yang akan ditagih: generate paralel (job & Finance) tidak boleh menagih order yang sama dua kali
+		var orders []models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payer_organization_id = ? AND org_invoice_id IS NULL AND status = ? AND paid_at < ?",
+				org.ID, "COMPLETED", periodEnd).
+			Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		var total money.Money
+		ids := make([]uint64, 0, len(orders))
+		for _, o := range orders {
+			total += o.PayerAmount
+			ids = append(ids, o.ID)
+		}
+
+		now := time.Now()
+		seq, err := sequence.Next(tx, fmt.Sprintf("ORG-INVOICE-%d", now.Year()))
+		if err != nil {
+			return err
+		}
+		inv = &models.OrgInvoice{
+			InvoiceNo:      fmt.Sprintf("CORP/%d/%02d/%06d", now.Year(), now.Month(), seq),
+			OrganizationID: org.ID,
+			PeriodStart:    periodStart,
+			PeriodEnd:      periodEnd,
+			OrderCount:     len(orders),
+			Total:          total,
+			Status:         models.OrgInvoiceIssued,
+			IssuedAt:       now,
+			DueAt:          now.AddDate(0, 0, max(org.PaymentTermDays, 1)),
+		}
+		if err := tx.Create(inv).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Order{}).Where("id IN ?", ids).Update("org_invoice_id", inv.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// RecordPayment mencatat transfer dari organisasi untuk satu tagihan (boleh dicicil).
+// Ledger: uang masuk ke rekening bank, piutang berkurang.
+func RecordPayment(db *gorm.DB, invoiceID uint64, amount money.Money, paidAt time.Time, reference, note string, recordedBy uint64) (models.OrgPayment, error) {
+	var p models.OrgPayment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var inv models.OrgInvoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, invoiceID).Error; err != nil {
+			return err
+		}
+		if inv.Status == models.OrgInvoicePaid {
+			return ErrInvoicePaid
+		}
+		remaining := inv.Total - inv.PaidAmount
+		if amount > remaining {
+			return ErrOverpayment
+		}
+
+		p = models.OrgPayment{
+			OrgInvoiceID: inv.ID,
+			Amount:       amount,
+			PaidAt:       paidAt,
+			Reference:    reference,
+			Note:         note,
+			RecordedBy:   recordedBy,
+		}
+		if err := tx.Create(&p).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"paid_amount": inv.PaidAmount + amount, "status": models.OrgInvoicePartial}
+		if amount == remaining {
+			updates["status"] = models.OrgInvoicePaid
+			updates["paid_at"] = paidAt
+		}
+		if err := tx.Model(&inv).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		_, err := ledger.Post(tx, ledger.Entry{
+			Reference:   fmt.Sprintf("ORG_PAYMENT:%d", p.ID),
+			Kind:        "ORG_PAYMENT",
+			Description: "Pembayaran tagihan " + inv.InvoiceNo,
+			Lines: []ledger.Line{
+				ledger.Debit(ledger.Bank, amount),
+				ledger.Credit(ledger.CustomerReceivable, amount),
+			},
+		})
+		return err
+	})
+	return p, err
+}
@@ -0,0 +1,39 @@
+package payout
+
+import (
+	"encoding/csv"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/disbursement"
+	"io"
+	"strings"
+)
+
+// WriteBankFile menulis CSV untuk upload transfer massal di internet banking
+// (dipakai untuk bank yang belum bisa transfer lewat API). Item batch harus sudah di-preload beserta BankAccount.
+func WriteBankFile(w io.Writer, run models.PayoutRun) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"no", "reference", "bank_code", "bank_name", "account_number", "account_name", "amount", "description"})
+
+	no := 0
+	for _, item := range run.Items {
+		if item.Status != StatusProcessing || item.BankAccount == nil {
+			continue
+		}
+		no++
+		account := item.BankAccount
+		writer.Write([]string{
+			fmt.Sprintf("%d", no),
+			Reference(item.ID),
+			strings.ToUpper(account.BankCode),
+			disbursement.SupportedBanks[account.BankCode],
+			account.AccountNumber,
+			account.HolderName,
+			fmt.Sprintf("%d", item.Amount.Int64()),
+			fmt.Sprintf("Pencairan Homecare batch %d", run.ID),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
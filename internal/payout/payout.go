@@ -0,0 +1,279 @@
+// Package payout mengatur siklus penarikan dana Mitra:
+// PENDING -> (approve Finance) -> PROCESSING -> (transfer provider / callback) -> SUCCESS | FAILED.
+// Kalau FAILED (ditolak Finance atau transfer gagal), saldo otomatis dikembalikan ke wallet.
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/utils"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status penarikan
+const (
+	StatusPending    = "PENDING"
+	StatusProcessing = "PROCESSING"
+	StatusSuccess    = "SUCCESS"
+	StatusFailed     = "FAILED"
+)
+
+// Prefix ReferenceID yang dikirim ke provider: WD-<id wallet_transaction>
+const referencePrefix = "WD-"
+
+var (
+	ErrNotFound         = errors.New("penarikan tidak ditemukan")
+	ErrAlreadyProcessed = errors.New("penarikan sudah diproses sebelumnya")
+)
+
+// Reference ID transfer untuk satu penarikan
+func Reference(trxID uint64) string {
+	return fmt.Sprintf("%s%d", referencePrefix, trxID)
+}
+
+// Approve mengubah PENDING -> PROCESSING (atomik, aman kalau dua Finance klik bersamaan)
+func Approve(db *gorm.DB, trxID uint64) error {
+	res := db.Model(&models.WalletTransaction{}).
+		Where("id = ? AND type = ? AND status = ?", trxID, "WITHDRAWAL", StatusPending).
+		Update("status", StatusProcessing)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAlreadyProcessed
+	}
+	return nil
+}
+
+// Reject menolak penarikan PENDING & mengembalikan saldo
+func Reject(db *gorm.DB, trxID uint64, reason string) error {
+	return finish(db, trxID, StatusPending, false, reason)
+}
+
+// Settle menerapkan status final transfer untuk penarikan PROCESSING.
+// Idempoten: callback yang dikirim ulang provider akan mendapat ErrAlreadyProcessed.
+func Settle(db *gorm.DB, trxID uint64, success bool, reason string) error {
+	return finish(db, trxID, StatusProcessing, success, reason)
+}
+
+// Execute mengirim transfer ke provider. Dipanggil di goroutine setelah Approve.
+func Execute(db *gorm.DB, provider disbursement.Provider, trxID uint64) {
+	var trx models.WalletTransaction
+	if err := db.Unscoped().Preload("BankAccount").First(&trx, trxID).Error; err != nil || trx.BankAccount == nil {
+		log.Printf("[Payout] Penarikan %d / rekening tidak ditemukan: %v", trxID, err)
+		return
+	}
+	if trx.Status != StatusProcessing {
+		return // Sudah final (callback / settle manual) sebelum sempat dikirim
+	}
+
+	// Catat provider SEBELUM transfer: kalau proses mati di tengah jalan, sweep tahu harus cek/kirim ulang ke mana
+	if err := db.Model(&models.WalletTransaction{}).Where("id = ?", trx.ID).Update("payout_provider", provider.Name()).Error; err != nil {
+		log.Printf("[Payout] Gagal mencatat provider penarikan %d, transfer tidak dikirim: %v", trx.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	account := trx.BankAccount
+	result, err := provider.Disburse(ctx, disbursement.PayoutRequest{
+		ReferenceID:   Reference(trx.ID),
+		BankCode:      account.BankCode,
+		AccountNumber: account.AccountNumber,
+		AccountName:   account.HolderName,
+		Amount:        trx.Amount,
+		Description:   "Pencairan saldo Mitra Homecare",
+	})
+
+	if errors.Is(err, disbursement.ErrRejected) {
+		// Ditolak pasti oleh provider -> kembalikan saldo
+		if err := Settle(db, trx.ID, false, err.Error()); err != nil {
+			log.Printf("[Payout] Gagal settle penarikan %d: %v", trx.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		// Status belum pasti (timeout/5xx): biarkan PROCESSING, Finance cek di dashboard provider lalu settle manual
+		log.Printf("[Payout] ⚠️ Transfer penarikan %d via %s belum pasti: %v", trx.ID, provider.Name(), err)
+		// Sweep (RetryUnsubmitted) akan mengirim ulang dengan ReferenceID yang sama
+		db.Model(&models.WalletTransaction{}).Where("id = ?", trx.ID).
+			Update("failure_reason", truncate("Status transfer belum pasti: "+err.Error(), 255))
+		return
+	}
+
+	db.Model(&models.WalletTransaction{}).Where("id = ?", trx.ID).Updates(map[string]interface{}{
+		"payout_ref":     result.ProviderRef,
+		"failure_reason": truncate(result.FailureReason, 255),
+	})
+
+	// Beberapa provider (fake) langsung memberi status final
+	if result.Status != disbursement.PayoutPending {
+		if err := Settle(db, trx.ID, result.Status == disbursement.PayoutSuccess, result.FailureReason); err != nil {
+			log.Printf("[Payout] Gagal settle penarikan %d: %v", trx.ID, err)
+		}
+	}
+}
+
+// RetryUnsubmitted mengirim ulang penarikan PROCESSING yang belum punya payout_ref
+// (goroutine Execute mati / server restart, atau error provider yang statusnya belum pasti).
+// Aman diulang: ReferenceID (WD-<id>) jadi idempotency key di provider, jadi transfer tidak dobel.
+// Penarikan yang tercatat di provider lain (misal bank_file) dilewati, itu urusan Finance.
+func RetryUnsubmitted(db *gorm.DB, provider disbursement.Provider) (int, error) {
+	var ids []uint64
+	err := db.Model(&models.WalletTransaction{}).
+		Where("type = ? AND status = ?", "WITHDRAWAL", StatusProcessing).
+		Where("payout_ref IS NULL OR payout_ref = ''").
+		Where("payout_provider IS NULL OR payout_provider = '' OR payout_provider = ?", provider.Name()).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		Execute(db, provider, id)
+	}
+	return len(ids), nil
+}
+
+// HandleCallback menerapkan callback provider. Return ID penarikan yang terkait.
+func HandleCallback(db *gorm.DB, result disbursement.PayoutResult) (uint64, error) {
+	trxID, err := findByResult(db, result)
+	if err != nil {
+		return 0, err
+	}
+	if result.Status == disbursement.PayoutPending {
+		return trxID, nil // Status antara (approved/processed), tunggu callback berikutnya
+	}
+	return trxID, Settle(db, trxID, result.Status == disbursement.PayoutSuccess, result.FailureReason)
+}
+
+// findByResult mencari penarikan dari ReferenceID (WD-<id>) atau ID transfer di provider
+func findByResult(db *gorm.DB, result disbursement.PayoutResult) (uint64, error) {
+	if strings.HasPrefix(result.ReferenceID, referencePrefix) {
+		id, err := strconv.ParseUint(strings.TrimPrefix(result.ReferenceID, referencePrefix), 10, 64)
+		if err == nil {
+			return id, nil
+		}
+	}
+
+	if result.ProviderRef != "" {
+		var trx models.WalletTransaction
+		if err := db.Where("payout_ref = ?", result.ProviderRef).First(&trx).Error; err == nil {
+			return trx.ID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// finish: ubah status from -> SUCCESS/FAILED, kembalikan saldo kalau gagal, catat ledger, lalu kabari Mitra
+func finish(db *gorm.DB, trxID uint64, from string, success bool, reason string) error {
+	var trx models.WalletTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&trx, trxID).Error; err != nil {
+			return ErrNotFound
+		}
+
+		now := time.Now()
+		status := StatusSuccess
+		if !success {
+			status = StatusFailed
+		}
+
+		// Kunci status secara atomik: hanya satu proses yang bisa menyelesaikan penarikan ini
+		res := tx.Model(&models.WalletTransaction{}).
+			Where("id = ? AND type = ? AND status = ?", trx.ID, "WITHDRAWAL", from).
+			Updates(map[string]interface{}{
+				"status":         status,
+				"failure_reason": truncate(reason, 255),
+				"processed_at":   now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrAlreadyProcessed
+		}
+		trx.Status = status
+		trx.FailureReason = reason
+
+		// Ledger: penarikan dalam proses keluar dari bank, atau dikembalikan ke wallet
+		journal := ledger.Entry{Reference: fmt.Sprintf("WITHDRAWAL_PAID:%d", trx.ID), Kind: "WITHDRAWAL_PAID", Description: "Penarikan dana ditransfer"}
+		journal.Lines = []ledger.Line{
+			ledger.Debit(ledger.PayoutClearing, trx.Amount),
+			ledger.Credit(ledger.Bank, trx.Amount),
+		}
+
+		if !success {
+			// KEMBALIKAN SALDO KE DOMPET MITRA (atomik)
+			if err := wallet.Credit(tx, trx.WalletID, trx.Amount); err != nil {
+				return err
+			}
+			kind := "WITHDRAWAL_FAILED"
+			if from == StatusPending {
+				kind = "WITHDRAWAL_REJECT"
+			}
+			journal = ledger.Entry{Reference: fmt.Sprintf("%s:%d", kind, trx.ID), Kind: kind, Description: "Penarikan dana gagal/ditolak, saldo dikembalikan"}
+			journal.Lines = []ledger.Line{
+				ledger.Debit(ledger.PayoutClearing, trx.Amount),
+				ledger.Credit(ledger.WalletAccount(trx.WalletID), trx.Amount),
+			}
+		}
+
+		_, err := ledger.Post(tx, journal)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyPartner(db, trx, from == StatusPending)
+	if trx.PayoutRunID != nil {
+		refreshRun(db, *trx.PayoutRunID)
+	}
+	return nil
+}
+
+// notifyPartner kirim push notif hasil penarikan ke Mitra
+func notifyPartner(db *gorm.DB, trx models.WalletTransaction, rejected bool) {
+	var w models.Wallet
+	if err := db.Preload("User").First(&w, trx.WalletID).Error; err != nil || w.User.FCMToken == "" {
+		return
+	}
+
+	title := "Penarikan Dana Berhasil! 💰"
+	body := fmt.Sprintf("Penarikan dana sebesar %s telah ditransfer ke rekening Anda.", trx.Amount)
+	typeNotif := "withdrawal_approved"
+	if trx.Status == StatusFailed {
+		title = "Penarikan Dana Gagal ❌"
+		body = "Maaf, transfer penarikan dana Anda gagal. Saldo telah dikembalikan."
+		typeNotif = "withdrawal_failed"
+		if rejected {
+			title = "Penarikan Dana Ditolak ❌"
+			body = "Maaf, permintaan penarikan dana Anda ditolak. Saldo telah dikembalikan."
+			typeNotif = "withdrawal_rejected"
+		}
+	}
+
+	utils.SendNotification(w.User.FCMToken, title, body, map[string]string{
+		"transaction_id": fmt.Sprintf("%d", trx.ID),
+		"type":           typeNotif,
+	})
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
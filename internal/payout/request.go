@@ -0,0 +1,50 @@
+package payout
+
+import (
+	"fmt"
+	"homecare-backend/internal/ledger"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/money"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Request mengajukan penarikan ke rekening: potong saldo dulu (lock balance), catat WITHDRAWAL PENDING & ledger.
+// Wajib dipanggil di dalam transaksi DB. Kalau nanti ditolak/gagal, saldo dikembalikan lewat Reject/Settle.
+func Request(tx *gorm.DB, walletID uint64, account models.BankAccount, amount money.Money, idemKey string, runID *uint64) (models.WalletTransaction, error) {
+	// Cek saldo & pengurangan atomik di DB (UPDATE ... WHERE balance >= ?)
+	if err := wallet.Debit(tx, walletID, amount); err != nil {
+		return models.WalletTransaction{}, err
+	}
+
+	// Catat di History
+	trx := models.WalletTransaction{
+		WalletID:      walletID,
+		Amount:        amount,
+		Type:          "WITHDRAWAL",
+		Status:        StatusPending,
+		BankAccountID: &account.ID,
+		PayoutRunID:   runID,
+		// OrderID kosong karena ini bukan dari order
+	}
+	if idemKey != "" {
+		trx.IdempotencyKey = &idemKey
+	}
+	if err := tx.Create(&trx).Error; err != nil {
+		return trx, err
+	}
+
+	// Ledger: hutang ke Mitra pindah ke penarikan dalam proses
+	_, err := ledger.Post(tx, ledger.Entry{
+		Reference:   fmt.Sprintf("WITHDRAWAL_REQUEST:%d", trx.ID),
+		Kind:        "WITHDRAWAL_REQUEST",
+		Description: fmt.Sprintf("Pengajuan penarikan ke %s %s", strings.ToUpper(account.BankCode), account.AccountNumber),
+		Lines: []ledger.Line{
+			ledger.Debit(ledger.WalletAccount(walletID), amount),
+			ledger.Credit(ledger.PayoutClearing, amount),
+		},
+	})
+	return trx, err
+}
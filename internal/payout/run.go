@@ -0,0 +1,255 @@
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/disbursement"
+	"homecare-backend/pkg/money"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmptyRun = errors.New("tidak ada penarikan PENDING untuk dimasukkan ke batch")
+	ErrRunState = errors.New("status batch tidak sesuai untuk aksi ini")
+)
+
+// MinAmount batas minimal saldo untuk pencairan otomatis.
+// Config .env: PAYOUT_MIN_AMOUNT (default 50000)
+func MinAmount() money.Money {
+	if v, err := strconv.ParseInt(os.Getenv("PAYOUT_MIN_AMOUNT"), 10, 64); err == nil && v > 0 {
+		return money.Money(v)
+	}
+	return 50000
+}
+
+// CollectAutoPayouts membuat penarikan PENDING untuk semua Mitra yang mengaktifkan pencairan otomatis
+// & saldonya sudah mencapai batas minimal. Seluruh saldo yang tersedia ditarik.
+// Idempotency key per minggu (AUTO-2026-W42) mencegah penarikan dobel kalau job jalan dua kali.
+func CollectAutoPayouts(db *gorm.DB, at time.Time) (int, error) {
+	var settings []models.PayoutSetting
+	// Hanya Mitra: saldo customer (refund/promo/top-up) tidak bisa dicairkan
+	if err := db.Preload("BankAccount").
+		Joins("JOIN users ON users.id = payout_settings.user_id AND users.role_id = ?", 3).
+		Where("payout_settings.auto_payout = ? AND payout_settings.bank_account_id IS NOT NULL", true).
+		Find(&settings).Error; err != nil {
+		return 0, err
+	}
+
+	year, week := at.ISOWeek()
+	key := fmt.Sprintf("AUTO-%d-W%02d", year, week)
+
+	created := 0
+	for _, s := range settings {
+		account := s.BankAccount
+		if account == nil || account.UserID != s.UserID || account.Status != "VERIFIED" {
+			log.Printf("[Payout] Auto payout user %d dilewati: rekening tidak valid", s.UserID)
+			continue
+		}
+
+		threshold := MinAmount()
+		if s.MinAmount > threshold {
+			threshold = s.MinAmount
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			w, err := wallet.ForUser(tx, s.UserID) // Kunci wallet sampai selesai
+			if err != nil {
+				return err
+			}
+			if w.Balance < threshold {
+				return nil
+			}
+
+			var existing int64
+			tx.Model(&models.WalletTransaction{}).Where("wallet_id = ? AND idempotency_key = ?", w.ID, key).Count(&existing)
+			if existing > 0 {
+				return nil // Minggu ini sudah diajukan
+			}
+
+			if _, err := Request(tx, w.ID, *account, w.Balance, key, nil); err != nil {
+				return err
+			}
+			created++
+			return nil
+		})
+		if err != nil {
+			log.Printf("[Payout] Gagal auto payout user %d: %v", s.UserID, err)
+		}
+	}
+	return created, nil
+}
+
+// CreateRun mengumpulkan semua penarikan PENDING yang belum masuk batch menjadi satu batch DRAFT
+func CreateRun(db *gorm.DB, createdBy *uint64) (models.PayoutRun, error) {
+	run := models.PayoutRun{Status: models.PayoutRunDraft, CreatedBy: createdBy}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.WalletTransaction{}).
+			Where("type = ? AND status = ? AND payout_run_id IS NULL", "WITHDRAWAL", StatusPending).
+			Update("payout_run_id", run.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrEmptyRun
+		}
+		return recount(tx, &run)
+	})
+	return run, err
+}
+
+// RemoveItem mengeluarkan satu penarikan dari batch DRAFT (kembali jadi penarikan PENDING biasa)
+func RemoveItem(db *gorm.DB, run *models.PayoutRun, trxID uint64) error {
+	if run.Status != models.PayoutRunDraft {
+		return ErrRunState
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.WalletTransaction{}).
+			Where("id = ? AND payout_run_id = ?", trxID, run.ID).
+			Update("payout_run_id", nil)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return recount(tx, run)
+	})
+}
+
+// CancelRun membatalkan batch DRAFT, semua item dilepas dari batch
+func CancelRun(db *gorm.DB, run *models.PayoutRun) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.PayoutRun{}).
+			Where("id = ? AND status = ?", run.ID, models.PayoutRunDraft).
+			Update("status", models.PayoutRunCancelled)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRunState
+		}
+		run.Status = models.PayoutRunCancelled
+
+		return tx.Model(&models.WalletTransaction{}).
+			Where("payout_run_id = ? AND status = ?", run.ID, StatusPending).
+			Update("payout_run_id", nil).Error
+	})
+}
+
+// ApproveRun menyetujui batch sekaligus: semua item PENDING -> PROCESSING.
+// Metode API: lanjut panggil ExecuteRun. Metode BANK_FILE: Finance download CSV lalu CompleteBankFile.
+func ApproveRun(db *gorm.DB, run *models.PayoutRun, approver uint64, method string) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.PayoutRun{}).
+			Where("id = ? AND status = ?", run.ID, models.PayoutRunDraft).
+			Updates(map[string]interface{}{
+				"status":      models.PayoutRunApproved,
+				"method":      method,
+				"approved_by": approver,
+				"approved_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRunState
+		}
+		run.Status = models.PayoutRunApproved
+		run.Method = method
+		run.ApprovedBy = &approver
+		run.ApprovedAt = &now
+
+		updates := map[string]interface{}{"status": StatusProcessing}
+		if method == models.PayoutMethodBankFile {
+			updates["payout_provider"] = "bank_file"
+		}
+		return tx.Model(&models.WalletTransaction{}).
+			Where("payout_run_id = ? AND status = ?", run.ID, StatusPending).
+			Updates(updates).Error
+	})
+}
+
+// ExecuteRun mengirim transfer semua item batch yang masih PROCESSING ke provider (dipanggil di goroutine)
+func ExecuteRun(db *gorm.DB, provider disbursement.Provider, runID uint64) {
+	var ids []uint64
+	db.Model(&models.WalletTransaction{}).
+		Where("payout_run_id = ? AND status = ? AND (payout_ref IS NULL OR payout_ref = '')", runID, StatusProcessing).
+		Pluck("id", &ids)
+
+	for _, id := range ids {
+		Execute(db, provider, id)
+	}
+	log.Printf("[Payout] Batch %d: %d transfer dikirim ke %s", runID, len(ids), provider.Name())
+}
+
+// CompleteBankFile mencatat hasil upload CSV ke bank: semua item PROCESSING jadi SUCCESS,
+// kecuali yang ada di `failed` (id -> alasan) jadi FAILED & saldonya dikembalikan.
+func CompleteBankFile(db *gorm.DB, run *models.PayoutRun, failed map[uint64]string) (int, error) {
+	if run.Status != models.PayoutRunApproved || run.Method != models.PayoutMethodBankFile {
+		return 0, ErrRunState
+	}
+
+	var ids []uint64
+	db.Model(&models.WalletTransaction{}).
+		Where("payout_run_id = ? AND status = ?", run.ID, StatusProcessing).
+		Pluck("id", &ids)
+
+	settled := 0
+	for _, id := range ids {
+		reason, isFailed := failed[id]
+		err := Settle(db, id, !isFailed, reason)
+		if err != nil && !errors.Is(err, ErrAlreadyProcessed) {
+			return settled, err
+		}
+		if err == nil {
+			settled++
+		}
+	}
+	return settled, nil
+}
+
+// recount menghitung ulang jumlah item & total nominal batch
+func recount(tx *gorm.DB, run *models.PayoutRun) error {
+	var sum struct {
+		Count int
+		Total money.Money
+	}
+	tx.Model(&models.WalletTransaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+		Where("payout_run_id = ?", run.ID).
+		Scan(&sum)
+
+	run.ItemCount = sum.Count
+	run.TotalAmount = sum.Total
+	return tx.Model(run).Updates(map[string]interface{}{
+		"item_count":   sum.Count,
+		"total_amount": sum.Total,
+	}).Error
+}
+
+// refreshRun menandai batch COMPLETED kalau semua itemnya sudah final
+func refreshRun(db *gorm.DB, runID uint64) {
+	var open int64
+	db.Model(&models.WalletTransaction{}).
+		Where("payout_run_id = ? AND status IN ?", runID, []string{StatusPending, StatusProcessing}).
+		Count(&open)
+	if open > 0 {
+		return
+	}
+	db.Model(&models.PayoutRun{}).
+		Where("id = ? AND status = ?", runID, models.PayoutRunApproved).
+		Update("status", models.PayoutRunCompleted)
+}
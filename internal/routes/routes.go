@@ -23,7 +23,10 @@ func SetupRoutes(r *gin.Engine) {
 
 		// Route Layanan (Bisa diakses publik biar orang bisa liat harga dulu)
 		api.GET("/services", handlers.GetServices)
+		api.GET("/competencies", handlers.GetCompetencies)
 		api.POST("/payment/notification", handlers.HandleMidtransNotification)
+		api.POST("/payment/fake/simulate", handlers.SimulateFakePayment) // Hanya aktif dengan PAYMENT_GATEWAY=fake
+		api.POST("/disbursement/callback", handlers.HandleDisbursementCallback)
 		api.GET("/partners/search", handlers.SearchPartners)
 
 		// 2. PROTECTED ROUTES (Harus Login / Punya Token)
@@ -41,6 +44,21 @@ func SetupRoutes(r *gin.Engine) {
 			protected.POST("/orders", handlers.CreateOrder)
 			protected.GET("/orders", handlers.GetMyOrders)
 			protected.GET("/orders/:id", handlers.GetOrderDetail)
+			protected.POST("/orders/:id/pay", handlers.RetryOrderPayment) // Link pembayaran baru kalau yang lama kedaluwarsa
+			protected.GET("/orders/:id/invoice", handlers.GetOrderInvoice)
+			protected.GET("/orders/:id/invoice/pdf", handlers.DownloadOrderInvoice)
+			protected.GET("/orders/:id/tracking", handlers.GetOrderTracking)
+			protected.GET("/orders/:id/tracking/stream", handlers.StreamOrderTracking) // SSE
+			protected.POST("/orders/:id/review", handlers.CreateOrderReview)
+			protected.POST("/orders/:id/confirm", handlers.ConfirmOrderCompletion) // Lepas pendapatan Mitra lebih awal
+			protected.POST("/orders/:id/dispute", handlers.CreateOrderDispute)
+
+			// SALDO CUSTOMER (refund, promo, top-up)
+			protected.GET("/wallet", handlers.GetMyBalance)
+			protected.POST("/wallet/topup", handlers.TopupBalance)
+
+			// PENJAMIN (perusahaan / asuransi yang menanggung order customer)
+			protected.GET("/payers", handlers.GetMyPayers)
 
 			// Group Khusus Mitra
 			partner := protected.Group("/partner")
@@ -48,13 +66,34 @@ func SetupRoutes(r *gin.Engine) {
 				partner.PUT("/profile", handlers.UpdatePartnerProfile)
 				partner.GET("/profile/me", handlers.GetMyPartnerProfile)
 				partner.PATCH("/status", handlers.TogglePartnerStatus)
+				partner.POST("/location", handlers.PingLocation)
+
+				// Keahlian & Sertifikat
+				partner.GET("/competencies", handlers.GetMyCompetencies)
+				partner.POST("/competencies", handlers.DeclareCompetency)
+				partner.DELETE("/competencies/:id", handlers.DeleteMyCompetency)
+
+				// Dokumen Legalitas (STR, SIP, KTP, Sertifikat)
+				partner.POST("/documents", handlers.UploadPartnerDocument)
+				partner.GET("/documents", handlers.GetMyDocuments)
+				partner.GET("/documents/:id/file", handlers.DownloadMyDocument)
+
+				// Verifikasi Akun
+				partner.GET("/verification", handlers.GetMyVerification)
+				partner.POST("/verification/submit", handlers.SubmitVerification)
+
+				// Performa & Tier
+				partner.GET("/metrics", handlers.GetMyMetrics)
+
 				partner.GET("/orders/my-jobs", handlers.GetMyJobs)
 				// 1. Liat Job
 				partner.GET("/orders/available", handlers.GetAvailableOrders)
 
 				// 2. Ambil Job
 				partner.POST("/orders/:id/accept", handlers.AcceptOrder)
-				partner.POST("/orders/:id/start", handlers.StartOrder) // <--- ROUTE BARU
+				partner.POST("/orders/:id/depart", handlers.DepartOrder)
+				partner.POST("/orders/:id/start", handlers.StartOrder) // Check-In (wajib dalam radius geofence)
+				partner.POST("/orders/:id/checkout", handlers.CheckOutOrder)
 				partner.POST("/orders/:id/reject", handlers.RejectOrder)
 
 				// 3. Lapor Kerja (Jurnal)
@@ -63,6 +102,14 @@ func SetupRoutes(r *gin.Engine) {
 				// MODUL KEUANGAN
 				partner.GET("/wallet", handlers.GetMyWallet)
 				partner.POST("/wallet/withdraw", handlers.RequestWithdrawal)
+
+				// Rekening Pencairan
+				partner.GET("/banks", handlers.GetSupportedBanks)
+				partner.GET("/bank-accounts", handlers.GetMyBankAccounts)
+				partner.POST("/bank-accounts", handlers.RegisterBankAccount)
+				partner.DELETE("/bank-accounts/:id", handlers.DeleteMyBankAccount)
+				partner.GET("/payout-settings", handlers.GetMyPayoutSetting)
+				partner.PUT("/payout-settings", handlers.UpdateMyPayoutSetting)
 			}
 
 			// Group ADMIN
@@ -79,6 +126,8 @@ func SetupRoutes(r *gin.Engine) {
 
 				// Manajemen Order (Ops)
 				admin.GET("/orders", middleware.AdminOnly(), handlers.GetAllOrders)
+				admin.POST("/orders/:id/geofence-override", middleware.AdminOnly(), handlers.OverrideGeofence)
+				admin.GET("/visits", middleware.FinanceOnly(), handlers.GetVisits) // Kehadiran (Payroll)
 
 				// Manajemen Service (Master Data)
 				admin.POST("/services", middleware.AdminOnly(), handlers.CreateService)
@@ -88,10 +137,89 @@ func SetupRoutes(r *gin.Engine) {
 				// Modul Mitra (Ops)
 				admin.GET("/partners/pending", middleware.AdminOnly(), handlers.GetPendingPartners)
 				admin.POST("/partners/:id/verify", middleware.AdminOnly(), handlers.VerifyPartner)
+				admin.GET("/partners/:id/verification-history", middleware.AdminOnly(), handlers.GetPartnerVerificationHistory)
+				admin.GET("/partners/:id/metrics", middleware.AdminOnly(), handlers.GetPartnerMetrics)
+				admin.POST("/partner-metrics/refresh", middleware.AdminOnly(), handlers.RefreshPartnerMetrics)
+
+				// Modul Kompetensi Mitra
+				admin.POST("/competencies", middleware.AdminOnly(), handlers.CreateCompetency)
+				admin.GET("/partners/:id/competencies", middleware.AdminOnly(), handlers.GetPartnerCompetencies)
+				admin.POST("/partner-competencies/:id/verify", middleware.AdminOnly(), handlers.VerifyPartnerCompetency)
+
+				// Modul Dokumen Mitra
+				admin.GET("/partners/:id/documents", middleware.AdminOnly(), handlers.GetPartnerDocuments)
+				admin.GET("/documents/:id/file", middleware.AdminOnly(), handlers.DownloadPartnerDocument)
+				admin.POST("/documents/:id/review", middleware.AdminOnly(), handlers.ReviewPartnerDocument)
 
 				// Modul Keuangan (Finance)
 				admin.GET("/withdrawals", middleware.FinanceOnly(), handlers.GetAllWithdrawals)
 				admin.POST("/withdrawals/:id/process", middleware.FinanceOnly(), handlers.ApproveWithdrawal)
+				admin.POST("/withdrawals/:id/settle", middleware.FinanceOnly(), handlers.SettleWithdrawal)
+				admin.GET("/bank-accounts", middleware.FinanceOnly(), handlers.GetBankAccounts)
+				admin.POST("/bank-accounts/:id/verify", middleware.FinanceOnly(), handlers.VerifyBankAccount)
+
+				// Audit Webhook Pembayaran
+				admin.GET("/payment-events", middleware.FinanceOnly(), handlers.GetPaymentEvents)
+				admin.GET("/payment-events/:id", middleware.FinanceOnly(), handlers.GetPaymentEventDetail)
+				admin.POST("/payment-events/:id/replay", middleware.FinanceOnly(), handlers.ReplayPaymentEvent)
+
+				// Rekonsiliasi Pembayaran Harian (order vs gateway)
+				admin.GET("/payment-reconciliations", middleware.FinanceOnly(), handlers.GetPaymentReconciliations)
+				admin.POST("/payment-reconciliations", middleware.FinanceOnly(), handlers.RunPaymentReconciliation)
+				admin.GET("/payment-reconciliations/:id", middleware.FinanceOnly(), handlers.GetPaymentReconciliationDetail)
+				admin.POST("/payment-reconciliation-items/:id/resolve", middleware.FinanceOnly(), handlers.ResolvePaymentReconciliationItem)
+
+				// Invoice
+				admin.GET("/invoices", middleware.FinanceOnly(), handlers.GetInvoices)
+				admin.GET("/invoices/:id/pdf", middleware.FinanceOnly(), handlers.DownloadInvoice)
+				admin.POST("/orders/:id/invoice", middleware.FinanceOnly(), handlers.IssueOrderInvoice)
+
+				// Saldo Customer
+				admin.POST("/orders/:id/refund-to-wallet", middleware.FinanceOnly(), handlers.RefundOrderToWallet)
+				admin.POST("/customers/:id/wallet/promo", middleware.FinanceOnly(), handlers.GrantCustomerPromo)
+
+				// Organisasi Penjamin (perusahaan / asuransi, ditagih bulanan)
+				admin.GET("/payer-organizations", middleware.FinanceOnly(), handlers.GetPayerOrganizations)
+				admin.POST("/payer-organizations", middleware.FinanceOnly(), handlers.CreatePayerOrganization)
+				admin.GET("/payer-organizations/:id", middleware.FinanceOnly(), handlers.GetPayerOrganizationDetail)
+				admin.PUT("/payer-organizations/:id", middleware.FinanceOnly(), handlers.UpdatePayerOrganization)
+				admin.POST("/payer-organizations/:id/members", middleware.FinanceOnly(), handlers.AddPayerMember)
+				admin.DELETE("/payer-organizations/:id/members/:memberId", middleware.FinanceOnly(), handlers.RemovePayerMember)
+				admin.POST("/payer-organizations/:id/coverages", middleware.FinanceOnly(), handlers.AddPayerCoverage)
+				admin.DELETE("/payer-organizations/:id/coverages/:coverageId", middleware.FinanceOnly(), handlers.DeletePayerCoverage)
+				admin.GET("/org-invoices", middleware.FinanceOnly(), handlers.GetOrgInvoices)
+				admin.POST("/org-invoices", middleware.FinanceOnly(), handlers.GenerateOrgInvoices)
+				admin.GET("/org-invoices/:id", middleware.FinanceOnly(), handlers.GetOrgInvoiceDetail)
+				admin.GET("/org-invoices/:id/pdf", middleware.FinanceOnly(), handlers.DownloadOrgInvoice)
+				admin.POST("/org-invoices/:id/payments", middleware.FinanceOnly(), handlers.RecordOrgPayment)
+				admin.GET("/receivables/aging", middleware.FinanceOnly(), handlers.GetReceivableAging)
+
+				// Komplain Order (Sengketa)
+				admin.GET("/disputes", middleware.FinanceOnly(), handlers.GetDisputes)
+				admin.POST("/disputes/:id/resolve", middleware.FinanceOnly(), handlers.ResolveDispute)
+
+				// Batch Pencairan (Payout Run)
+				admin.GET("/payout-runs", middleware.FinanceOnly(), handlers.GetPayoutRuns)
+				admin.POST("/payout-runs", middleware.FinanceOnly(), handlers.CreatePayoutRun)
+				admin.GET("/payout-runs/:id", middleware.FinanceOnly(), handlers.GetPayoutRunDetail)
+				admin.DELETE("/payout-runs/:id/items/:trxId", middleware.FinanceOnly(), handlers.RemovePayoutRunItem)
+				admin.POST("/payout-runs/:id/cancel", middleware.FinanceOnly(), handlers.CancelPayoutRun)
+				admin.POST("/payout-runs/:id/approve", middleware.FinanceOnly(), handlers.ApprovePayoutRun)
+				admin.GET("/payout-runs/:id/bank-file", middleware.FinanceOnly(), handlers.DownloadPayoutRunBankFile)
+				admin.POST("/payout-runs/:id/complete", middleware.FinanceOnly(), handlers.CompletePayoutRun)
+
+				// Aturan Bagi Hasil (Komisi Mitra)
+				admin.GET("/commission-rules", middleware.FinanceOnly(), handlers.GetCommissionRules)
+				admin.POST("/commission-rules", middleware.FinanceOnly(), handlers.CreateCommissionRule)
+				admin.PUT("/commission-rules/:id", middleware.FinanceOnly(), handlers.UpdateCommissionRule)
+				admin.DELETE("/commission-rules/:id", middleware.FinanceOnly(), handlers.DeactivateCommissionRule)
+				admin.POST("/commission-rules/preview", middleware.FinanceOnly(), handlers.PreviewCommission)
+
+				// Ledger (Buku Besar)
+				admin.GET("/ledger/trial-balance", middleware.FinanceOnly(), handlers.GetTrialBalance)
+				admin.GET("/ledger/reconciliation", middleware.FinanceOnly(), handlers.GetLedgerReconciliation)
+				admin.GET("/ledger/transactions", middleware.FinanceOnly(), handlers.GetLedgerTransactions)
+				admin.POST("/ledger/opening-balances", middleware.FinanceOnly(), handlers.BackfillLedgerOpeningBalances)
 			}
 		}
 
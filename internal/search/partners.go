@@ -0,0 +1,243 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rumus Haversine di MySQL (hasil dalam KM). LEAST(1, ...) mencegah acos(1.0000000002) = NULL
+// akibat pembulatan float saat koordinat Mitra persis sama dengan titik pencarian.
+const distanceSQL = "(6371 * acos(LEAST(1, cos(radians(?)) * cos(radians(partner_profiles.current_lat)) * cos(radians(partner_profiles.current_lng) - radians(?)) + sin(radians(?)) * sin(radians(partner_profiles.current_lat)))))"
+
+// Pilihan urutan hasil
+const (
+	SortDistance   = "distance"   // Terdekat dulu (default)
+	SortRating     = "rating"     // Rating tertinggi dulu
+	SortExperience = "experience" // Pengalaman terlama dulu
+)
+
+var ErrInvalidCursor = errors.New("cursor tidak valid")
+
+// PartnerQuery adalah parameter pencarian Mitra terdekat
+type PartnerQuery struct {
+	Lat      float64
+	Lng      float64
+	RadiusKM float64
+
+	// Filter opsional (zero value = tidak difilter)
+	Service       *models.Service // Hanya Mitra yang memenuhi syarat layanan ini
+	Gender        string          // L / P
+	MinRating     float64
+	MinExperience int
+	Languages     []string // Mitra harus menguasai SEMUA bahasa ini
+	PriceTier     string
+
+	// Hanya Mitra yang tidak punya order aktif bentrok di rentang waktu ini
+	AvailableFrom *time.Time
+	AvailableTo   *time.Time
+
+	Sort   string
+	Cursor string // Dari next_cursor halaman sebelumnya
+	Limit  int
+}
+
+// cursor menyimpan posisi baris terakhir halaman sebelumnya (nilai kolom sort + id)
+type cursor struct {
+	Value float64 `json:"v"`
+	ID    uint64  `json:"id"`
+}
+
+// NearbyPartners mencari Mitra aktif dalam radius sesuai filter & urutan.
+// Return next cursor (kosong kalau sudah halaman terakhir).
+//
+// Strategi: prefilter pakai bounding box (BETWEEN di kolom lat/lng yang ter-index idx_partner_geo),
+// baru hitung Haversine untuk kandidat di dalam kotak. Jadi biaya acos/cos/sin tidak lagi
+// dibayar untuk SEMUA baris partner_profiles.
+func NearbyPartners(db *gorm.DB, q PartnerQuery) ([]models.PartnerProfile, string, error) {
+	query := db.
+		Table("partner_profiles").
+		Select("partner_profiles.*, "+distanceSQL+" AS distance", q.Lat, q.Lng, q.Lat).
+		Joins("JOIN users ON users.id = partner_profiles.user_id AND users.deleted_at IS NULL").
+		Preload("User").
+		Where("partner_profiles.is_active = ?", true)
+
+	minLat, maxLat, minLng, maxLng := utils.BoundingBox(q.Lat, q.Lng, q.RadiusKM)
+	query = query.Where("partner_profiles.current_lat BETWEEN ? AND ?", minLat, maxLat)
+	switch ranges := lngRanges(minLng, maxLng); len(ranges) {
+	case 1:
+		query = query.Where("partner_profiles.current_lng BETWEEN ? AND ?", ranges[0][0], ranges[0][1])
+	case 2: // Kotak melewati antimeridian: dua potong rentang bujur
+		query = query.Where("(partner_profiles.current_lng BETWEEN ? AND ? OR partner_profiles.current_lng BETWEEN ? AND ?)",
+			ranges[0][0], ranges[0][1], ranges[1][0], ranges[1][1])
+	}
+
+	// 1. Filter
+	if q.Service != nil {
+		query = query.Scopes(EligibleForService(*q.Service))
+	}
+	if q.Gender != "" {
+		query = query.Where("partner_profiles.gender = ?", q.Gender)
+	}
+	if q.MinRating > 0 {
+		query = query.Where("partner_profiles.rating_avg >= ?", q.MinRating)
+	}
+	if q.MinExperience > 0 {
+		query = query.Where("partner_profiles.experience_years >= ?", q.MinExperience)
+	}
+	for _, lang := range q.Languages {
+		query = query.Where("FIND_IN_SET(?, partner_profiles.languages) > 0", lang)
+	}
+	if q.PriceTier != "" {
+		query = query.Where("partner_profiles.price_tier = ?", q.PriceTier)
+	}
+	if q.AvailableFrom != nil && q.AvailableTo != nil {
+		// Rumus Overlap sama dengan AcceptOrder: (StartA < EndB) AND (EndA > StartB)
+		query = query.Where(`NOT EXISTS (
+			SELECT 1 FROM orders o
+			WHERE o.partner_id = partner_profiles.id
+			AND o.status IN ('ASSIGNED', 'EN_ROUTE', 'ON_DUTY')
+			AND o.schedule_start < ? AND o.schedule_end > ?
+		)`, *q.AvailableTo, *q.AvailableFrom)
+	}
+
+	// 2. Urutan + Cursor (keyset pagination: lanjut dari baris terakhir, bukan OFFSET)
+	var after *cursor
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = c
+	}
+
+	query = query.Having("distance <= ?", q.RadiusKM)
+
+	switch q.Sort {
+	case SortRating:
+		if after != nil {
+			query = query.Where("(partner_profiles.rating_avg < ? OR (partner_profiles.rating_avg = ? AND partner_profiles.id > ?))", after.Value, after.Value, after.ID)
+		}
+		query = query.Order("partner_profiles.rating_avg DESC, partner_profiles.id ASC")
+	case SortExperience:
+		if after != nil {
+			query = query.Where("(partner_profiles.experience_years < ? OR (partner_profiles.experience_years = ? AND partner_profiles.id > ?))", after.Value, after.Value, after.ID)
+		}
+		query = query.Order("partner_profiles.experience_years DESC, partner_profiles.id ASC")
+	default:
+		if after != nil {
+			query = query.Having("(distance > ? OR (distance = ? AND partner_profiles.id > ?))", after.Value, after.Value, after.ID)
+		}
+		query = query.Order("distance ASC, partner_profiles.id ASC")
+	}
+
+	// Ambil 1 baris lebih untuk tahu masih ada halaman berikutnya atau tidak
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit + 1)
+	}
+
+	var partners []models.PartnerProfile
+	if err := query.Find(&partners).Error; err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if q.Limit > 0 && len(partners) > q.Limit {
+		partners = partners[:q.Limit]
+		nextCursor = encodeCursor(q.Sort, partners[len(partners)-1])
+	}
+
+	return partners, nextCursor, nil
+}
+
+// lngRanges membungkus rentang bujur bounding box ke -180..180.
+// Return 1 rentang (normal), 2 rentang (melewati antimeridian), atau nil (seluruh bujur, tidak perlu difilter).
+func lngRanges(minLng, maxLng float64) [][2]float64 {
+	switch {
+	case maxLng-minLng >= 360:
+		return nil
+	case minLng < -180:
+		return [][2]float64{{minLng + 360, 180}, {-180, maxLng}}
+	case maxLng > 180:
+		return [][2]float64{{minLng, 180}, {-180, maxLng - 360}}
+	default:
+		return [][2]float64{{minLng, maxLng}}
+	}
+}
+
+// EligibleForService adalah scope query partner_profiles yang hanya
+// meloloskan Mitra yang memenuhi syarat layanan (izin praktik berlaku + pengalaman + kompetensi VERIFIED)
+func EligibleForService(service models.Service) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Scopes(LicenseValid(time.Now())).
+			Where("partner_profiles.experience_years >= ?", service.MinExperienceYears).
+			// Tidak boleh ada kompetensi wajib yang belum dimiliki (VERIFIED) oleh Mitra
+			Where(`NOT EXISTS (
+				SELECT 1 FROM service_competencies sc
+				WHERE sc.service_id = ?
+				AND NOT EXISTS (
+					SELECT 1 FROM partner_competencies pc
+					WHERE pc.partner_id = partner_profiles.id
+					AND pc.competency_id = sc.competency_id
+					AND pc.status = 'VERIFIED'
+				)
+			)`, service.ID)
+	}
+}
+
+// LicenseValid adalah scope query partner_profiles yang hanya meloloskan Mitra dengan izin praktik berlaku:
+// STR belum lewat masa berlaku & tidak ada STR/SIP EXPIRED yang belum diganti dokumen baru yang APPROVED.
+func LicenseValid(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("(partner_profiles.str_expires_at IS NULL OR partner_profiles.str_expires_at > ?)", now).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM partner_documents expired
+				WHERE expired.partner_id = partner_profiles.id
+				AND expired.type IN ('STR', 'SIP')
+				AND expired.status = 'EXPIRED'
+				AND NOT EXISTS (
+					SELECT 1 FROM partner_documents renewed
+					WHERE renewed.partner_id = expired.partner_id
+					AND renewed.type = expired.type
+					AND renewed.status = 'APPROVED'
+					AND (renewed.expires_at IS NULL OR renewed.expires_at > ?)
+				)
+			)`, now)
+	}
+}
+
+func encodeCursor(sort string, last models.PartnerProfile) string {
+	c := cursor{ID: last.ID}
+	switch sort {
+	case SortRating:
+		c.Value = last.RatingAvg
+	case SortExperience:
+		c.Value = float64(last.ExperienceYears)
+	default:
+		if last.Distance != nil {
+			c.Value = *last.Distance
+		}
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
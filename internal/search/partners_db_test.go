@@ -0,0 +1,219 @@
+package search
+
+import (
+	"fmt"
+	"homecare-backend/internal/models"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Test & benchmark di file ini butuh MySQL sungguhan (index & EXPLAIN tidak bisa dipalsukan).
+// Pakai database kosong khusus test; 50rb Mitra dummy dibuat sekali lalu dipakai ulang:
+//
+//	TEST_DATABASE_DSN="root:secret@tcp(127.0.0.1:3307)/homecare_test?parseTime=True&loc=Local" go test -run DB -bench DB ./internal/search/
+const (
+	benchPartners    = 50000
+	benchEmailDomain = "@search-bench.invalid"
+	benchSpreadKM    = 60
+	benchRadiusKM    = 15
+)
+
+// Titik tengah Jakarta (Monas)
+const benchLat, benchLng = -6.1754, 106.8272
+
+func testDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_DSN belum diisi, test database dilewati")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		tb.Fatalf("gagal koneksi ke database test: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.PartnerProfile{}); err != nil {
+		tb.Fatalf("gagal migrasi: %v", err)
+	}
+	seedPartners(tb, db)
+	return db
+}
+
+// seedPartners mengisi Mitra dummy sampai benchPartners, tersebar acak ±benchSpreadKM dari Monas
+func seedPartners(tb testing.TB, db *gorm.DB) {
+	tb.Helper()
+	var existing int64
+	db.Model(&models.User{}).Where("email LIKE ?", "%"+benchEmailDomain).Count(&existing)
+
+	rng := rand.New(rand.NewSource(existing + 1))
+	const batch = 1000
+	for offset := int(existing); offset < benchPartners; offset += batch {
+		size := batch
+		if offset+size > benchPartners {
+			size = benchPartners - offset
+		}
+
+		users := make([]models.User, size)
+		for i := range users {
+			id := offset + i
+			users[i] = models.User{
+				RoleID:       3,
+				FullName:     fmt.Sprintf("Mitra Dummy %d", id),
+				Email:        fmt.Sprintf("mitra%d%s", id, benchEmailDomain),
+				PasswordHash: "-",
+				Phone:        fmt.Sprintf("0899%08d", id),
+				IsVerified:   true,
+			}
+		}
+		if err := db.Create(&users).Error; err != nil {
+			tb.Fatalf("gagal seed users: %v", err)
+		}
+
+		profiles := make([]models.PartnerProfile, size)
+		for i, u := range users {
+			profiles[i] = models.PartnerProfile{
+				UserID:             u.ID,
+				ExperienceYears:    rng.Intn(15),
+				CurrentLat:         benchLat + (rng.Float64()*2-1)*benchSpreadKM/111.045,
+				CurrentLng:         benchLng + (rng.Float64()*2-1)*benchSpreadKM/111.045,
+				IsActive:           rng.Intn(10) < 8, // 80% online
+				VerificationStatus: models.PartnerApproved,
+			}
+		}
+		if err := db.Create(&profiles).Error; err != nil {
+			tb.Fatalf("gagal seed partner_profiles: %v", err)
+		}
+	}
+}
+
+// fullScan versi lama NearbyPartners: Haversine dihitung untuk SEMUA Mitra aktif, tanpa bounding box
+func fullScan(db *gorm.DB, q PartnerQuery) ([]models.PartnerProfile, error) {
+	query := db.
+		Table("partner_profiles").
+		Select("partner_profiles.*, "+distanceSQL+" AS distance", q.Lat, q.Lng, q.Lat).
+		Joins("JOIN users ON users.id = partner_profiles.user_id AND users.deleted_at IS NULL").
+		Preload("User").
+		Where("partner_profiles.is_active = ?", true).
+		Having("distance <= ?", q.RadiusKM).
+		Order("distance ASC, partner_profiles.id ASC")
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit + 1)
+	}
+
+	var partners []models.PartnerProfile
+	err := query.Find(&partners).Error
+	return partners, err
+}
+
+// benchPoints titik pencarian acak di sekitar Monas (seed tetap biar antar run sebanding)
+func benchPoints(n int) [][2]float64 {
+	rng := rand.New(rand.NewSource(42))
+	points := make([][2]float64, n)
+	for i := range points {
+		points[i] = [2]float64{benchLat + (rng.Float64()*2-1)*0.3, benchLng + (rng.Float64()*2-1)*0.3}
+	}
+	return points
+}
+
+func ids(partners []models.PartnerProfile) []uint64 {
+	out := make([]uint64, len(partners))
+	for i, p := range partners {
+		out[i] = p.ID
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func TestNearbyPartnersMatchesFullScanDB(t *testing.T) {
+	db := testDB(t)
+
+	for _, p := range benchPoints(5) {
+		q := PartnerQuery{Lat: p[0], Lng: p[1], RadiusKM: benchRadiusKM}
+		got, _, err := NearbyPartners(db, q)
+		if err != nil {
+			t.Fatalf("NearbyPartners: %v", err)
+		}
+		want, err := fullScan(db, q)
+		if err != nil {
+			t.Fatalf("fullScan: %v", err)
+		}
+		if fmt.Sprint(ids(got)) != fmt.Sprint(ids(want)) {
+			t.Errorf("(%f, %f): bounding box dapat %d Mitra, full scan %d", p[0], p[1], len(got), len(want))
+		}
+	}
+}
+
+func TestNearbyPartnersUsesGeoIndexDB(t *testing.T) {
+	db := testDB(t)
+
+	// Tangkap SQL query utama (bukan Preload users) yang dibuat NearbyPartners
+	var sql string
+	var vars []interface{}
+	err := db.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		if sql == "" && tx.Statement.Table == "partner_profiles" {
+			sql = tx.Statement.SQL.String()
+			vars = append([]interface{}{}, tx.Statement.Vars...)
+		}
+	})
+	if err != nil {
+		t.Fatalf("gagal daftar callback: %v", err)
+	}
+
+	if _, _, err := NearbyPartners(db, PartnerQuery{Lat: benchLat, Lng: benchLng, RadiusKM: benchRadiusKM, Limit: 20}); err != nil {
+		t.Fatalf("NearbyPartners: %v", err)
+	}
+	if sql == "" {
+		t.Fatal("query partner_profiles tidak tertangkap")
+	}
+
+	var plan []map[string]interface{}
+	if err := db.Raw("EXPLAIN " + db.Dialector.Explain(sql, vars...)).Scan(&plan).Error; err != nil {
+		t.Fatalf("EXPLAIN gagal: %v", err)
+	}
+	for _, row := range plan {
+		key := row["key"]
+		if b, ok := key.([]byte); ok {
+			key = string(b)
+		}
+		if key == "idx_partner_geo" {
+			return
+		}
+	}
+	t.Errorf("idx_partner_geo tidak dipakai, rencana query: %v", plan)
+}
+
+func benchmarkSearchDB(b *testing.B, search func(db *gorm.DB, q PartnerQuery) error) {
+	db := testDB(b)
+	points := benchPoints(64)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := points[i%len(points)]
+		if err := search(db, PartnerQuery{Lat: p[0], Lng: p[1], RadiusKM: benchRadiusKM, Limit: 20}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNearbyPartnersDB(b *testing.B) {
+	benchmarkSearchDB(b, func(db *gorm.DB, q PartnerQuery) error {
+		_, _, err := NearbyPartners(db, q)
+		return err
+	})
+}
+
+func BenchmarkFullScanDB(b *testing.B) {
+	benchmarkSearchDB(b, func(db *gorm.DB, q PartnerQuery) error {
+		_, err := fullScan(db, q)
+		return err
+	})
+}
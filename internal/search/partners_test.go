@@ -0,0 +1,201 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/utils"
+	"math"
+	"testing"
+)
+
+// destination titik sejauh distKM dari (lat, lng) ke arah bearing (derajat), rumus great-circle
+func destination(lat, lng, bearing, distKM float64) (float64, float64) {
+	rad := math.Pi / 180
+	d := distKM / utils.EarthRadiusKM
+	lat1, lng1, brng := lat*rad, lng*rad, bearing*rad
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brng))
+	lng2Rad := lng1 + math.Atan2(math.Sin(brng)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+
+	lng2 := math.Mod(lng2Rad/rad+540, 360) - 180 // Normalisasi ke -180..180
+	return lat2 / rad, lng2
+}
+
+// inBox meniru filter SQL NearbyPartners (BETWEEN lat + rentang bujur hasil lngRanges)
+func inBox(lat, lng, minLat, maxLat, minLng, maxLng float64) bool {
+	if lat < minLat || lat > maxLat {
+		return false
+	}
+	ranges := lngRanges(minLng, maxLng)
+	if ranges == nil {
+		return true
+	}
+	for _, r := range ranges {
+		if lng >= r[0] && lng <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBoundingBoxContainsCircle(t *testing.T) {
+	centers := []struct {
+		name     string
+		lat, lng float64
+	}{
+		{"Jakarta", -6.1754, 106.8272},
+		{"Ekuator", 0, 0},
+		{"Lintang tinggi", 70, 25},
+		{"Dekat kutub utara", 89.9, 10},
+		{"Dekat kutub selatan", -89.95, -45},
+		{"Antimeridian timur", -17.7, 179.95},
+		{"Antimeridian barat", 51.9, -179.98},
+	}
+	radii := []float64{0.5, 15, 100, 1000}
+
+	for _, c := range centers {
+		for _, r := range radii {
+			t.Run(fmt.Sprintf("%s/%gkm", c.name, r), func(t *testing.T) {
+				minLat, maxLat, minLng, maxLng := utils.BoundingBox(c.lat, c.lng, r)
+				if minLat < -90 || maxLat > 90 || minLat > maxLat || minLng > maxLng {
+					t.Fatalf("kotak tidak valid: lat %.4f..%.4f lng %.4f..%.4f", minLat, maxLat, minLng, maxLng)
+				}
+
+				// Semua titik di pinggir lingkaran (jarak hampir = radius) harus lolos prefilter
+				for bearing := 0.0; bearing < 360; bearing += 5 {
+					lat, lng := destination(c.lat, c.lng, bearing, r*0.999)
+					if !inBox(lat, lng, minLat, maxLat, minLng, maxLng) {
+						t.Errorf("titik (%.5f, %.5f) arah %g° terbuang dari kotak lat %.5f..%.5f lng %.5f..%.5f",
+							lat, lng, bearing, minLat, maxLat, minLng, maxLng)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBoundingBoxExcludesFarPoints(t *testing.T) {
+	// Kotak harus benar-benar memotong: titik 2x radius di arah utara/timur tidak boleh lolos
+	minLat, maxLat, minLng, maxLng := utils.BoundingBox(-6.1754, 106.8272, 15)
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		lat, lng := destination(-6.1754, 106.8272, bearing, 30)
+		if inBox(lat, lng, minLat, maxLat, minLng, maxLng) {
+			t.Errorf("titik 30 KM arah %g° masih masuk kotak radius 15 KM", bearing)
+		}
+	}
+}
+
+func TestLngRanges(t *testing.T) {
+	cases := []struct {
+		minLng, maxLng float64
+		want           [][2]float64
+	}{
+		{100, 110, [][2]float64{{100, 110}}},
+		{179.5, 180.5, [][2]float64{{179.5, 180}, {-180, -179.5}}},
+		{-180.5, -179.5, [][2]float64{{179.5, 180}, {-180, -179.5}}},
+		{-170, 190, nil},
+	}
+	for _, tc := range cases {
+		got := lngRanges(tc.minLng, tc.maxLng)
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("lngRanges(%g, %g) = %v, mau %v", tc.minLng, tc.maxLng, got, tc.want)
+		}
+	}
+}
+
+func TestHaversineKM(t *testing.T) {
+	oneDegree := utils.EarthRadiusKM * math.Pi / 180 // ≈ 111.195 KM
+	cases := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"Titik sama", -6.1754, 106.8272, -6.1754, 106.8272, 0},
+		{"1 derajat bujur di ekuator", 0, 0, 0, 1, oneDegree},
+		{"1 derajat lintang", 10, 50, 11, 50, oneDegree},
+		{"Melewati antimeridian", 0, 179.5, 0, -179.5, oneDegree},
+		{"Kutub ke kutub", 90, 0, -90, 0, utils.EarthRadiusKM * math.Pi},
+		{"Antipoda", 0, 0, 0, 180, utils.EarthRadiusKM * math.Pi},
+	}
+	for _, tc := range cases {
+		got := utils.HaversineKM(tc.lat1, tc.lng1, tc.lat2, tc.lng2)
+		if math.Abs(got-tc.want) > 0.001 {
+			t.Errorf("%s: %.4f KM, mau %.4f KM", tc.name, got, tc.want)
+		}
+		if back := utils.HaversineKM(tc.lat2, tc.lng2, tc.lat1, tc.lng1); math.Abs(back-got) > 1e-9 {
+			t.Errorf("%s: tidak simetris (%.6f vs %.6f)", tc.name, got, back)
+		}
+	}
+
+	// Jarak ke titik hasil destination() harus kembali ke jarak semula
+	for _, d := range []float64{0.1, 15, 500} {
+		lat, lng := destination(-6.1754, 106.8272, 37, d)
+		if got := utils.HaversineKM(-6.1754, 106.8272, lat, lng); math.Abs(got-d) > 1e-6*math.Max(d, 1) {
+			t.Errorf("jarak ke titik %g KM = %.6f", d, got)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	distance := 3.25
+	last := models.PartnerProfile{ID: 42, RatingAvg: 4.8, ExperienceYears: 7, Distance: &distance}
+
+	cases := map[string]float64{SortDistance: 3.25, SortRating: 4.8, SortExperience: 7, "": 3.25}
+	for sort, want := range cases {
+		c, err := decodeCursor(encodeCursor(sort, last))
+		if err != nil {
+			t.Fatalf("sort %q: %v", sort, err)
+		}
+		if c.ID != 42 || c.Value != want {
+			t.Errorf("sort %q: cursor = %+v, mau {Value:%g ID:42}", sort, *c, want)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsMalformed(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	malformed := map[string]string{
+		"bukan base64": "!!!bukan-base64!!!",
+		"bukan json":   b64([]byte("halo")),
+		"tanpa id":     b64([]byte(`{"v":1.5}`)),
+		"id nol":       b64([]byte(`{"v":1.5,"id":0}`)),
+		"tipe salah":   b64([]byte(`{"v":"dekat","id":1}`)),
+		"kosong":       "",
+	}
+	for name, s := range malformed {
+		if _, err := decodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, mau ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func BenchmarkBoundingBox(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		utils.BoundingBox(-6.1754, 106.8272, 15)
+	}
+}
+
+func BenchmarkBoundingBoxAntimeridian(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _, minLng, maxLng := utils.BoundingBox(-17.7, 179.95, 15)
+		lngRanges(minLng, maxLng)
+	}
+}
+
+func BenchmarkHaversineKM(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		utils.HaversineKM(-6.1754, 106.8272, -6.2297, 106.8295)
+	}
+}
+
+func BenchmarkCursorRoundTrip(b *testing.B) {
+	distance := 3.25
+	last := models.PartnerProfile{ID: 42, Distance: &distance}
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeCursor(encodeCursor(SortDistance, last)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
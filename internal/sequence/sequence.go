@@ -0,0 +1,29 @@
+// Package sequence membuat nomor urut yang aman dipakai banyak request sekaligus.
+// Nomor diambil dari tabel sequences dengan UPDATE atomik, jadi dua request di detik yang sama
+// tidak akan pernah mendapat nomor yang sama.
+package sequence
+
+import (
+	"homecare-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Next menaikkan & mengembalikan nomor urut berikutnya untuk `name` (mulai dari 1).
+// Kalau dipanggil di dalam transaksi, baris sequence terkunci sampai transaksi selesai:
+// nomor tidak bolong kalau transaksi gagal (cocok untuk nomor faktur), tapi request lain menunggu.
+func Next(db *gorm.DB, name string) (uint64, error) {
+	var seq models.Sequence
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Sequence{Name: name}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Sequence{}).Where("name = ?", name).
+			Update("value", gorm.Expr("value + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).First(&seq).Error
+	})
+	return seq.Value, err
+}
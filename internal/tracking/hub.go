@@ -0,0 +1,73 @@
+package tracking
+
+import (
+	"sync"
+	"time"
+)
+
+// Update adalah posisi terbaru Mitra yang dikirim ke customer (via SSE)
+type Update struct {
+	OrderID    uint64    `json:"order_id"`
+	Status     string    `json:"status"`
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	DistanceKM float64   `json:"distance_km"`
+	ETAMinutes int       `json:"eta_minutes"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Hub adalah pub/sub in-memory per order.
+// Cukup untuk 1 instance server; kalau nanti scale ke banyak instance, ganti ke Redis Pub/Sub.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[uint64]map[chan Update]struct{}
+}
+
+// Default dipakai oleh handler
+var Default = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[uint64]map[chan Update]struct{})}
+}
+
+// Subscribe mendaftarkan pendengar untuk satu order.
+// Jangan lupa panggil fungsi unsubscribe saat koneksi ditutup.
+func (h *Hub) Subscribe(orderID uint64) (<-chan Update, func()) {
+	ch := make(chan Update, 8)
+
+	h.mu.Lock()
+	if h.subs[orderID] == nil {
+		h.subs[orderID] = make(map[chan Update]struct{})
+	}
+	h.subs[orderID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[orderID][ch]; !ok {
+			return
+		}
+		delete(h.subs[orderID], ch)
+		if len(h.subs[orderID]) == 0 {
+			delete(h.subs, orderID)
+		}
+		close(ch)
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish mengirim update ke semua pendengar order tsb.
+// Non-blocking: kalau client lambat (buffer penuh), update dilewati saja.
+func (h *Hub) Publish(update Update) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs[update.OrderID] {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
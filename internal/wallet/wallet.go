@@ -0,0 +1,66 @@
+// Package wallet berisi operasi saldo wallet yang aman dari race condition.
+// Semua perubahan saldo WAJIB lewat sini (bukan wallet.Balance += x lalu Save),
+// karena Save menulis ulang nilai yang dibaca sebelumnya dan bisa menimpa request paralel.
+package wallet
+
+import (
+	"errors"
+	"homecare-backend/internal/models"
+	"homecare-backend/pkg/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("saldo tidak cukup")
+	ErrInvalidAmount       = errors.New("nominal harus lebih dari 0")
+)
+
+// ForUser mengambil wallet user & mengunci barisnya (SELECT ... FOR UPDATE) sampai transaksi selesai.
+// Kalau belum punya wallet, dibuatkan (aman kalau dua request membuat bersamaan, user_id unique).
+func ForUser(tx *gorm.DB, userID uint64) (models.Wallet, error) {
+	var w models.Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&w).Error
+	if err == nil {
+		return w, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return w, err
+	}
+
+	w = models.Wallet{UserID: userID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&w).Error; err != nil {
+		return w, err
+	}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&w).Error
+	return w, err
+}
+
+// Credit menambah saldo secara atomik (balance = balance + x di level DB)
+func Credit(tx *gorm.DB, walletID uint64, amount money.Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return tx.Model(&models.Wallet{}).
+		Where("id = ?", walletID).
+		Update("balance", gorm.Expr("balance + ?", amount)).Error
+}
+
+// Debit mengurangi saldo secara atomik HANYA kalau saldonya cukup.
+// Pengecekan & pengurangan terjadi di satu query, jadi dua penarikan paralel tidak bisa membuat saldo minus.
+func Debit(tx *gorm.DB, walletID uint64, amount money.Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	res := tx.Model(&models.Wallet{}).
+		Where("id = ? AND balance >= ?", walletID, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
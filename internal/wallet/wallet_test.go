@@ -0,0 +1,171 @@
+package wallet_test
+
+import (
+	"errors"
+	"fmt"
+	"homecare-backend/internal/models"
+	"homecare-backend/internal/payout"
+	"homecare-backend/internal/wallet"
+	"homecare-backend/pkg/money"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Test ini butuh MySQL sungguhan (row lock & unique index tidak bisa dipalsukan).
+// Jalankan dengan database kosong khusus test, misal:
+//
+//	TEST_DATABASE_DSN="root:secret@tcp(127.0.0.1:3307)/homecare_test?parseTime=True&loc=Local" go test ./internal/wallet/
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN belum diisi, test database dilewati")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("gagal koneksi ke database test: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Wallet{}, &models.WalletTransaction{},
+		&models.LedgerAccount{}, &models.LedgerTransaction{}, &models.LedgerEntry{}); err != nil {
+		t.Fatalf("gagal migrasi: %v", err)
+	}
+	return db
+}
+
+// seedWallet membuat wallet baru (user_id unik per test) dengan saldo awal
+func seedWallet(t *testing.T, db *gorm.DB, balance money.Money) models.Wallet {
+	t.Helper()
+	w := models.Wallet{UserID: uint64(time.Now().UnixNano()), Balance: balance}
+	if err := db.Create(&w).Error; err != nil {
+		t.Fatalf("gagal membuat wallet: %v", err)
+	}
+	return w
+}
+
+func TestConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	db := testDB(t)
+	const (
+		balance  = money.Money(100000)
+		amount   = money.Money(30000)
+		attempts = 20
+	)
+	w := seedWallet(t, db, balance)
+	account := models.BankAccount{ID: 1, BankCode: "bca", AccountNumber: "1234567890"}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := payout.Request(tx, w.ID, account, amount, "", nil)
+				return err
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				successes++
+			case !errors.Is(err, wallet.ErrInsufficientBalance):
+				t.Errorf("error tak terduga: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var final models.Wallet
+	db.First(&final, w.ID)
+	if final.Balance < 0 {
+		t.Fatalf("saldo minus: %s", final.Balance)
+	}
+	if want := int(balance / amount); successes != want {
+		t.Errorf("penarikan berhasil = %d, mau %d", successes, want)
+	}
+	if want := balance - amount*money.Money(successes); final.Balance != want {
+		t.Errorf("saldo akhir = %s, mau %s", final.Balance, want)
+	}
+
+	var rows int64
+	db.Model(&models.WalletTransaction{}).Where("wallet_id = ? AND type = ?", w.ID, "WITHDRAWAL").Count(&rows)
+	if rows != int64(successes) {
+		t.Errorf("baris WITHDRAWAL = %d, mau %d", rows, successes)
+	}
+}
+
+func TestConcurrentDebitsNeverOverdraw(t *testing.T) {
+	db := testDB(t)
+	w := seedWallet(t, db, 50000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Transaction(func(tx *gorm.DB) error {
+				return wallet.Debit(tx, w.ID, 7000)
+			})
+		}()
+	}
+	wg.Wait()
+
+	var final models.Wallet
+	db.First(&final, w.ID)
+	if final.Balance != 50000%7000 {
+		t.Errorf("saldo akhir = %s, mau %s", final.Balance, money.Money(50000%7000))
+	}
+}
+
+func TestWithdrawalIdempotencyKeyCreatesOneRow(t *testing.T) {
+	db := testDB(t)
+	w := seedWallet(t, db, 100000)
+	account := models.BankAccount{ID: 1, BankCode: "bca", AccountNumber: "1234567890"}
+	key := fmt.Sprintf("test-%d", time.Now().UnixNano())
+
+	// Request ulang dengan key yang sama (berurutan & paralel) hanya boleh tercatat sekali
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.Transaction(func(tx *gorm.DB) error {
+				_, err := payout.Request(tx, w.ID, account, 25000, key, nil)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	db.Transaction(func(tx *gorm.DB) error {
+		_, err := payout.Request(tx, w.ID, account, 25000, key, nil)
+		return err
+	})
+
+	var rows int64
+	db.Model(&models.WalletTransaction{}).Where("wallet_id = ? AND idempotency_key = ?", w.ID, key).Count(&rows)
+	if rows != 1 {
+		t.Errorf("baris WITHDRAWAL dengan key yang sama = %d, mau 1", rows)
+	}
+
+	// Transaksi yang bentrok di-rollback, jadi saldo hanya terpotong sekali
+	var final models.Wallet
+	db.First(&final, w.ID)
+	if final.Balance != 75000 {
+		t.Errorf("saldo akhir = %s, mau %s", final.Balance, money.Money(75000))
+	}
+}
@@ -0,0 +1,128 @@
+package disbursement
+
+import (
+	"context"
+	"errors"
+	"homecare-backend/pkg/money"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Provider adalah abstraksi penyedia transfer ke rekening bank (Midtrans Iris, Xendit, dll).
+// Handler cukup pakai interface ini, jadi gampang ganti provider / pakai fake di development.
+type Provider interface {
+	Name() string
+
+	// InquireAccount mengecek rekening ke bank & mengembalikan nama pemilik sebenarnya
+	InquireAccount(ctx context.Context, bankCode, accountNumber string) (AccountInfo, error)
+
+	// Disburse mengirim dana ke rekening. Biasanya hasilnya PENDING dan status final datang lewat callback.
+	Disburse(ctx context.Context, req PayoutRequest) (PayoutResult, error)
+
+	// ParseCallback memvalidasi (signature/token) & membaca callback status transfer dari provider
+	ParseCallback(r *http.Request) (PayoutResult, error)
+}
+
+// Status transfer
+const (
+	PayoutPending = "PENDING"
+	PayoutSuccess = "SUCCESS"
+	PayoutFailed  = "FAILED"
+)
+
+// PayoutRequest data transfer ke rekening Mitra
+type PayoutRequest struct {
+	ReferenceID   string // ID unik dari sisi kita (dipakai juga sebagai idempotency key di provider)
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+	Amount        money.Money
+	Description   string
+}
+
+// PayoutResult status transfer dari provider
+type PayoutResult struct {
+	ReferenceID   string `json:"reference_id"` // Bisa kosong kalau provider hanya kirim ProviderRef
+	ProviderRef   string `json:"provider_ref"`
+	Status        string `json:"status"` // PENDING, SUCCESS, FAILED
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// AccountInfo hasil inquiry rekening
+type AccountInfo struct {
+	BankCode      string `json:"bank_code"`
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+}
+
+// Default dipakai oleh handler (di-set lewat Init di main)
+var Default Provider
+
+var (
+	ErrAccountNotFound     = errors.New("rekening tidak ditemukan")
+	ErrUnsupportedBank     = errors.New("bank tidak didukung")
+	ErrInquiryNotSupported = errors.New("provider tidak mendukung cek rekening")
+	ErrInvalidCallback     = errors.New("callback tidak valid")
+
+	// ErrRejected = provider menolak permintaan transfer secara pasti (saldo bisa langsung dikembalikan).
+	// Error lain (timeout, 5xx) statusnya belum pasti, jadi transfer dibiarkan PROCESSING.
+	ErrRejected = errors.New("transfer ditolak provider")
+)
+
+// SupportedBanks kode bank yang bisa dipakai untuk pencairan (kode mengikuti Iris/Xendit)
+var SupportedBanks = map[string]string{
+	"bca":     "Bank Central Asia (BCA)",
+	"bni":     "Bank Negara Indonesia (BNI)",
+	"bri":     "Bank Rakyat Indonesia (BRI)",
+	"mandiri": "Bank Mandiri",
+	"bsi":     "Bank Syariah Indonesia (BSI)",
+	"cimb":    "CIMB Niaga",
+	"permata": "Bank Permata",
+	"danamon": "Bank Danamon",
+	"btn":     "Bank Tabungan Negara (BTN)",
+	"bjb":     "Bank BJB",
+}
+
+// Init memilih provider berdasarkan .env
+// DISBURSEMENT_PROVIDER=iris | xendit | fake (WAJIB diisi)
+//   - iris:   IRIS_CREATOR_KEY, IRIS_APPROVER_KEY (opsional, auto-approve), IRIS_MERCHANT_KEY (cek signature callback), MIDTRANS_ENV=production
+//   - xendit: XENDIT_SECRET_KEY, XENDIT_CALLBACK_TOKEN
+//   - fake:   FAKE_DISBURSEMENT_TOKEN (opsional, untuk simulasi callback)
+func Init() {
+	// Tidak ada default: provider fake langsung menganggap transfer SUKSES, jadi lupa set env di production
+	// berarti penarikan dana tercatat lunas padahal uang tidak pernah dikirim. Fake harus dipilih secara sengaja.
+	name := os.Getenv("DISBURSEMENT_PROVIDER")
+	if name == "" {
+		log.Fatal("DISBURSEMENT_PROVIDER wajib diisi (iris | xendit | fake)")
+	}
+
+	switch name {
+	case "fake":
+		Default = NewFakeProvider(os.Getenv("FAKE_DISBURSEMENT_TOKEN"))
+	case "iris":
+		Default = NewIrisProvider(os.Getenv("IRIS_CREATOR_KEY"), os.Getenv("IRIS_APPROVER_KEY"), os.Getenv("IRIS_MERCHANT_KEY"), os.Getenv("MIDTRANS_ENV") == "production")
+	case "xendit":
+		Default = NewXenditProvider(os.Getenv("XENDIT_SECRET_KEY"), os.Getenv("XENDIT_CALLBACK_TOKEN"))
+	default:
+		log.Fatalf("DISBURSEMENT_PROVIDER tidak dikenal: %s", name)
+	}
+
+	log.Println("🏦 Disbursement Ready! Provider:", name)
+}
+
+var nonLetter = regexp.MustCompile(`[^A-Z ]+`)
+
+// NormalizeName menyamakan format nama untuk dibandingkan (huruf besar, tanpa gelar/tanda baca berlebih)
+func NormalizeName(name string) string {
+	name = nonLetter.ReplaceAllString(strings.ToUpper(name), " ")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NamesMatch true kalau nama input Mitra sama dengan nama dari bank (setelah dinormalisasi)
+func NamesMatch(a, b string) bool {
+	na, nb := NormalizeName(a), NormalizeName(b)
+	return na != "" && na == nb
+}
@@ -0,0 +1,87 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// FakeProvider dipakai untuk development & testing (tidak ada uang sungguhan yang berpindah).
+//   - Rekening yang didaftarkan lewat Register mengembalikan nama tersebut.
+//   - Nomor rekening berawalan "000" dianggap tidak ada.
+//   - Selain itu, nama pemilik = "PENERIMA UJI".
+//   - Transfer ke rekening berawalan "999" langsung FAILED, "888" tetap PENDING
+//     (status final dikirim manual lewat callback), selain itu langsung SUCCESS.
+type FakeProvider struct {
+	mu            sync.RWMutex
+	accounts      map[string]string
+	callbackToken string // Header X-Fake-Token untuk simulasi callback (kosong = callback ditolak)
+}
+
+func NewFakeProvider(callbackToken string) *FakeProvider {
+	return &FakeProvider{accounts: map[string]string{}, callbackToken: callbackToken}
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Register menambah rekening palsu dengan nama pemilik tertentu
+func (f *FakeProvider) Register(bankCode, accountNumber, accountName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[bankCode+":"+accountNumber] = accountName
+}
+
+func (f *FakeProvider) InquireAccount(_ context.Context, bankCode, accountNumber string) (AccountInfo, error) {
+	if _, ok := SupportedBanks[bankCode]; !ok {
+		return AccountInfo{}, ErrUnsupportedBank
+	}
+	if strings.HasPrefix(accountNumber, "000") {
+		return AccountInfo{}, ErrAccountNotFound
+	}
+
+	f.mu.RLock()
+	name, ok := f.accounts[bankCode+":"+accountNumber]
+	f.mu.RUnlock()
+	if !ok {
+		name = "PENERIMA UJI"
+	}
+
+	return AccountInfo{BankCode: bankCode, AccountNumber: accountNumber, AccountName: name}, nil
+}
+
+func (f *FakeProvider) Disburse(_ context.Context, req PayoutRequest) (PayoutResult, error) {
+	result := PayoutResult{ReferenceID: req.ReferenceID, ProviderRef: "FAKE-" + req.ReferenceID, Status: PayoutSuccess}
+	switch {
+	case strings.HasPrefix(req.AccountNumber, "999"):
+		result.Status = PayoutFailed
+		result.FailureReason = "REKENING_TIDAK_AKTIF"
+	case strings.HasPrefix(req.AccountNumber, "888"):
+		result.Status = PayoutPending
+	}
+	return result, nil
+}
+
+// ParseCallback body: {"reference_id": "WD-1", "status": "SUCCESS|FAILED", "failure_reason": "..."}
+func (f *FakeProvider) ParseCallback(r *http.Request) (PayoutResult, error) {
+	if f.callbackToken == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Fake-Token")), []byte(f.callbackToken)) != 1 {
+		return PayoutResult{}, ErrInvalidCallback
+	}
+
+	raw, err := readBody(r)
+	if err != nil {
+		return PayoutResult{}, err
+	}
+	var result PayoutResult
+	if err := json.Unmarshal(raw, &result); err != nil || result.ReferenceID == "" {
+		return PayoutResult{}, ErrInvalidCallback
+	}
+	if result.Status != PayoutSuccess && result.Status != PayoutFailed {
+		result.Status = PayoutPending
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package disbursement
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// apiError error HTTP dari provider. Hanya 4xx jenis validasi yang dianggap penolakan pasti (ErrRejected).
+// 408/409/429 dll belum pasti: 409 bisa berarti transfer dengan referensi sama sudah ada di provider,
+// jadi saldo tidak boleh langsung dikembalikan (bisa dibayar dua kali).
+// Begitu juga 400 "duplicate" (Xendit: DUPLICATE_TRANSACTION_ERROR) saat penarikan dikirim ulang dengan idempotency key sama.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body)
+}
+
+func (e *apiError) Unwrap() error {
+	switch e.Status {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity:
+		if strings.Contains(strings.ToUpper(e.Body), "DUPLICATE") {
+			return nil
+		}
+		return ErrRejected
+	}
+	return nil
+}
+
+// doJSON kirim request JSON dengan Basic Auth & decode response ke out
+func doJSON(ctx context.Context, method, url, username string, headers map[string]string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, "")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &apiError{Status: resp.StatusCode, Body: string(raw)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// readBody membaca body callback (maks 1MB)
+func readBody(r *http.Request) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r.Body, 1<<20))
+}
@@ -0,0 +1,27 @@
+package disbursement
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIErrorRejected(t *testing.T) {
+	cases := map[int]bool{
+		400: true, 403: true, 404: true, 422: true,
+		401: false, 408: false, 409: false, 429: false, 500: false, 502: false, 503: false,
+	}
+	for status, rejected := range cases {
+		err := error(&apiError{Status: status})
+		if got := errors.Is(err, ErrRejected); got != rejected {
+			t.Errorf("HTTP %d: errors.Is(ErrRejected) = %v, mau %v", status, got, rejected)
+		}
+	}
+}
+
+func TestAPIErrorDuplicateNotRejected(t *testing.T) {
+	// Kirim ulang dengan idempotency key sama: transfer pertama mungkin sudah jalan, jangan refund
+	err := error(&apiError{Status: 400, Body: `{"error_code":"DUPLICATE_TRANSACTION_ERROR","message":"Idempotency key has been used before"}`})
+	if errors.Is(err, ErrRejected) {
+		t.Error("400 duplicate dianggap ErrRejected, saldo bisa dikembalikan padahal transfer sudah jalan")
+	}
+}
@@ -0,0 +1,128 @@
+package disbursement
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IrisProvider adapter Midtrans Iris (https://docs.midtrans.com/reference/iris-api)
+type IrisProvider struct {
+	baseURL     string
+	creatorKey  string // Membuat payout & cek rekening
+	approverKey string // Kosong = payout harus di-approve manual di dashboard Iris
+	merchantKey string // Untuk verifikasi header Iris-Signature di callback
+}
+
+func NewIrisProvider(creatorKey, approverKey, merchantKey string, production bool) *IrisProvider {
+	baseURL := "https://app.sandbox.midtrans.com/iris/api/v1"
+	if production {
+		baseURL = "https://app.midtrans.com/iris/api/v1"
+	}
+	return &IrisProvider{baseURL: baseURL, creatorKey: creatorKey, approverKey: approverKey, merchantKey: merchantKey}
+}
+
+func (p *IrisProvider) Name() string {
+	return "iris"
+}
+
+func (p *IrisProvider) InquireAccount(ctx context.Context, bankCode, accountNumber string) (AccountInfo, error) {
+	if _, ok := SupportedBanks[bankCode]; !ok {
+		return AccountInfo{}, ErrUnsupportedBank
+	}
+
+	var resp struct {
+		AccountName string `json:"account_name"`
+		AccountNo   string `json:"account_no"`
+	}
+	q := url.Values{"bank": {bankCode}, "account": {accountNumber}}
+	err := doJSON(ctx, http.MethodGet, p.baseURL+"/account_validation?"+q.Encode(), p.creatorKey, nil, nil, &resp)
+	if errors.Is(err, ErrRejected) {
+		return AccountInfo{}, ErrAccountNotFound
+	}
+	if err != nil {
+		return AccountInfo{}, err
+	}
+
+	return AccountInfo{BankCode: bankCode, AccountNumber: accountNumber, AccountName: resp.AccountName}, nil
+}
+
+func (p *IrisProvider) Disburse(ctx context.Context, req PayoutRequest) (PayoutResult, error) {
+	// 1. Buat payout (status awal "queued")
+	body := map[string]interface{}{
+		"payouts": []map[string]string{{
+			"beneficiary_name":    req.AccountName,
+			"beneficiary_account": req.AccountNumber,
+			"beneficiary_bank":    req.BankCode,
+			"amount":              fmt.Sprintf("%d", req.Amount.Int64()),
+			"notes":               req.ReferenceID + " " + req.Description,
+		}},
+	}
+	var created struct {
+		Payouts []struct {
+			Status      string `json:"status"`
+			ReferenceNo string `json:"reference_no"`
+		} `json:"payouts"`
+	}
+	headers := map[string]string{"X-Idempotency-Key": req.ReferenceID}
+	if err := doJSON(ctx, http.MethodPost, p.baseURL+"/payouts", p.creatorKey, headers, body, &created); err != nil {
+		return PayoutResult{}, err
+	}
+	if len(created.Payouts) == 0 {
+		return PayoutResult{}, errors.New("iris: response payout kosong")
+	}
+
+	result := PayoutResult{ReferenceID: req.ReferenceID, ProviderRef: created.Payouts[0].ReferenceNo, Status: PayoutPending}
+
+	// 2. Auto-approve kalau approver key diset
+	if p.approverKey != "" {
+		approve := map[string]interface{}{"reference_nos": []string{result.ProviderRef}}
+		if err := doJSON(ctx, http.MethodPost, p.baseURL+"/payouts/approve", p.approverKey, nil, approve, nil); err != nil {
+			// Payout sudah terbuat, jadi jangan dianggap gagal: biarkan PROCESSING & approve manual di dashboard
+			result.FailureReason = "auto-approve gagal: " + err.Error()
+		}
+	}
+
+	return result, nil
+}
+
+// ParseCallback callback Iris. Signature = SHA512(body + merchant key) di header Iris-Signature.
+func (p *IrisProvider) ParseCallback(r *http.Request) (PayoutResult, error) {
+	raw, err := readBody(r)
+	if err != nil {
+		return PayoutResult{}, err
+	}
+
+	sum := sha512.Sum512(append(raw, []byte(p.merchantKey)...))
+	expected := hex.EncodeToString(sum[:])
+	if p.merchantKey == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(r.Header.Get("Iris-Signature"))) != 1 {
+		return PayoutResult{}, ErrInvalidCallback
+	}
+
+	var payload struct {
+		ReferenceNo  string `json:"reference_no"`
+		Status       string `json:"status"`
+		ErrorCode    string `json:"error_code"`
+		ErrorMessage string `json:"error_message"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.ReferenceNo == "" {
+		return PayoutResult{}, ErrInvalidCallback
+	}
+
+	result := PayoutResult{ProviderRef: payload.ReferenceNo, Status: PayoutPending}
+	switch strings.ToLower(payload.Status) {
+	case "completed":
+		result.Status = PayoutSuccess
+	case "failed", "rejected":
+		result.Status = PayoutFailed
+		result.FailureReason = strings.TrimSpace(payload.ErrorCode + " " + payload.ErrorMessage)
+	}
+	return result, nil
+}
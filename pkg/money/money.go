@@ -0,0 +1,100 @@
+// Package money adalah tipe nominal Rupiah. Disimpan sebagai bilangan bulat (Rupiah penuh, tanpa sen)
+// supaya tidak ada error pembulatan float saat jumlah/bagi hasil & cocok dengan gross_amount Midtrans.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money nominal dalam Rupiah penuh
+type Money int64
+
+// BpsScale 100% dalam basis poin (1 bps = 0,01%)
+const BpsScale = 10000
+
+// FromFloat konversi dari float (data lama / hasil hitung luar) dengan pembulatan ke Rupiah terdekat
+func FromFloat(f float64) Money {
+	return Money(math.Round(f))
+}
+
+// Int64 untuk API pihak ketiga (Midtrans gross_amount, dll)
+func (m Money) Int64() int64 {
+	return int64(m)
+}
+
+// String format tampilan: Rp150.000
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	digits := strconv.FormatInt(v, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp" + b.String()
+}
+
+// PercentToBps konversi persen (misal 87.5) ke basis poin (8750), dibulatkan ke bps terdekat
+func PercentToBps(percent float64) int64 {
+	return int64(math.Round(percent * 100))
+}
+
+// BpsToPercent kebalikan PercentToBps (untuk tampilan)
+func BpsToPercent(bps int64) float64 {
+	return float64(bps) / 100
+}
+
+// MulBps menghitung bagian bps dari nominal. Aturan pembulatan: SELALU dibulatkan ke bawah (floor),
+// sisa pecahannya jadi milik pihak lain (lihat Split).
+func (m Money) MulBps(bps int64) Money {
+	product := int64(m) * bps
+	q := product / BpsScale
+	if product%BpsScale != 0 && product < 0 {
+		q-- // floor juga untuk nilai negatif
+	}
+	return Money(q)
+}
+
+// Split membagi nominal jadi bagian penerima (bps, dibulatkan ke bawah) & sisanya.
+// Dijamin share + rest == m, jadi tidak ada Rupiah yang hilang/tercipta karena pembulatan.
+func (m Money) Split(bps int64) (share, rest Money) {
+	share = m.MulBps(bps)
+	return share, m - share
+}
+
+// UnmarshalJSON menerima angka (150000 atau 150000.0) maupun string ("150000").
+// Nominal pecahan Rupiah (150000.5) ditolak supaya tidak ada pembulatan diam-diam.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if string(data) == "null" || len(data) == 0 {
+		return nil
+	}
+
+	if v, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+		*m = Money(v)
+		return nil
+	}
+
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("nominal tidak valid: %s", data)
+	}
+	if f != math.Trunc(f) {
+		return fmt.Errorf("nominal harus Rupiah bulat: %s", data)
+	}
+	*m = Money(f)
+	return nil
+}
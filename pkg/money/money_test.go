@@ -0,0 +1,117 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMulBps(t *testing.T) {
+	cases := []struct {
+		m    Money
+		bps  int64
+		want Money
+	}{
+		{150000, 8000, 120000},
+		{150001, 8000, 120000}, // 120000.8 -> floor
+		{99999, 1, 9},          // 9.9999 -> floor
+		{100, 0, 0},
+		{100, BpsScale, 100},
+		{0, 8000, 0},
+		{-150000, 8000, -120000},
+		{-150001, 8000, -120001}, // -120000.8 -> floor = -120001 (bukan dipotong ke -120000)
+		{-1, 5000, -1},           // -0.5 -> -1
+		{150001, -8000, -120001},
+	}
+	for _, c := range cases {
+		if got := c.m.MulBps(c.bps); got != c.want {
+			t.Errorf("%d.MulBps(%d) = %d, mau %d", c.m, c.bps, got, c.want)
+		}
+	}
+}
+
+func TestSplitNeverLosesRupiah(t *testing.T) {
+	amounts := []Money{0, 1, 7, 99999, 150000, 150001, 333333, 1000000007, -1, -150001}
+	rates := []int64{0, 1, 3333, 5000, 8000, 8750, 9999, BpsScale}
+
+	for _, m := range amounts {
+		for _, bps := range rates {
+			share, rest := m.Split(bps)
+			if share+rest != m {
+				t.Errorf("%d.Split(%d) = %d + %d, jumlahnya bukan %d", m, bps, share, rest, m)
+			}
+			if share != m.MulBps(bps) {
+				t.Errorf("%d.Split(%d) share = %d, mau %d", m, bps, share, m.MulBps(bps))
+			}
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{`150000`, 150000, false},
+		{`"150000"`, 150000, false},
+		{`150000.0`, 150000, false},
+		{`1.5e5`, 150000, false},
+		{`-25000`, -25000, false},
+		{`null`, 0, false},
+		{`150000.5`, 0, true},
+		{`"150000.5"`, 0, true},
+		{`"abc"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, c := range cases {
+		var m Money
+		err := json.Unmarshal([]byte(c.input), &m)
+		if (err != nil) != c.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, mau error = %v", c.input, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && m != c.want {
+			t.Errorf("Unmarshal(%s) = %d, mau %d", c.input, m, c.want)
+		}
+	}
+}
+
+func TestPercentToBps(t *testing.T) {
+	cases := []struct {
+		percent float64
+		want    int64
+	}{
+		{80, 8000},
+		{87.5, 8750},
+		{12.345, 1235}, // dibulatkan ke bps terdekat
+		{12.344, 1234},
+		{0.29, 29}, // 0.29*100 = 28.999999999999996 di float
+		{100, BpsScale},
+		{0, 0},
+	}
+	for _, c := range cases {
+		if got := PercentToBps(c.percent); got != c.want {
+			t.Errorf("PercentToBps(%v) = %d, mau %d", c.percent, got, c.want)
+		}
+		if c.percent == float64(int64(c.percent)) && BpsToPercent(c.want) != c.percent {
+			t.Errorf("BpsToPercent(%d) = %v, mau %v", c.want, BpsToPercent(c.want), c.percent)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := map[Money]string{
+		0:        "Rp0",
+		999:      "Rp999",
+		1000:     "Rp1.000",
+		150000:   "Rp150.000",
+		1234567:  "Rp1.234.567",
+		-150000:  "-Rp150.000",
+		-1000000: "-Rp1.000.000",
+	}
+	for m, want := range cases {
+		if got := m.String(); got != want {
+			t.Errorf("Money(%d).String() = %q, mau %q", int64(m), got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeGateway dipakai untuk development & testing (tidak ada uang sungguhan yang berpindah).
+// Tagihan disimpan di memori. Hasil pembayaran digerakkan lewat Simulate (endpoint simulator),
+// yang membuat body notifikasi berformat Midtrans lengkap dengan signature, jadi alur webhook teruji penuh.
+type FakeGateway struct {
+	mu        sync.Mutex
+	serverKey string
+	charges   map[string]*fakeCharge
+	seq       int
+}
+
+type fakeCharge struct {
+	amount        string // "150000.00"
+	status        string // Status ala Midtrans: pending, settlement, expire, cancel, deny, refund
+	transactionID string
+}
+
+var ErrInvalidTransition = errors.New("status transaksi tidak bisa diubah")
+
+func NewFakeGateway(serverKey string) *FakeGateway {
+	if serverKey == "" {
+		serverKey = "fake-server-key"
+	}
+	return &FakeGateway{serverKey: serverKey, charges: map[string]*fakeCharge{}}
+}
+
+func (f *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (f *FakeGateway) CreateCharge(_ context.Context, req ChargeRequest) (Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	f.charges[req.OrderNo] = &fakeCharge{
+		amount:        fmt.Sprintf("%d.00", req.Amount.Int64()),
+		status:        "pending",
+		transactionID: fmt.Sprintf("FAKE-%d-%d", time.Now().Unix(), f.seq),
+	}
+	return Charge{Token: "FAKE-TOKEN-" + req.OrderNo, RedirectURL: "fake://payment/" + req.OrderNo}, nil
+}
+
+func (f *FakeGateway) QueryStatus(_ context.Context, orderNo string) (Notification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	charge, ok := f.charges[orderNo]
+	if !ok {
+		return Notification{}, ErrNotFound
+	}
+	return buildMidtransNotification(orderNo, charge.transactionID, charge.status, "accept", charge.amount)
+}
+
+func (f *FakeGateway) Cancel(_ context.Context, orderNo string) error {
+	_, err := f.transition(orderNo, "cancel")
+	return err
+}
+
+func (f *FakeGateway) Refund(_ context.Context, req RefundRequest) error {
+	_, err := f.transition(req.OrderNo, "refund")
+	return err
+}
+
+func (f *FakeGateway) ParseWebhook(body []byte) (Notification, error) {
+	return parseMidtransWebhook(body, f.serverKey)
+}
+
+// Simulate mengubah status tagihan (settlement, pending, expire, cancel, deny) &
+// mengembalikan body notifikasi bertanda tangan, seolah dikirim gateway ke webhook.
+func (f *FakeGateway) Simulate(orderNo, status string) ([]byte, error) {
+	charge, err := f.transition(orderNo, status)
+	if err != nil {
+		return nil, err
+	}
+
+	statusCode := "201"
+	switch status {
+	case "settlement":
+		statusCode = "200"
+	case "deny", "cancel", "expire":
+		statusCode = "202"
+	}
+
+	return json.Marshal(midtransWebhook{
+		TransactionStatus: status,
+		OrderID:           orderNo,
+		FraudStatus:       "accept",
+		StatusCode:        statusCode,
+		GrossAmount:       charge.amount,
+		SignatureKey:      midtransSignature(orderNo, statusCode, charge.amount, f.serverKey),
+		TransactionID:     charge.transactionID,
+	})
+}
+
+// transition: tagihan pending bisa jadi apa saja, settlement hanya bisa di-refund
+func (f *FakeGateway) transition(orderNo, status string) (fakeCharge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	charge, ok := f.charges[orderNo]
+	if !ok {
+		return fakeCharge{}, ErrNotFound
+	}
+
+	allowed := charge.status == "pending" || charge.status == status ||
+		(charge.status == "settlement" && status == "refund")
+	if !allowed {
+		return *charge, ErrInvalidTransition
+	}
+
+	charge.status = status
+	return *charge, nil
+}
@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newFakeCharge(t *testing.T, f *FakeGateway, orderNo string) {
+	t.Helper()
+	if _, err := f.CreateCharge(context.Background(), ChargeRequest{OrderNo: orderNo, Amount: 150000}); err != nil {
+		t.Fatalf("CreateCharge: %v", err)
+	}
+}
+
+func TestFakeSimulateRoundTrip(t *testing.T) {
+	cases := []struct {
+		status string
+		want   string
+	}{
+		{"settlement", StatusPaid},
+		{"pending", StatusPending},
+		{"expire", StatusFailed},
+		{"cancel", StatusFailed},
+		{"deny", StatusFailed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.status, func(t *testing.T) {
+			f := NewFakeGateway("")
+			newFakeCharge(t, f, "INV-1")
+
+			body, err := f.Simulate("INV-1", tc.status)
+			if err != nil {
+				t.Fatalf("Simulate: %v", err)
+			}
+			n, err := f.ParseWebhook(body)
+			if err != nil {
+				t.Fatalf("ParseWebhook: %v", err)
+			}
+			if n.OrderNo != "INV-1" || n.Status != tc.want || n.RawStatus != tc.status || n.Amount != 150000 {
+				t.Errorf("notifikasi = %+v, mau order INV-1 status %s nominal 150000", n, tc.want)
+			}
+			if n.TransactionID == "" {
+				t.Error("transaction_id kosong")
+			}
+
+			// QueryStatus (dipakai rekonsiliasi) harus melihat status yang sama
+			q, err := f.QueryStatus(context.Background(), "INV-1")
+			if err != nil || q.Status != tc.want {
+				t.Errorf("QueryStatus = %s (%v), mau %s", q.Status, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFakeRejectsOtherServerKey(t *testing.T) {
+	f := NewFakeGateway("key-a")
+	newFakeCharge(t, f, "INV-1")
+	body, err := f.Simulate("INV-1", "settlement")
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if _, err := NewFakeGateway("key-b").ParseWebhook(body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook dengan server key lain: err = %v, mau ErrInvalidSignature", err)
+	}
+}
+
+func TestFakeTransitions(t *testing.T) {
+	f := NewFakeGateway("")
+	newFakeCharge(t, f, "INV-1")
+
+	if _, err := f.Simulate("INV-404", "settlement"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("order tidak dikenal: err = %v, mau ErrNotFound", err)
+	}
+	if _, err := f.Simulate("INV-1", "settlement"); err != nil {
+		t.Fatalf("settlement: %v", err)
+	}
+	// Notifikasi ulang status yang sama boleh (gateway memang suka kirim dobel)
+	if _, err := f.Simulate("INV-1", "settlement"); err != nil {
+		t.Errorf("settlement ulang: %v", err)
+	}
+	// Yang sudah lunas tidak bisa jadi expire / dibatalkan, hanya bisa di-refund
+	if _, err := f.Simulate("INV-1", "expire"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("settlement -> expire: err = %v, mau ErrInvalidTransition", err)
+	}
+	if err := f.Cancel(context.Background(), "INV-1"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Cancel setelah lunas: err = %v, mau ErrInvalidTransition", err)
+	}
+	if err := f.Refund(context.Background(), RefundRequest{OrderNo: "INV-1"}); err != nil {
+		t.Errorf("Refund setelah lunas: %v", err)
+	}
+}
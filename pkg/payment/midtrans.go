@@ -0,0 +1,188 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"homecare-backend/pkg/money"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+// MidtransGateway pembayaran lewat Midtrans Snap (charge) & Core API (status, cancel, refund)
+type MidtransGateway struct {
+	serverKey string
+	env       midtrans.EnvironmentType
+}
+
+func NewMidtransGateway(serverKey string, production bool) *MidtransGateway {
+	env := midtrans.Sandbox
+	if production {
+		env = midtrans.Production
+	}
+	return &MidtransGateway{serverKey: serverKey, env: env}
+}
+
+func (m *MidtransGateway) Name() string {
+	return "midtrans"
+}
+
+func (m *MidtransGateway) CreateCharge(_ context.Context, req ChargeRequest) (Charge, error) {
+	var s snap.Client
+	s.New(m.serverKey, m.env)
+
+	items := make([]midtrans.ItemDetails, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, midtrans.ItemDetails{
+			ID:    item.ID,
+			Name:  item.Name,
+			Price: item.Price.Int64(), // Midtrans minta int64 (Rupiah bulat)
+			Qty:   int32(item.Qty),
+		})
+	}
+
+	resp, err := s.CreateTransaction(&snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderNo,
+			GrossAmt: req.Amount.Int64(),
+		},
+		CreditCard: &snap.CreditCardDetails{
+			Secure: true,
+		},
+		CustomerDetail: &midtrans.CustomerDetails{
+			FName: req.CustomerName,
+			Email: req.CustomerEmail,
+			Phone: req.CustomerPhone,
+		},
+		Items: &items,
+	})
+	if err != nil {
+		return Charge{}, fmt.Errorf("midtrans: %s", err.GetMessage())
+	}
+	return Charge{Token: resp.Token, RedirectURL: resp.RedirectURL}, nil
+}
+
+func (m *MidtransGateway) QueryStatus(_ context.Context, orderNo string) (Notification, error) {
+	resp, err := m.core().CheckTransaction(orderNo)
+	if err != nil {
+		if err.StatusCode == 404 {
+			return Notification{}, ErrNotFound
+		}
+		return Notification{}, fmt.Errorf("midtrans: %s", err.GetMessage())
+	}
+	return buildMidtransNotification(resp.OrderID, resp.TransactionID, resp.TransactionStatus, resp.FraudStatus, resp.GrossAmount)
+}
+
+func (m *MidtransGateway) Cancel(_ context.Context, orderNo string) error {
+	if _, err := m.core().CancelTransaction(orderNo); err != nil {
+		return fmt.Errorf("midtrans: %s", err.GetMessage())
+	}
+	return nil
+}
+
+func (m *MidtransGateway) Refund(_ context.Context, req RefundRequest) error {
+	_, err := m.core().RefundTransaction(req.OrderNo, &coreapi.RefundReq{
+		RefundKey: req.RefundKey,
+		Amount:    req.Amount.Int64(),
+		Reason:    req.Reason,
+	})
+	if err != nil {
+		return fmt.Errorf("midtrans: %s", err.GetMessage())
+	}
+	return nil
+}
+
+func (m *MidtransGateway) ParseWebhook(body []byte) (Notification, error) {
+	return parseMidtransWebhook(body, m.serverKey)
+}
+
+func (m *MidtransGateway) core() coreapi.Client {
+	var client coreapi.Client
+	client.New(m.serverKey, m.env)
+	return client
+}
+
+// midtransWebhook body notifikasi Midtrans (format ini juga dipakai FakeGateway)
+type midtransWebhook struct {
+	TransactionStatus string `json:"transaction_status"`
+	OrderID           string `json:"order_id"`
+	FraudStatus       string `json:"fraud_status"`
+	StatusCode        string `json:"status_code"`
+	GrossAmount       string `json:"gross_amount"` // Format "150000.00"
+	SignatureKey      string `json:"signature_key"`
+	TransactionID     string `json:"transaction_id"`
+}
+
+// parseMidtransWebhook: signature_key = SHA512(order_id + status_code + gross_amount + server key)
+func parseMidtransWebhook(body []byte, serverKey string) (Notification, error) {
+	var w midtransWebhook
+	if err := json.Unmarshal(body, &w); err != nil || w.OrderID == "" {
+		return Notification{}, ErrInvalidPayload
+	}
+
+	n, err := buildMidtransNotification(w.OrderID, w.TransactionID, w.TransactionStatus, w.FraudStatus, w.GrossAmount)
+	if serverKey == "" || w.SignatureKey == "" ||
+		subtle.ConstantTimeCompare([]byte(midtransSignature(w.OrderID, w.StatusCode, w.GrossAmount, serverKey)), []byte(strings.ToLower(w.SignatureKey))) != 1 {
+		return n, ErrInvalidSignature
+	}
+	return n, err
+}
+
+func midtransSignature(orderID, statusCode, grossAmount, serverKey string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func buildMidtransNotification(orderID, transactionID, transactionStatus, fraudStatus, grossAmount string) (Notification, error) {
+	n := Notification{
+		OrderNo:       orderID,
+		TransactionID: transactionID,
+		RawStatus:     transactionStatus,
+		FraudStatus:   fraudStatus,
+		GrossAmount:   grossAmount,
+		Status:        mapMidtransStatus(transactionStatus, fraudStatus),
+	}
+	amount, err := parseGrossAmount(grossAmount)
+	if err != nil {
+		return n, ErrInvalidPayload
+	}
+	n.Amount = amount
+	return n, nil
+}
+
+// mapMidtransStatus menerjemahkan transaction_status Midtrans ke status pembayaran
+func mapMidtransStatus(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture":
+		if fraudStatus == "accept" {
+			return StatusPaid // Sukses CC
+		}
+		return StatusPending // challenge: masih diverifikasi bank
+	case "settlement":
+		return StatusPaid // Sukses Transfer Bank/Gopay
+	case "deny", "cancel", "expire":
+		return StatusFailed // Gagal
+	default:
+		return StatusPending
+	}
+}
+
+// parseGrossAmount "150000.00" -> Rp150.000 (harus Rupiah bulat)
+func parseGrossAmount(s string) (money.Money, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("gross_amount %s bukan Rupiah bulat", s)
+	}
+	return money.Money(int64(f)), nil
+}
@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testServerKey = "SB-Mid-server-test"
+
+// signedBody body notifikasi Midtrans dengan signature yang benar untuk server key test
+func signedBody(t *testing.T, w midtransWebhook) midtransWebhook {
+	t.Helper()
+	w.SignatureKey = midtransSignature(w.OrderID, w.StatusCode, w.GrossAmount, testServerKey)
+	return w
+}
+
+func marshal(t *testing.T, w midtransWebhook) []byte {
+	t.Helper()
+	body, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestMidtransSignature(t *testing.T) {
+	valid := signedBody(t, midtransWebhook{
+		TransactionStatus: "settlement",
+		OrderID:           "INV-20261019-000001",
+		StatusCode:        "200",
+		GrossAmount:       "150000.00",
+		TransactionID:     "trx-1",
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		n, err := parseMidtransWebhook(marshal(t, valid), testServerKey)
+		if err != nil {
+			t.Fatalf("err = %v", err)
+		}
+		if n.Status != StatusPaid || n.Amount != 150000 || n.OrderNo != valid.OrderID {
+			t.Errorf("notifikasi = %+v", n)
+		}
+	})
+
+	t.Run("signature huruf besar", func(t *testing.T) {
+		w := valid
+		w.SignatureKey = strings.ToUpper(w.SignatureKey)
+		if _, err := parseMidtransWebhook(marshal(t, w), testServerKey); err != nil {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	tampered := map[string]func(w *midtransWebhook){
+		"signature diubah":   func(w *midtransWebhook) { w.SignatureKey = strings.Repeat("0", 128) },
+		"signature kosong":   func(w *midtransWebhook) { w.SignatureKey = "" },
+		"nominal diubah":     func(w *midtransWebhook) { w.GrossAmount = "1000.00" },
+		"order diubah":       func(w *midtransWebhook) { w.OrderID = "INV-20261019-000002" },
+		"status code diubah": func(w *midtransWebhook) { w.StatusCode = "201" },
+		"server key berbeda": func(w *midtransWebhook) {
+			w.SignatureKey = midtransSignature(w.OrderID, w.StatusCode, w.GrossAmount, "lain")
+		},
+	}
+	for name, mutate := range tampered {
+		t.Run(name, func(t *testing.T) {
+			w := valid
+			mutate(&w)
+			if _, err := parseMidtransWebhook(marshal(t, w), testServerKey); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("err = %v, mau ErrInvalidSignature", err)
+			}
+		})
+	}
+
+	t.Run("server key belum diset", func(t *testing.T) {
+		if _, err := parseMidtransWebhook(marshal(t, valid), ""); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("err = %v, mau ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("body rusak", func(t *testing.T) {
+		if _, err := parseMidtransWebhook([]byte("{bukan json"), testServerKey); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("err = %v, mau ErrInvalidPayload", err)
+		}
+	})
+
+	t.Run("nominal pecahan", func(t *testing.T) {
+		w := signedBody(t, midtransWebhook{OrderID: "INV-1", StatusCode: "200", GrossAmount: "150000.50", TransactionStatus: "settlement"})
+		if _, err := parseMidtransWebhook(marshal(t, w), testServerKey); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("err = %v, mau ErrInvalidPayload", err)
+		}
+	})
+}
+
+func TestMapMidtransStatus(t *testing.T) {
+	cases := []struct {
+		status, fraud, want string
+	}{
+		{"settlement", "", StatusPaid},
+		{"settlement", "accept", StatusPaid},
+		{"capture", "accept", StatusPaid},
+		{"capture", "challenge", StatusPending},
+		{"pending", "", StatusPending},
+		{"deny", "", StatusFailed},
+		{"expire", "", StatusFailed},
+		{"cancel", "", StatusFailed},
+		{"refund", "", StatusPending},
+		{"", "", StatusPending},
+	}
+	for _, tc := range cases {
+		if got := mapMidtransStatus(tc.status, tc.fraud); got != tc.want {
+			t.Errorf("mapMidtransStatus(%q, %q) = %s, mau %s", tc.status, tc.fraud, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"homecare-backend/pkg/money"
+	"log"
+	"os"
+)
+
+// Gateway adalah abstraksi payment gateway (Midtrans, dll).
+// Handler cukup pakai interface ini, jadi gampang ganti gateway / pakai fake saat development & testing.
+type Gateway interface {
+	Name() string
+
+	// CreateCharge membuat tagihan & mengembalikan token / link pembayaran
+	CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error)
+
+	// QueryStatus mengambil status transaksi terkini langsung dari gateway
+	QueryStatus(ctx context.Context, orderNo string) (Notification, error)
+
+	// Cancel membatalkan tagihan yang belum dibayar
+	Cancel(ctx context.Context, orderNo string) error
+
+	// Refund mengembalikan dana transaksi yang sudah dibayar (sebagian / penuh)
+	Refund(ctx context.Context, req RefundRequest) error
+
+	// ParseWebhook memvalidasi signature & membaca body notifikasi.
+	// Kalau signature salah, Notification tetap diisi (untuk audit) bersama ErrInvalidSignature.
+	ParseWebhook(body []byte) (Notification, error)
+}
+
+// Status pembayaran (sudah dinormalisasi dari status masing-masing gateway)
+const (
+	StatusPending = "PENDING"
+	StatusPaid    = "PAID"
+	StatusFailed  = "FAILED" // Ditolak, dibatalkan, atau kedaluwarsa
+)
+
+// ChargeRequest data tagihan
+type ChargeRequest struct {
+	OrderNo       string
+	Amount        money.Money
+	CustomerName  string
+	CustomerEmail string
+	CustomerPhone string
+	Items         []Item
+}
+
+// Item rincian tagihan
+type Item struct {
+	ID    string
+	Name  string
+	Price money.Money
+	Qty   int
+}
+
+// Charge hasil pembuatan tagihan
+type Charge struct {
+	Token       string `json:"token"`
+	RedirectURL string `json:"redirect_url"`
+}
+
+// RefundRequest permintaan refund. RefundKey unik per refund (idempotency di sisi gateway).
+type RefundRequest struct {
+	OrderNo   string
+	RefundKey string
+	Amount    money.Money
+	Reason    string
+}
+
+// Notification status transaksi dari webhook / query status
+type Notification struct {
+	OrderNo       string
+	TransactionID string
+	RawStatus     string // Status asli gateway (settlement, expire, dll)
+	FraudStatus   string
+	GrossAmount   string      // Nominal mentah dari gateway ("150000.00")
+	Amount        money.Money // Nominal dalam Rupiah bulat
+	Status        string      // PENDING, PAID, FAILED
+}
+
+// Default dipakai oleh handler (di-set lewat Init di main)
+var Default Gateway
+
+var (
+	ErrInvalidSignature = errors.New("signature notifikasi tidak valid")
+	ErrInvalidPayload   = errors.New("body notifikasi tidak valid")
+	ErrNotFound         = errors.New("transaksi tidak ditemukan")
+)
+
+// Init memilih gateway berdasarkan .env
+// PAYMENT_GATEWAY=midtrans (default) | fake
+//   - midtrans: MIDTRANS_SERVER_KEY, MIDTRANS_ENV=production (selain itu sandbox)
+//   - fake:     FAKE_PAYMENT_SERVER_KEY (opsional, kunci signature notifikasi simulasi)
+func Init() {
+	name := os.Getenv("PAYMENT_GATEWAY")
+	if name == "" {
+		name = "midtrans"
+	}
+
+	switch name {
+	case "midtrans":
+		Default = NewMidtransGateway(os.Getenv("MIDTRANS_SERVER_KEY"), os.Getenv("MIDTRANS_ENV") == "production")
+	case "fake":
+		Default = NewFakeGateway(os.Getenv("FAKE_PAYMENT_SERVER_KEY"))
+	default:
+		log.Fatalf("PAYMENT_GATEWAY tidak dikenal: %s", name)
+	}
+
+	log.Printf("Payment gateway: %s", Default.Name())
+}
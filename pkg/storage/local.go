@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage menyimpan file di filesystem server (cocok untuk 1 VPS + volume Docker)
+type LocalStorage struct {
+	baseDir string
+}
+
+// NewLocalStorage membuat folder dasar kalau belum ada
+func NewLocalStorage(baseDir string) (*LocalStorage, error) {
+	abs, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{baseDir: abs}, nil
+}
+
+func (s *LocalStorage) Save(path string, r io.Reader) error {
+	full, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(full, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(full) // Jangan tinggalkan file setengah jadi
+		return err
+	}
+	return f.Close()
+}
+
+func (s *LocalStorage) Open(path string) (io.ReadCloser, error) {
+	full, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(full)
+}
+
+func (s *LocalStorage) Delete(path string) error {
+	full, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// resolve mengubah path relatif jadi path absolut & mencegah path traversal ("../../etc/passwd")
+func (s *LocalStorage) resolve(path string) (string, error) {
+	full := filepath.Join(s.baseDir, filepath.Clean("/"+path))
+	if !strings.HasPrefix(full, s.baseDir+string(os.PathSeparator)) {
+		return "", ErrInvalidPath
+	}
+	return full, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// Storage adalah abstraksi tempat penyimpanan file (dokumen mitra, foto, dll).
+// Path selalu relatif (misal: "partners/12/STR-1700000000.pdf"),
+// jadi nanti gampang ganti backend ke S3/GCS tanpa ubah handler.
+type Storage interface {
+	Save(path string, r io.Reader) error
+	Open(path string) (io.ReadCloser, error)
+	Delete(path string) error
+}
+
+// Default dipakai oleh handler (di-set lewat Init di main)
+var Default Storage
+
+var ErrInvalidPath = errors.New("path file tidak valid")
+
+// Init memilih backend storage berdasarkan .env
+// STORAGE_DRIVER=local (default), STORAGE_LOCAL_DIR=./uploads
+func Init() {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	switch driver {
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		local, err := NewLocalStorage(dir)
+		if err != nil {
+			log.Fatalf("Error initializing local storage: %v", err)
+		}
+		Default = local
+	default:
+		log.Fatalf("STORAGE_DRIVER tidak dikenal: %s", driver)
+	}
+
+	log.Println("🗂️  Storage Ready! Driver:", driver)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// Jari-jari bumi dalam KM (sama dengan angka 6371 di query SearchPartners)
+const EarthRadiusKM = 6371.0
+
+// Kecepatan rata-rata motor/mobil di dalam kota untuk estimasi ETA
+const AvgCitySpeedKMH = 25.0
+
+// HaversineKM menghitung jarak garis lurus 2 koordinat dalam KM
+func HaversineKM(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return EarthRadiusKM * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// EstimateETA memperkirakan waktu tempuh. Kalau kecepatan dari GPS masuk akal (> 5 km/j) pakai itu,
+// kalau tidak pakai kecepatan rata-rata kota. Jarak dikali 1.3 karena jalan tidak lurus.
+func EstimateETA(distanceKM float64, speedKMH float64) time.Duration {
+	if speedKMH < 5 {
+		speedKMH = AvgCitySpeedKMH
+	}
+	hours := (distanceKM * 1.3) / speedKMH
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// BoundingBox menghitung kotak koordinat (min/max lat & lng) yang memuat lingkaran radius tertentu.
+// Dipakai sebagai prefilter SQL: "lat BETWEEN ? AND ?" bisa pakai index, rumus Haversine tidak.
+// Dekat antimeridian minLng/maxLng bisa melewati ±180 (misal 179.9 + 0.2 = 180.1), pemanggil yang
+// membungkusnya ke rentang -180..180. Kalau lingkaran memuat kutub, rentang bujur = seluruh bumi.
+func BoundingBox(lat, lng, radiusKM float64) (minLat, maxLat, minLng, maxLng float64) {
+	// 1 derajat lintang ≈ 111.045 KM (konstan di seluruh bumi, sedikit lebih kecil dari aslinya = kotak lebih longgar)
+	latDelta := radiusKM / 111.045
+	minLat, maxLat = lat-latDelta, lat+latDelta
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), lng - 180, lng + 180
+	}
+
+	// 1 derajat bujur makin kecil mendekati kutub. Pakai asin(sin r / cos lat), bukan r / cos lat:
+	// pendekatan linear terlalu sempit di lintang tinggi & bisa membuang Mitra di pinggir lingkaran.
+	sinR := math.Sin(radiusKM / EarthRadiusKM)
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if sinR >= cosLat {
+		return minLat, maxLat, lng - 180, lng + 180
+	}
+	lngDelta := math.Asin(sinR/cosLat) * 180 / math.Pi
+	return minLat, maxLat, lng - lngDelta, lng + lngDelta
+}